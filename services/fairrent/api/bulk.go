@@ -0,0 +1,221 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/auth"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/bulk"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/scheduler"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/validation"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/common/v1"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/fairrent/v1"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// maxImportSize bounds the size of an import file
+	maxImportSize = 256 << 20
+
+	// exportChunkSize is the size of ExportQueue chunks
+	exportChunkSize = 64 << 10
+)
+
+// errImportTooLarge is returned for import files over maxImportSize
+var errImportTooLarge = fmt.Errorf("import is larger than %d MiB", maxImportSize>>20)
+
+// ImportQueue implements the ImportQueue RPC method
+func (s *Server) ImportQueue(stream fairrentv1.FairRentService_ImportQueueServer) error {
+	ctx := stream.Context()
+	first, err := stream.Recv()
+	if err == io.EOF {
+		return status.Error(codes.InvalidArgument, "import is empty")
+	}
+	if err != nil {
+		return err
+	}
+	format, ok := bulk.FormatFromProto(first.Format)
+	if !ok {
+		v := &validation.Violations{}
+		v.Add("format", validation.CodeRequired, "format is required")
+		return v.Err()
+	}
+
+	s.logger.Info("ImportQueue request received",
+		zap.String("format", string(format)),
+		zap.Bool("dry_run", first.DryRun),
+	)
+
+	in := &importReader{stream: stream, buf: first.Data, size: len(first.Data)}
+	rows, err := bulk.Read(in, format)
+	if in.err != nil {
+		return in.err
+	}
+	if err != nil {
+		code := codes.InvalidArgument
+		if errors.Is(err, errImportTooLarge) {
+			code = codes.ResourceExhausted
+		}
+		return status.Error(code, err.Error())
+	}
+
+	resp := &fairrentv1.ImportQueueResponse{
+		TotalRecords: int32(len(rows)),
+		DryRun:       first.DryRun,
+	}
+	reject := func(row bulk.Row, details []*commonv1.ErrorDetail) {
+		resp.RejectedRecords = append(resp.RejectedRecords, &fairrentv1.RejectedRecord{
+			Record:   int32(row.Number),
+			TicketId: row.Record.TicketID,
+			UserId:   row.Record.UserID,
+			Errors:   details,
+		})
+	}
+
+	// Every record is checked before any is imported
+	var accepted []bulk.Row
+	var tickets []scheduler.ImportedTicket
+	for _, row := range rows {
+		if row.Err != nil {
+			reject(row, validation.Details(row.Err))
+			continue
+		}
+		ticket, err := row.Record.Ticket(s.validation)
+		if err != nil {
+			reject(row, validation.Details(err))
+			continue
+		}
+		accepted = append(accepted, row)
+		tickets = append(tickets, ticket)
+	}
+
+	outcomes, err := s.scheduler.Import(ctx, actor(ctx), tickets, first.DryRun)
+	if err != nil {
+		s.logger.Error("Failed to import queue", zap.Error(err))
+		return statusError(err)
+	}
+	for i, outcome := range outcomes {
+		if outcome.Err == nil {
+			resp.Imported++
+			continue
+		}
+		v := &validation.Violations{}
+		switch {
+		case errors.Is(outcome.Err, scheduler.ErrTicketExists):
+			v.Add("ticket_id", validation.CodeAlreadyExists, outcome.Err.Error())
+		case errors.Is(outcome.Err, scheduler.ErrEnqueueTimeInFuture):
+			v.Add("enqueue_time", validation.CodeOutOfRange, outcome.Err.Error())
+		case errors.Is(outcome.Err, scheduler.ErrQueueFull):
+			v.Add("", validation.CodeTooMany, outcome.Err.Error())
+		default:
+			v.Add("", validation.CodeInconsistent, outcome.Err.Error())
+		}
+		reject(accepted[i], validation.Details(v.Err()))
+	}
+
+	sort.Slice(resp.RejectedRecords, func(i, j int) bool {
+		return resp.RejectedRecords[i].Record < resp.RejectedRecords[j].Record
+	})
+	resp.Rejected = int32(len(resp.RejectedRecords))
+
+	s.logger.Info("Queue import completed",
+		zap.Int32("total_records", resp.TotalRecords),
+		zap.Int32("imported", resp.Imported),
+		zap.Int32("rejected", resp.Rejected),
+		zap.Bool("dry_run", resp.DryRun),
+	)
+	return stream.SendAndClose(resp)
+}
+
+// ExportQueue implements the ExportQueue RPC method
+func (s *Server) ExportQueue(req *fairrentv1.ExportQueueRequest, stream fairrentv1.FairRentService_ExportQueueServer) error {
+	format, ok := bulk.FormatFromProto(req.Format)
+	if !ok {
+		v := &validation.Violations{}
+		v.Add("format", validation.CodeRequired, "format is required")
+		return v.Err()
+	}
+
+	s.logger.Info("ExportQueue request received",
+		zap.String("format", string(format)),
+		zap.Bool("include_allocated", req.IncludeAllocated),
+	)
+
+	ctx := stream.Context()
+	tickets := s.scheduler.Export(ctx, actor(ctx), req.IncludeAllocated)
+
+	out := bufio.NewWriterSize(&chunkWriter{stream: stream}, exportChunkSize)
+	w := bulk.NewWriter(out, format)
+	for _, ticket := range tickets {
+		if err := w.Write(bulk.NewRecord(ticket)); err != nil {
+			return statusError(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		return statusError(err)
+	}
+	if err := out.Flush(); err != nil {
+		return statusError(err)
+	}
+	return nil
+}
+
+// actor names the caller in audit records
+func actor(ctx context.Context) string {
+	if principal, ok := auth.FromContext(ctx); ok {
+		return principal.Subject
+	}
+	return "anonymous"
+}
+
+// importReader reads the data of an ImportQueue stream
+type importReader struct {
+	stream fairrentv1.FairRentService_ImportQueueServer
+	buf    []byte
+	size   int
+
+	// err is the stream's error, other than its end
+	err error
+}
+
+// Read implements io.Reader
+func (r *importReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		req, err := r.stream.Recv()
+		if err == io.EOF {
+			return 0, io.EOF
+		}
+		if err != nil {
+			r.err = err
+			return 0, err
+		}
+		r.size += len(req.Data)
+		if r.size > maxImportSize {
+			return 0, errImportTooLarge
+		}
+		r.buf = req.Data
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// chunkWriter sends every write as an ExportQueue chunk
+type chunkWriter struct {
+	stream fairrentv1.FairRentService_ExportQueueServer
+}
+
+// Write implements io.Writer
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	chunk := &fairrentv1.ExportQueueChunk{Data: append([]byte(nil), p...)}
+	if err := w.stream.Send(chunk); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
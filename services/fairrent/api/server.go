@@ -2,21 +2,37 @@ package api
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"net"
+	"strconv"
 	"time"
 
 	"github.com/grpc-ecosystem/go-grpc-middleware"
 	"github.com/grpc-ecosystem/go-grpc-prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/common/v1"
 	"github.com/wohnfair/wohnfair/services/gen/wohnfair/fairrent/v1"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/auth"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/authz"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/commitment"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/events"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/history"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/ratelimit"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/reports"
 	"github.com/wohnfair/wohnfair/services/fairrent/internal/scheduler"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/validation"
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/health"
 	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -27,39 +43,136 @@ type Server struct {
 	// Dependencies
 	scheduler *scheduler.FairRent
 	logger    *zap.Logger
+	reports   *reports.Store
+	history   *history.Store
+	
+	// Request validation
+	validation validation.Config
+	
+	// Transport security
+	tlsConfig *tls.Config
+	
+	// Interceptors
+	authenticator *auth.Authenticator
+	authorizer    *authz.Authorizer
+	limiter       *ratelimit.Limiter
 	
 	// gRPC server
 	grpcServer *grpc.Server
 	healthServer *health.Server
 	
 	// Configuration
+	host string
 	port int
 }
 
+// ServerOption configures optional Server dependencies
+type ServerOption func(*Server)
+
+// WithReports serves signed fairness reports from the given store
+func WithReports(store *reports.Store) ServerOption {
+	return func(s *Server) {
+		s.reports = store
+	}
+}
+
+// WithHistory serves recorded fairness history from the given store
+func WithHistory(store *history.Store) ServerOption {
+	return func(s *Server) {
+		s.history = store
+	}
+}
+
+// WithAuthenticator requires callers to present a valid bearer token
+func WithAuthenticator(authenticator *auth.Authenticator) ServerOption {
+	return func(s *Server) {
+		s.authenticator = authenticator
+	}
+}
+
+// WithAuthorizer checks calls against a role-based policy. It needs an
+// authenticator to identify callers.
+func WithAuthorizer(authorizer *authz.Authorizer) ServerOption {
+	return func(s *Server) {
+		s.authorizer = authorizer
+	}
+}
+
+// WithRateLimiter rejects calls over the configured rates
+func WithRateLimiter(limiter *ratelimit.Limiter) ServerOption {
+	return func(s *Server) {
+		s.limiter = limiter
+	}
+}
+
+// WithHost listens on the given host instead of all interfaces
+func WithHost(host string) ServerOption {
+	return func(s *Server) {
+		s.host = host
+	}
+}
+
+// WithTLS serves gRPC over TLS instead of plaintext
+func WithTLS(cfg *tls.Config) ServerOption {
+	return func(s *Server) {
+		s.tlsConfig = cfg
+	}
+}
+
+// WithValidation sets how thoroughly requests are validated
+func WithValidation(cfg validation.Config) ServerOption {
+	return func(s *Server) {
+		s.validation = cfg
+	}
+}
+
 // NewServer creates a new FairRent server
-func NewServer(scheduler *scheduler.FairRent, logger *zap.Logger, port int) *Server {
+func NewServer(scheduler *scheduler.FairRent, logger *zap.Logger, port int, opts ...ServerOption) *Server {
+	server := &Server{
+		scheduler:  scheduler,
+		logger:     logger,
+		port:       port,
+		validation: validation.DefaultConfig(),
+	}
+	for _, opt := range opts {
+		opt(server)
+	}
+	
 	// Create gRPC server with middleware
-	grpcServer := grpc.NewServer(
-		grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(
-			grpc_prometheus.UnaryServerInterceptor,
-			otelgrpc.UnaryServerInterceptor(),
-		)),
-		grpc.StreamInterceptor(grpc_middleware.ChainStreamServer(
-			grpc_prometheus.StreamServerInterceptor,
-			otelgrpc.StreamServerInterceptor(),
-		)),
-	)
+	unary := []grpc.UnaryServerInterceptor{
+		grpc_prometheus.UnaryServerInterceptor,
+		otelgrpc.UnaryServerInterceptor(),
+	}
+	stream := []grpc.StreamServerInterceptor{
+		grpc_prometheus.StreamServerInterceptor,
+		otelgrpc.StreamServerInterceptor(),
+	}
+	if server.authenticator != nil {
+		unary = append(unary, server.authenticator.UnaryServerInterceptor())
+		stream = append(stream, server.authenticator.StreamServerInterceptor())
+	}
+	if server.limiter != nil {
+		unary = append(unary, server.limiter.UnaryServerInterceptor())
+		stream = append(stream, server.limiter.StreamServerInterceptor())
+	}
+	if server.authorizer != nil {
+		unary = append(unary, server.authorizer.UnaryServerInterceptor())
+		stream = append(stream, server.authorizer.StreamServerInterceptor())
+	}
+	grpcOpts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(unary...)),
+		grpc.StreamInterceptor(grpc_middleware.ChainStreamServer(stream...)),
+	}
+	if server.tlsConfig != nil {
+		grpcOpts = append(grpcOpts, grpc.Creds(credentials.NewTLS(server.tlsConfig)))
+	}
+	grpcServer := grpc.NewServer(grpcOpts...)
 	
 	// Create health server
 	healthServer := health.NewServer()
 	
-	server := &Server{
-		scheduler:    scheduler,
-		logger:       logger,
-		grpcServer:   grpcServer,
-		healthServer: healthServer,
-		port:         port,
-	}
+	server.grpcServer = grpcServer
+	server.healthServer = healthServer
 	
 	// Register services
 	fairrentv1.RegisterFairRentServiceServer(grpcServer, server)
@@ -79,18 +192,17 @@ func NewServer(scheduler *scheduler.FairRent, logger *zap.Logger, port int) *Ser
 
 // Start starts the gRPC server
 func (s *Server) Start() error {
-	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", s.port))
+	lis, err := net.Listen("tcp", net.JoinHostPort(s.host, strconv.Itoa(s.port)))
 	if err != nil {
 		return fmt.Errorf("failed to listen: %w", err)
 	}
 	
 	s.logger.Info("Starting FairRent gRPC server",
+		zap.String("host", s.host),
 		zap.Int("port", s.port),
+		zap.Bool("tls", s.tlsConfig != nil),
 	)
 	
-	// Start metrics server
-	go s.startMetricsServer()
-	
 	// Start gRPC server
 	if err := s.grpcServer.Serve(lis); err != nil {
 		return fmt.Errorf("failed to serve: %w", err)
@@ -112,28 +224,21 @@ func (s *Server) Stop() {
 	}
 }
 
-// startMetricsServer starts the Prometheus metrics server
-func (s *Server) startMetricsServer() {
-	// This would typically run on a different port
-	// For now, we'll just log that metrics are available via gRPC
-	s.logger.Info("Prometheus metrics available via gRPC server")
-}
-
 // Enqueue implements the Enqueue RPC method
 func (s *Server) Enqueue(ctx context.Context, req *fairrentv1.EnqueueRequest) (*fairrentv1.EnqueueResponse, error) {
 	start := time.Now()
 	
 	s.logger.Info("Enqueue request received",
-		zap.String("user_id", req.UserId.Value),
+		zap.String("user_id", req.GetUserId().GetValue()),
 		zap.String("user_group", req.UserGroup.String()),
 		zap.Int("urgency", int(req.Urgency)),
 	)
 	
 	// Validate request
-	if err := s.validateEnqueueRequest(req); err != nil {
+	if err := validation.EnqueueRequest(req, s.validation); err != nil {
 		s.logger.Error("Enqueue request validation failed",
 			zap.Error(err),
-			zap.String("user_id", req.UserId.Value),
+			zap.String("user_id", req.GetUserId().GetValue()),
 		)
 		return nil, err
 	}
@@ -145,7 +250,7 @@ func (s *Server) Enqueue(ctx context.Context, req *fairrentv1.EnqueueRequest) (*
 			zap.Error(err),
 			zap.String("user_id", req.UserId.Value),
 		)
-		return nil, err
+		return nil, statusError(err)
 	}
 	
 	// Log success
@@ -172,7 +277,7 @@ func (s *Server) ScheduleNext(ctx context.Context, req *fairrentv1.ScheduleNextR
 		s.logger.Error("Failed to schedule next request",
 			zap.Error(err),
 		)
-		return nil, err
+		return nil, statusError(err)
 	}
 	
 	// Log success
@@ -188,6 +293,10 @@ func (s *Server) ScheduleNext(ctx context.Context, req *fairrentv1.ScheduleNextR
 
 // PeekPosition implements the PeekPosition RPC method
 func (s *Server) PeekPosition(ctx context.Context, req *fairrentv1.PeekPositionRequest) (*fairrentv1.PeekPositionResponse, error) {
+	if err := validation.TicketID(req.TicketId); err != nil {
+		return nil, err
+	}
+	
 	s.logger.Debug("PeekPosition request received",
 		zap.String("ticket_id", req.TicketId.Value),
 	)
@@ -199,7 +308,7 @@ func (s *Server) PeekPosition(ctx context.Context, req *fairrentv1.PeekPositionR
 			zap.Error(err),
 			zap.String("ticket_id", req.TicketId.Value),
 		)
-		return nil, err
+		return nil, statusError(err)
 	}
 	
 	return resp, nil
@@ -215,71 +324,496 @@ func (s *Server) GetMetrics(ctx context.Context, req *fairrentv1.GetMetricsReque
 		s.logger.Error("Failed to get metrics",
 			zap.Error(err),
 		)
-		return nil, err
+		return nil, statusError(err)
 	}
 	
+	if !authz.FullView(ctx) {
+		return metricsSummary(metrics), nil
+	}
 	return metrics, nil
 }
 
+// metricsSummary keeps the queue size, wait times and fairness parameters of
+// the metrics, for callers not allowed to see them in full
+func metricsSummary(m *fairrentv1.FairnessMetrics) *fairrentv1.FairnessMetrics {
+	return &fairrentv1.FairnessMetrics{
+		Alpha:            m.Alpha,
+		GroupWeights:     m.GroupWeights,
+		TotalRequests:    m.TotalRequests,
+		TotalAllocations: m.TotalAllocations,
+		ActiveRequests:   m.ActiveRequests,
+		AverageWaitTime:  m.AverageWaitTime,
+		MedianWaitTime:   m.MedianWaitTime,
+		CalculatedAt:     m.CalculatedAt,
+	}
+}
+
 // UpdateRequest implements the UpdateRequest RPC method
 func (s *Server) UpdateRequest(ctx context.Context, req *fairrentv1.UpdateRequestRequest) (*fairrentv1.UpdateRequestResponse, error) {
+	if err := validation.UpdateRequest(req, s.validation); err != nil {
+		return nil, err
+	}
+	
 	s.logger.Info("UpdateRequest received",
 		zap.String("ticket_id", req.TicketId.Value),
 	)
 	
-	// TODO: Implement update logic
-	return nil, fmt.Errorf("UpdateRequest not yet implemented")
+	resp, err := s.scheduler.UpdateRequest(ctx, req)
+	if err != nil {
+		s.logger.Error("Failed to update request",
+			zap.Error(err),
+			zap.String("ticket_id", req.TicketId.Value),
+		)
+		return nil, statusError(err)
+	}
+	
+	return resp, nil
 }
 
 // CancelRequest implements the CancelRequest RPC method
 func (s *Server) CancelRequest(ctx context.Context, req *fairrentv1.CancelRequestRequest) (*fairrentv1.CancelRequestResponse, error) {
+	if err := validation.TicketID(req.TicketId); err != nil {
+		return nil, err
+	}
+	
 	s.logger.Info("CancelRequest received",
 		zap.String("ticket_id", req.TicketId.Value),
 		zap.String("reason", req.Reason),
 	)
 	
-	// TODO: Implement cancellation logic
-	return nil, fmt.Errorf("CancelRequest not yet implemented")
+	resp, err := s.scheduler.CancelRequest(ctx, req)
+	if err != nil {
+		s.logger.Error("Failed to cancel request",
+			zap.Error(err),
+			zap.String("ticket_id", req.TicketId.Value),
+		)
+		return nil, statusError(err)
+	}
+	
+	return resp, nil
 }
 
 // GetQueueStatus implements the GetQueueStatus RPC method
-func (s *Server) GetQueueStatus(ctx context.Context, req *fairrentv1.GetQueueStatusRequest) (*fairrentv1.QueueStatus, error) {
+func (s *Server) GetQueueStatus(ctx context.Context, req *emptypb.Empty) (*fairrentv1.QueueStatus, error) {
 	s.logger.Debug("GetQueueStatus request received")
 	
-	// TODO: Implement queue status logic
-	return nil, fmt.Errorf("GetQueueStatus not yet implemented")
+	queueStatus, err := s.scheduler.GetQueueStatus(ctx)
+	if err != nil {
+		s.logger.Error("Failed to get queue status",
+			zap.Error(err),
+		)
+		return nil, statusError(err)
+	}
+	
+	return queueStatus, nil
 }
 
 // Health implements the Health RPC method
-func (s *Server) Health(ctx context.Context, req *fairrentv1.HealthRequest) (*fairrentv1.HealthResponse, error) {
-	return &fairrentv1.HealthResponse{
-		Status:  fairrentv1.HealthResponse_SERVING,
-		Message: "FairRent service is healthy",
-		Timestamp: &timestamppb.Timestamp{
-			Seconds: time.Now().Unix(),
+func (s *Server) Health(ctx context.Context, req *emptypb.Empty) (*commonv1.HealthResponse, error) {
+	mode, since := s.scheduler.Mode()
+	
+	message := "FairRent service is healthy"
+	switch mode {
+	case scheduler.ModePaused:
+		message = "FairRent service is healthy, scheduling is paused"
+	case scheduler.ModeDraining:
+		message = "FairRent service is healthy, the queue is draining"
+	}
+	return &commonv1.HealthResponse{
+		Status:  commonv1.HealthResponse_STATUS_SERVING,
+		Message: message,
+		Details: map[string]string{
+			"mode":       string(mode),
+			"mode_since": since.UTC().Format(time.RFC3339),
 		},
+		Timestamp: timestamppb.Now(),
 	}, nil
 }
 
-// validateEnqueueRequest validates the enqueue request
-func (s *Server) validateEnqueueRequest(req *fairrentv1.EnqueueRequest) error {
-	if req.UserId == nil || req.UserId.Value == "" {
-		return fmt.Errorf("user_id is required")
+// PauseScheduling implements the PauseScheduling RPC method
+func (s *Server) PauseScheduling(ctx context.Context, req *fairrentv1.SchedulerModeRequest) (*fairrentv1.SchedulerModeResponse, error) {
+	return s.setMode(ctx, scheduler.ModePaused, req.Reason)
+}
+
+// ResumeScheduling implements the ResumeScheduling RPC method
+func (s *Server) ResumeScheduling(ctx context.Context, req *fairrentv1.SchedulerModeRequest) (*fairrentv1.SchedulerModeResponse, error) {
+	return s.setMode(ctx, scheduler.ModeRunning, req.Reason)
+}
+
+// DrainQueue implements the DrainQueue RPC method
+func (s *Server) DrainQueue(ctx context.Context, req *fairrentv1.SchedulerModeRequest) (*fairrentv1.SchedulerModeResponse, error) {
+	return s.setMode(ctx, scheduler.ModeDraining, req.Reason)
+}
+
+// setMode switches the scheduler mode on behalf of the caller
+func (s *Server) setMode(ctx context.Context, mode scheduler.Mode, reason string) (*fairrentv1.SchedulerModeResponse, error) {
+	s.logger.Info("Scheduler mode change requested",
+		zap.String("mode", string(mode)),
+		zap.String("reason", reason),
+	)
+	
+	change, err := s.scheduler.SetMode(ctx, actor(ctx), mode, reason)
+	if err != nil {
+		s.logger.Error("Failed to change scheduler mode",
+			zap.Error(err),
+			zap.String("mode", string(mode)),
+		)
+		return nil, statusError(err)
+	}
+	
+	return &fairrentv1.SchedulerModeResponse{
+		PreviousMode: change.Previous.Proto(),
+		Mode:         change.Mode.Proto(),
+		ChangedAt:    timestamppb.New(change.Since),
+		QueueLength:  int32(change.QueueLength),
+	}, nil
+}
+
+// ExplainDecision implements the ExplainDecision RPC method
+func (s *Server) ExplainDecision(ctx context.Context, req *fairrentv1.ExplainDecisionRequest) (*fairrentv1.ExplainDecisionResponse, error) {
+	if err := validation.TicketID(req.TicketId); err != nil {
+		return nil, err
+	}
+	
+	s.logger.Debug("ExplainDecision request received",
+		zap.String("ticket_id", req.TicketId.Value),
+	)
+	
+	// Process request
+	resp, err := s.scheduler.ExplainDecision(ctx, req)
+	if err != nil {
+		s.logger.Error("Failed to explain decision",
+			zap.Error(err),
+			zap.String("ticket_id", req.TicketId.Value),
+		)
+		return nil, statusError(err)
 	}
 	
-	if req.UserGroup == fairrentv1.UserGroup_USER_GROUP_UNSPECIFIED {
-		return fmt.Errorf("user_group is required")
+	// Other applicants' tickets are only shown to callers with the full view
+	if !authz.FullView(ctx) {
+		resp = scheduler.ExplanationSummary(resp)
 	}
 	
-	if req.Urgency == fairrentv1.UrgencyLevel_URGENCY_LEVEL_UNSPECIFIED {
-		return fmt.Errorf("urgency level is required")
+	return resp, nil
+}
+
+// SimulatePolicy implements the SimulatePolicy RPC method
+func (s *Server) SimulatePolicy(ctx context.Context, req *fairrentv1.SimulatePolicyRequest) (*fairrentv1.SimulatePolicyResponse, error) {
+	s.logger.Info("SimulatePolicy request received",
+		zap.Float64("alpha", req.Alpha),
+		zap.Int("group_weight_overrides", len(req.GroupWeights)),
+		zap.Int32("allocations", req.Allocations),
+	)
+	
+	// Process request
+	resp, err := s.scheduler.SimulatePolicy(ctx, req)
+	if err != nil {
+		s.logger.Error("Failed to simulate policy",
+			zap.Error(err),
+		)
+		return nil, statusError(err)
 	}
 	
-	if req.FinancialConstraints != nil {
-		if req.FinancialConstraints.MaxMonthlyRent <= 0 {
-			return fmt.Errorf("max_monthly_rent must be positive")
+	return resp, nil
+}
+
+// SchedulePolicy implements the SchedulePolicy RPC method
+func (s *Server) SchedulePolicy(ctx context.Context, req *fairrentv1.SchedulePolicyRequest) (*fairrentv1.PolicyVersion, error) {
+	var effectiveFrom time.Time
+	if req.EffectiveFrom != nil {
+		effectiveFrom = req.EffectiveFrom.AsTime()
+	}
+	
+	s.logger.Info("SchedulePolicy request received",
+		zap.Float64("alpha", req.Alpha),
+		zap.Int("group_weights", len(req.GroupWeights)),
+		zap.Time("effective_from", effectiveFrom),
+	)
+	
+	p, err := s.scheduler.SchedulePolicy(ctx, actor(ctx), req.Alpha, req.GroupWeights, effectiveFrom, req.Reason)
+	if err != nil {
+		s.logger.Error("Failed to schedule policy",
+			zap.Error(err),
+		)
+		return nil, statusError(err)
+	}
+	
+	return p.Proto(), nil
+}
+
+// GetPolicy implements the GetPolicy RPC method
+func (s *Server) GetPolicy(ctx context.Context, req *fairrentv1.GetPolicyRequest) (*fairrentv1.PolicyVersion, error) {
+	var (
+		p   scheduler.PolicyVersion
+		err error
+	)
+	switch {
+	case req.Version > 0:
+		p, err = s.scheduler.Policy(int(req.Version))
+	case req.At != nil:
+		p, err = s.scheduler.PolicyAt(req.At.AsTime())
+	default:
+		p, err = s.scheduler.PolicyAt(time.Now())
+	}
+	if err != nil {
+		return nil, statusError(err)
+	}
+	
+	return p.Proto(), nil
+}
+
+// ListPolicies implements the ListPolicies RPC method
+func (s *Server) ListPolicies(ctx context.Context, req *emptypb.Empty) (*fairrentv1.ListPoliciesResponse, error) {
+	versions, active := s.scheduler.Policies()
+	
+	resp := &fairrentv1.ListPoliciesResponse{
+		ActiveVersion: int32(active),
+	}
+	for _, p := range versions {
+		resp.Versions = append(resp.Versions, p.Proto())
+	}
+	
+	return resp, nil
+}
+
+// GetFairnessReport implements the GetFairnessReport RPC method
+func (s *Server) GetFairnessReport(ctx context.Context, req *fairrentv1.GetFairnessReportRequest) (*fairrentv1.FairnessReport, error) {
+	s.logger.Debug("GetFairnessReport request received",
+		zap.String("report_id", req.ReportId),
+	)
+	
+	if s.reports == nil {
+		return nil, status.Error(codes.FailedPrecondition, "fairness reports are not enabled")
+	}
+	
+	report, err := s.reports.Get(req.ReportId)
+	if err != nil {
+		s.logger.Error("Failed to get fairness report",
+			zap.Error(err),
+			zap.String("report_id", req.ReportId),
+		)
+		return nil, statusError(err)
+	}
+	
+	pb, err := report.Proto()
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return reportView(ctx, pb), nil
+}
+
+// reportView withholds the full metrics of a report from callers not allowed
+// to see them, as GetMetrics does. The signature stays verifiable over the
+// metrics digest.
+func reportView(ctx context.Context, report *fairrentv1.FairnessReport) *fairrentv1.FairnessReport {
+	if authz.FullView(ctx) {
+		return report
+	}
+	report.Metrics = metricsSummary(report.Metrics)
+	report.MetricsBytes = nil
+	return report
+}
+
+// ListFairnessReports implements the ListFairnessReports RPC method
+func (s *Server) ListFairnessReports(ctx context.Context, req *fairrentv1.ListFairnessReportsRequest) (*fairrentv1.ListFairnessReportsResponse, error) {
+	s.logger.Debug("ListFairnessReports request received")
+	
+	if s.reports == nil {
+		return nil, status.Error(codes.FailedPrecondition, "fairness reports are not enabled")
+	}
+	
+	var start, end time.Time
+	if req.StartTime != nil {
+		start = req.StartTime.AsTime()
+	}
+	if req.EndTime != nil {
+		end = req.EndTime.AsTime()
+	}
+	
+	resp := &fairrentv1.ListFairnessReportsResponse{}
+	for _, report := range s.reports.List(start, end, int(req.Limit)) {
+		pb, err := report.Proto()
+		if err != nil {
+			return nil, statusError(err)
+		}
+		resp.Reports = append(resp.Reports, reportView(ctx, pb))
+	}
+	
+	return resp, nil
+}
+
+// GetQueueCommitment implements the GetQueueCommitment RPC method
+func (s *Server) GetQueueCommitment(ctx context.Context, req *emptypb.Empty) (*fairrentv1.QueueCommitment, error) {
+	s.logger.Debug("GetQueueCommitment request received")
+	
+	resp, err := s.scheduler.GetQueueCommitment(ctx)
+	if err != nil {
+		s.logger.Error("Failed to get queue commitment",
+			zap.Error(err),
+		)
+		return nil, statusError(err)
+	}
+	
+	return resp, nil
+}
+
+// GetPositionProof implements the GetPositionProof RPC method
+func (s *Server) GetPositionProof(ctx context.Context, req *fairrentv1.GetPositionProofRequest) (*fairrentv1.PositionProof, error) {
+	if err := validation.TicketID(req.TicketId); err != nil {
+		return nil, err
+	}
+	
+	s.logger.Debug("GetPositionProof request received",
+		zap.String("ticket_id", req.TicketId.Value),
+	)
+	
+	resp, err := s.scheduler.GetPositionProof(ctx, req)
+	if err != nil {
+		s.logger.Error("Failed to prove position",
+			zap.Error(err),
+			zap.String("ticket_id", req.TicketId.Value),
+		)
+		return nil, statusError(err)
+	}
+	
+	return resp, nil
+}
+
+// GetFairnessHistory implements the GetFairnessHistory RPC method
+func (s *Server) GetFairnessHistory(ctx context.Context, req *fairrentv1.GetFairnessHistoryRequest) (*fairrentv1.GetFairnessHistoryResponse, error) {
+	s.logger.Debug("GetFairnessHistory request received",
+		zap.Duration("resolution", req.Resolution.AsDuration()),
+	)
+	
+	if s.history == nil {
+		return nil, status.Error(codes.FailedPrecondition, "fairness history is not enabled")
+	}
+	
+	var start, end time.Time
+	if req.StartTime != nil {
+		start = req.StartTime.AsTime()
+	}
+	if req.EndTime != nil {
+		end = req.EndTime.AsTime()
+	}
+	if !start.IsZero() && !end.IsZero() && end.Before(start) {
+		v := &validation.Violations{}
+		v.Add("end_time", validation.CodeInconsistent, "end_time must not be before start_time")
+		return nil, v.Err()
+	}
+	
+	resp := &fairrentv1.GetFairnessHistoryResponse{}
+	for _, point := range s.history.Query(start, end, req.Resolution.AsDuration()) {
+		resp.Points = append(resp.Points, point.Proto())
+	}
+	
+	return resp, nil
+}
+
+// WatchPosition implements the WatchPosition RPC method. The stream ends when
+// the ticket is allocated or cancelled, or when the client goes away.
+func (s *Server) WatchPosition(req *fairrentv1.WatchPositionRequest, stream fairrentv1.FairRentService_WatchPositionServer) error {
+	if err := validation.TicketID(req.TicketId); err != nil {
+		return err
+	}
+	
+	s.logger.Debug("WatchPosition request received",
+		zap.String("ticket_id", req.TicketId.Value),
+	)
+	
+	if err := s.scheduler.WatchPosition(stream.Context(), watchCaller(stream.Context()), req.TicketId.Value, stream.Send); err != nil {
+		s.logger.Error("Failed to watch position",
+			zap.Error(err),
+			zap.String("ticket_id", req.TicketId.Value),
+		)
+		return statusError(err)
+	}
+	
+	return nil
+}
+
+// watchCaller identifies the caller of a WatchPosition stream for the limit
+// on streams per caller: the token subject, or the peer address without one
+func watchCaller(ctx context.Context) string {
+	if principal, ok := auth.FromContext(ctx); ok {
+		return "sub:" + principal.Subject
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		host, _, err := net.SplitHostPort(p.Addr.String())
+		if err != nil {
+			host = p.Addr.String()
+		}
+		return "peer:" + host
+	}
+	return ""
+}
+
+// StreamEvents implements the StreamEvents RPC method. Consumers resume by
+// passing the last sequence number they processed.
+func (s *Server) StreamEvents(req *fairrentv1.StreamEventsRequest, stream fairrentv1.FairRentService_StreamEventsServer) error {
+	s.logger.Info("StreamEvents request received",
+		zap.Uint64("after_sequence", req.AfterSequence),
+		zap.Int("types", len(req.Types)),
+	)
+	
+	types := make(map[events.Type]bool, len(req.Types))
+	v := &validation.Violations{}
+	for i, t := range req.Types {
+		eventType, ok := events.TypeFromProto(t)
+		if !ok {
+			v.Add(fmt.Sprintf("types[%d]", i), validation.CodeInvalidEnum, fmt.Sprintf("unknown event type: %s", t))
+			continue
 		}
+		types[eventType] = true
+	}
+	if err := v.Err(); err != nil {
+		return err
+	}
+	
+	err := s.scheduler.Events().Stream(stream.Context(), req.AfterSequence, func(event events.Event) error {
+		if len(types) > 0 && !types[event.Type] {
+			return nil
+		}
+		return stream.Send(event.Proto())
+	})
+	if err != nil {
+		s.logger.Error("Event stream failed",
+			zap.Error(err),
+			zap.Uint64("after_sequence", req.AfterSequence),
+		)
+		return statusError(err)
 	}
 	
 	return nil
 }
+
+// statusError maps scheduler and store errors to gRPC status codes
+func statusError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	
+	code := codes.Internal
+	switch {
+	case errors.Is(err, scheduler.ErrTicketNotFound), errors.Is(err, reports.ErrReportNotFound),
+		errors.Is(err, scheduler.ErrPolicyNotFound):
+		code = codes.NotFound
+	case errors.Is(err, scheduler.ErrTicketNotQueued), errors.Is(err, scheduler.ErrQueueEmpty),
+		errors.Is(err, scheduler.ErrNoCommitment), errors.Is(err, commitment.ErrTicketNotCommitted),
+		errors.Is(err, events.ErrExpired), errors.Is(err, scheduler.ErrSchedulingPaused):
+		code = codes.FailedPrecondition
+	case errors.Is(err, scheduler.ErrDraining):
+		code = codes.Unavailable
+	case errors.Is(err, scheduler.ErrQueueFull), errors.Is(err, scheduler.ErrTooManyWatches):
+		code = codes.ResourceExhausted
+	case errors.Is(err, scheduler.ErrInvalidPolicy):
+		code = codes.InvalidArgument
+	case errors.Is(err, events.ErrAhead):
+		code = codes.OutOfRange
+	case errors.Is(err, context.Canceled):
+		code = codes.Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		code = codes.DeadlineExceeded
+	}
+	return status.Error(code, err.Error())
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/bulk"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/certs"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/fairrent/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// defaultAddr is the address of a local fairrentd
+	defaultAddr = "localhost:50051"
+
+	// tokenEnv names the environment variable read for the bearer token
+	tokenEnv = "FAIRRENT_TOKEN"
+
+	// importChunkSize is the size of ImportQueue messages
+	importChunkSize = 1 << 20
+)
+
+// runImport implements `fairrentd import`: it streams a file of tickets
+// from another waitlist to a running fairrentd, keeping their enqueue times,
+// and reports every rejected record
+func runImport(args []string) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	addr := fs.String("addr", defaultAddr, "fairrentd gRPC address")
+	formatName := fs.String("format", "", "File format: json, ndjson or csv (default from the file extension)")
+	dryRun := fs.Bool("dry-run", false, "Validate the file without importing it")
+	token := fs.String("token", "", "Bearer token of an admin (default $"+tokenEnv+")")
+	tlsOpts := addTLSFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: fairrentd import [-addr host:port] [-format json|ndjson|csv] [-dry-run] [-token token] [-tls ...] <file>")
+		return 2
+	}
+
+	path := fs.Arg(0)
+	var format bulk.Format
+	var err error
+	if *formatName != "" {
+		format, err = bulk.ParseFormat(*formatName)
+	} else {
+		format, err = bulk.FormatOf(path)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open import: %v\n", err)
+		return 1
+	}
+	defer f.Close()
+
+	client, conn, err := dialFairRent(*addr, tlsOpts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer conn.Close()
+
+	stream, err := client.ImportQueue(withToken(context.Background(), *token))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import failed: %v\n", err)
+		return 1
+	}
+
+	// The first message carries the options, even for an empty file
+	first := true
+	buf := make([]byte, importChunkSize)
+	for {
+		n, err := io.ReadFull(f, buf)
+		if n > 0 || first {
+			req := &fairrentv1.ImportQueueRequest{Data: append([]byte(nil), buf[:n]...)}
+			if first {
+				req.Format = format.Proto()
+				req.DryRun = *dryRun
+				first = false
+			}
+			if sendErr := stream.Send(req); sendErr != nil {
+				// The server's status is reported by CloseAndRecv
+				break
+			}
+		}
+		if err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to read import: %v\n", err)
+			return 1
+		}
+	}
+
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import failed: %v\n", err)
+		return 1
+	}
+
+	for _, rejected := range resp.RejectedRecords {
+		fmt.Fprintf(os.Stderr, "record %d", rejected.Record)
+		if rejected.TicketId != "" {
+			fmt.Fprintf(os.Stderr, " (ticket %s)", rejected.TicketId)
+		}
+		fmt.Fprintln(os.Stderr, ":")
+		for _, detail := range rejected.Errors {
+			fmt.Fprintf(os.Stderr, "  %s: %s [%s]\n", detail.Field, detail.Message, detail.Code)
+		}
+	}
+
+	verb := "imported"
+	if resp.DryRun {
+		verb = "would import"
+	}
+	fmt.Printf("%d records: %s %d, rejected %d\n", resp.TotalRecords, verb, resp.Imported, resp.Rejected)
+	if resp.Rejected > 0 {
+		return 1
+	}
+	return 0
+}
+
+// runExport implements `fairrentd export`: it writes the queue of a running
+// fairrentd, in service order, to a file or stdout
+func runExport(args []string) int {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	addr := fs.String("addr", defaultAddr, "fairrentd gRPC address")
+	formatName := fs.String("format", "", "File format: json, ndjson or csv (default from the output extension, else json)")
+	output := fs.String("o", "", "Output file (default stdout)")
+	includeAllocated := fs.Bool("include-allocated", false, "Also export tickets allocated since the server started")
+	token := fs.String("token", "", "Bearer token of an admin (default $"+tokenEnv+")")
+	tlsOpts := addTLSFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 0 {
+		fmt.Fprintln(os.Stderr, "usage: fairrentd export [-addr host:port] [-format json|ndjson|csv] [-o path] [-include-allocated] [-token token] [-tls ...]")
+		return 2
+	}
+
+	format := bulk.FormatJSON
+	var err error
+	switch {
+	case *formatName != "":
+		format, err = bulk.ParseFormat(*formatName)
+	case *output != "":
+		format, err = bulk.FormatOf(*output)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	client, conn, err := dialFairRent(*addr, tlsOpts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer conn.Close()
+
+	stream, err := client.ExportQueue(withToken(context.Background(), *token), &fairrentv1.ExportQueueRequest{
+		Format:           format.Proto(),
+		IncludeAllocated: *includeAllocated,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "export failed: %v\n", err)
+		return 1
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to create output: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		out = f
+	}
+
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			return 0
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "export failed: %v\n", err)
+			return 1
+		}
+		if _, err := out.Write(chunk.Data); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to write export: %v\n", err)
+			return 1
+		}
+	}
+}
+
+// tlsFlags are the flags for connecting to fairrentd over TLS
+type tlsFlags struct {
+	enabled    *bool
+	caFile     *string
+	certFile   *string
+	keyFile    *string
+	serverName *string
+}
+
+// addTLSFlags defines the TLS flags on fs
+func addTLSFlags(fs *flag.FlagSet) *tlsFlags {
+	return &tlsFlags{
+		enabled:    fs.Bool("tls", false, "Connect over TLS (implied by the other TLS flags)"),
+		caFile:     fs.String("ca-cert", "", "PEM CA bundle verifying the server (default the system roots)"),
+		certFile:   fs.String("cert", "", "PEM client certificate, for servers requiring one"),
+		keyFile:    fs.String("key", "", "PEM client key"),
+		serverName: fs.String("server-name", "", "Name expected in the server certificate (default the host of -addr)"),
+	}
+}
+
+// credentials returns the transport credentials the flags ask for
+func (f *tlsFlags) credentials() (credentials.TransportCredentials, error) {
+	if !*f.enabled && *f.caFile == "" && *f.certFile == "" && *f.keyFile == "" && *f.serverName == "" {
+		return insecure.NewCredentials(), nil
+	}
+	cfg, err := certs.ClientConfig(*f.caFile, *f.certFile, *f.keyFile, *f.serverName)
+	if err != nil {
+		return nil, err
+	}
+	return credentials.NewTLS(cfg), nil
+}
+
+// dialFairRent connects to a running fairrentd
+func dialFairRent(addr string, tlsOpts *tlsFlags) (fairrentv1.FairRentServiceClient, *grpc.ClientConn, error) {
+	creds, err := tlsOpts.credentials()
+	if err != nil {
+		return nil, nil, err
+	}
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	return fairrentv1.NewFairRentServiceClient(conn), conn, nil
+}
+
+// withToken attaches the bearer token, if any, to outgoing calls
+func withToken(ctx context.Context, token string) context.Context {
+	if token == "" {
+		token = os.Getenv(tokenEnv)
+	}
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+}
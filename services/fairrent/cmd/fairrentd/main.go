@@ -4,55 +4,214 @@ import (
 	"context"
 	"flag"
 	"fmt"
-	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/wohnfair/wohnfair/services/fairrent/api"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/audit"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/auth"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/authz"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/certs"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/config"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/events"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/history"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/ops"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/pii"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/ratelimit"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/reports"
 	"github.com/wohnfair/wohnfair/services/fairrent/internal/scheduler"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/slo"
 	"github.com/wohnfair/wohnfair/services/fairrent/internal/telemetry"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
 var (
-	port     = flag.Int("port", 50051, "gRPC server port")
-	logLevel = flag.String("log-level", "info", "Log level (debug, info, warn, error)")
+	port     = flag.Int("port", 50051, "gRPC server port, overriding service.port")
+	logLevel = flag.String("log-level", "info", "Log level (debug, info, warn, error), overriding logging.level")
 	configFile = flag.String("config", "", "Configuration file path")
 )
 
 func main() {
+	// Operator subcommands
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "pseudonymize":
+			os.Exit(runPseudonymize(os.Args[2:]))
+		case "reidentify":
+			os.Exit(runReidentify(os.Args[2:]))
+		case "import":
+			os.Exit(runImport(os.Args[2:]))
+		case "export":
+			os.Exit(runExport(os.Args[2:]))
+		}
+	}
+
 	flag.Parse()
 
+	// Load configuration
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		os.Exit(1)
+	}
+
 	// Initialize logger
-	logger := initLogger()
+	logger := initLogger(cfg.Logging, cfg.Development.Debug)
 	defer logger.Sync()
 
 	logger.Info("Starting FairRent service",
-		zap.Int("port", *port),
-		zap.String("log_level", *logLevel),
+		zap.Int("port", cfg.Service.Port),
+		zap.String("log_level", cfg.Logging.Level),
+		zap.String("config", *configFile),
 	)
 
+	// Pseudonymise user identifiers in every log entry from here on
+	logger, err = withPseudonymisation(cfg.Logging.PII, logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize log pseudonymisation", zap.Error(err))
+	}
+
 	// Initialize telemetry
-	if err := telemetry.InitTracer("fairrent", "0.1.0"); err != nil {
+	shutdownTracer, err := telemetry.InitTracer(context.Background(), cfg.Telemetry.Tracing, cfg.Service.Name, cfg.Service.Version)
+	if err != nil {
 		logger.Fatal("Failed to initialize tracer", zap.Error(err))
 	}
+	if cfg.Telemetry.Tracing.Enabled {
+		logger.Info("Tracing enabled",
+			zap.String("exporter", cfg.Telemetry.Tracing.Exporter),
+			zap.String("endpoint", cfg.Telemetry.Tracing.Endpoint),
+			zap.Float64("sample_rate", cfg.Telemetry.Tracing.SampleRate),
+		)
+	}
 
-	// Load configuration
-	config := scheduler.DefaultConfig()
-	if *configFile != "" {
-		if err := loadConfig(*configFile, config); err != nil {
-			logger.Fatal("Failed to load configuration", zap.Error(err))
-		}
+	// Open audit log
+	auditLog, err := audit.Open(cfg.Audit.Path)
+	if err != nil {
+		logger.Fatal("Failed to open audit log", zap.Error(err))
 	}
+	defer auditLog.Close()
+
+	// Open event feed
+	eventFeed, err := events.Open(cfg.Events.Path, cfg.Events.Capacity)
+	if err != nil {
+		logger.Fatal("Failed to open event feed", zap.Error(err))
+	}
+	defer eventFeed.Close()
 
 	// Create scheduler
-	scheduler := scheduler.NewFairRent(config, logger)
+	cfg.Scheduler.AuditLog = auditLog
+	cfg.Scheduler.EventFeed = eventFeed
+	cfg.Scheduler.Registerer = prometheus.DefaultRegisterer
+	scheduler := scheduler.NewFairRent(&cfg.Scheduler, logger)
+
+	// Background jobs run until shutdown
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	// Apply fairness policy changes in the config file without a restart
+	if cfg.Development.HotReload && *configFile != "" {
+		go watchConfig(jobsCtx, *configFile, cfg, scheduler, logger)
+		logger.Info("Config hot reload enabled", zap.String("config", *configFile))
+	}
+
+	serverOpts := []api.ServerOption{
+		api.WithValidation(cfg.Security.Validation),
+		api.WithHost(cfg.Service.Host),
+	}
+
+	// Start signed fairness reports
+	if cfg.Reports.Enabled {
+		publisher, err := newReportPublisher(cfg.Reports, scheduler.GetMetrics, auditLog, logger)
+		if err != nil {
+			logger.Fatal("Failed to initialize fairness reports", zap.Error(err))
+		}
+		go publisher.Run(jobsCtx, cfg.Reports.Interval)
+		serverOpts = append(serverOpts, api.WithReports(publisher.Store()))
+	}
+
+	// Start queue commitments
+	if cfg.Commitments.Enabled {
+		go runQueueCommitments(jobsCtx, scheduler, cfg.Commitments.Interval, logger)
+	}
+
+	// Start fairness SLO monitoring
+	if cfg.SLO.Enabled {
+		monitor := slo.NewMonitor(cfg.SLO.Objectives, scheduler.GetMetrics, auditLog, prometheus.DefaultRegisterer, logger)
+		go monitor.Run(jobsCtx, cfg.SLO.Interval)
+	}
+
+	// Start fairness history recording
+	if cfg.Metrics.Custom.Enabled {
+		store, err := history.NewStore(cfg.Metrics.Custom.Path, cfg.Metrics.Custom.RetentionPeriod)
+		if err != nil {
+			logger.Fatal("Failed to open fairness history", zap.Error(err))
+		}
+		defer store.Close()
+
+		collector := history.NewCollector(scheduler.GetMetrics, store, logger)
+		go collector.Run(jobsCtx, cfg.Metrics.Custom.CollectionInterval)
+		serverOpts = append(serverOpts, api.WithHistory(store))
+	}
+
+	// Serve over TLS, reloading rotated certificates
+	if cfg.Security.TLS.Enabled {
+		reloader, err := certs.NewReloader(cfg.Security.TLS, logger)
+		if err != nil {
+			logger.Fatal("Failed to load TLS certificate", zap.Error(err))
+		}
+		go reloader.Run(jobsCtx)
+		serverOpts = append(serverOpts, api.WithTLS(reloader.ServerConfig()))
+		logger.Info("TLS enabled",
+			zap.String("cert_file", cfg.Security.TLS.CertFile),
+			zap.Bool("client_certificates", cfg.Security.TLS.ClientCAFile != ""),
+		)
+	} else {
+		logger.Warn("TLS disabled, gRPC traffic is not encrypted")
+	}
+
+	// Require bearer tokens
+	if cfg.Security.Auth.Enabled && cfg.Security.Auth.Type == auth.TypeJWT {
+		authenticator, err := auth.NewAuthenticator(cfg.Security.Auth, logger)
+		if err != nil {
+			logger.Fatal("Failed to initialize authentication", zap.Error(err))
+		}
+		serverOpts = append(serverOpts, api.WithAuthenticator(authenticator))
+		logger.Info("Authentication enabled", zap.String("issuer", cfg.Security.Auth.Issuer))
+	} else {
+		logger.Warn("Authentication disabled, every caller can use the gRPC API")
+	}
+
+	// Limit call rates, per principal once callers are authenticated
+	if cfg.Performance.RateLimit.Enabled {
+		limiter := ratelimit.NewLimiter(cfg.Performance.RateLimit, prometheus.DefaultRegisterer, logger)
+		serverOpts = append(serverOpts, api.WithRateLimiter(limiter))
+		logger.Info("Rate limiting enabled",
+			zap.Float64("requests_per_second", cfg.Performance.RateLimit.RequestsPerSecond),
+			zap.Int("burst_size", cfg.Performance.RateLimit.BurstSize),
+		)
+	}
+
+	// Check calls against the role matrix
+	if cfg.Security.Authorization.Enabled {
+		policy, err := authz.LoadPolicy(cfg.Security.Authorization.PolicyFile)
+		if err != nil {
+			logger.Fatal("Failed to load authorization policy", zap.Error(err))
+		}
+		authorizer := authz.NewAuthorizer(policy, scheduler.TicketOwner, auditLog, logger)
+		serverOpts = append(serverOpts, api.WithAuthorizer(authorizer))
+		logger.Info("Authorization enabled",
+			zap.String("policy_file", cfg.Security.Authorization.PolicyFile),
+			zap.Int("methods", len(policy.Methods)),
+		)
+	}
 
 	// Create and start server
-	server := api.NewServer(scheduler, logger, *port)
+	server := api.NewServer(scheduler, logger, cfg.Service.Port, serverOpts...)
 
 	// Start server in goroutine
 	go func() {
@@ -61,8 +220,11 @@ func main() {
 		}
 	}()
 
-	// Start metrics server
-	go startMetricsServer(logger)
+	// Start ops HTTP server
+	opsServer, err := startOpsServer(cfg, scheduler, logger)
+	if err != nil {
+		logger.Fatal("Failed to start ops server", zap.Error(err))
+	}
 
 	// Wait for interrupt signal
 	quit := make(chan os.Signal, 1)
@@ -75,15 +237,23 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	scheduler.MarkNotReady("shutting down")
+	stopJobs()
 	server.Stop()
+	if err := opsServer.Shutdown(ctx); err != nil {
+		logger.Error("Failed to stop ops server", zap.Error(err))
+	}
+	if err := shutdownTracer(ctx); err != nil {
+		logger.Error("Failed to flush traces", zap.Error(err))
+	}
 
 	logger.Info("FairRent service stopped")
 }
 
 // initLogger initializes the logger
-func initLogger() *zap.Logger {
+func initLogger(cfg config.LoggingConfig, debug bool) *zap.Logger {
 	var level zapcore.Level
-	switch *logLevel {
+	switch cfg.Level {
 	case "debug":
 		level = zapcore.DebugLevel
 	case "info":
@@ -95,9 +265,14 @@ func initLogger() *zap.Logger {
 	default:
 		level = zapcore.InfoLevel
 	}
+	if debug {
+		level = zapcore.DebugLevel
+	}
 
 	config := zap.NewProductionConfig()
 	config.Level = zap.NewAtomicLevelAt(level)
+	config.Encoding = cfg.Format
+	config.OutputPaths = []string{cfg.Output}
 	config.EncoderConfig.TimeKey = "timestamp"
 	config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
 
@@ -110,29 +285,121 @@ func initLogger() *zap.Logger {
 	return logger
 }
 
-// loadConfig loads configuration from file
-func loadConfig(configFile string, config *scheduler.Config) error {
-	// TODO: Implement configuration loading from YAML/JSON
-	// For now, just log that we're using defaults
-	return nil
+// withPseudonymisation wraps logger so that user identifiers are
+// pseudonymised and sensitive request fields redacted
+func withPseudonymisation(cfg config.PIIConfig, logger *zap.Logger) (*zap.Logger, error) {
+	var key []byte
+	var err error
+	if cfg.KeyFile != "" {
+		key, err = pii.LoadOrCreateKey(cfg.KeyFile)
+	} else {
+		logger.Warn("No pseudonymisation key configured, using an ephemeral key; logged user IDs cannot be re-identified")
+		key, err = pii.GenerateKey()
+	}
+	if err != nil {
+		return logger, err
+	}
+
+	p, err := pii.NewPseudonymizer(key)
+	if err != nil {
+		return logger, err
+	}
+	return pii.WrapLogger(logger, p), nil
 }
 
-// startMetricsServer starts the Prometheus metrics server
-func startMetricsServer(logger *zap.Logger) {
-	// This would typically run on a different port
-	// For now, we'll just log that metrics are available via gRPC
-	logger.Info("Prometheus metrics available via gRPC server")
-	
-	// In a real deployment, you might want a separate HTTP server for metrics
-	// mux := http.NewServeMux()
-	// mux.Handle("/metrics", promhttp.Handler())
-	// 
-	// server := &http.Server{
-	//     Addr:    ":9090",
-	//     Handler: mux,
-	// }
-	// 
-	// if err := server.ListenAndServe(); err != nil {
-	//     logger.Error("Metrics server failed", zap.Error(err))
-	// }
+// loadConfig loads configuration from file and environment, falling back to
+// defaults. Flags given on the command line take precedence.
+func loadConfig(configFile string) (*config.Config, error) {
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		return nil, err
+	}
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "port":
+			cfg.Service.Port = *port
+		case "log-level":
+			cfg.Logging.Level = *logLevel
+		}
+	})
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// newReportPublisher sets up signing and storage for fairness reports
+func newReportPublisher(cfg config.ReportsConfig, snapshot reports.SnapshotFunc, auditLog *audit.Log, logger *zap.Logger) (*reports.Publisher, error) {
+	var signer *reports.Signer
+	var err error
+	if cfg.SigningKeyFile != "" {
+		signer, err = reports.LoadOrCreateSigner(cfg.SigningKeyFile)
+	} else {
+		logger.Warn("No report signing key configured, using an ephemeral key")
+		signer, err = reports.GenerateSigner()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := reports.NewStore(cfg.Directory, cfg.Retention)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Fairness reports enabled",
+		zap.Duration("interval", cfg.Interval),
+		zap.String("key_id", signer.KeyID()),
+	)
+
+	return reports.NewPublisher(snapshot, signer, auditLog, store, logger), nil
+}
+
+// runQueueCommitments publishes a queue commitment every interval until the
+// context is cancelled
+func runQueueCommitments(ctx context.Context, fr *scheduler.FairRent, interval time.Duration, logger *zap.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := fr.CommitQueue(); err != nil {
+			logger.Error("Failed to publish queue commitment", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// startOpsServer serves metrics, health, readiness and profiling endpoints
+// as configured
+func startOpsServer(cfg *config.Config, fr *scheduler.FairRent, logger *zap.Logger) (*ops.Server, error) {
+	server := ops.NewServer(logger)
+
+	if cfg.Metrics.Prometheus.Enabled {
+		server.Handle(cfg.Metrics.Prometheus.Port, cfg.Metrics.Prometheus.Path,
+			promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{}))
+	}
+
+	if cfg.Health.Enabled {
+		server.Handle(cfg.Health.Port, cfg.Health.Path, ops.HealthHandler("fairrent"))
+		server.Handle(cfg.Health.Port, "/readyz", ops.ReadinessHandler(cfg.Health.Timeout, map[string]ops.ReadinessCheck{
+			"scheduler": fr.Ready,
+		}))
+	}
+
+	if cfg.Development.Profiling.Enabled {
+		logger.Warn("pprof endpoints enabled",
+			zap.String("host", cfg.Development.Profiling.Host),
+			zap.Int("port", cfg.Development.Profiling.Port))
+		server.HandlePprof(cfg.Development.Profiling.Host, cfg.Development.Profiling.Port)
+	}
+
+	if err := server.Start(); err != nil {
+		return nil, err
+	}
+	return server, nil
 }
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/pii"
+)
+
+// defaultPIIKeyFile matches logging.pii.key_file in config/config.yaml
+const defaultPIIKeyFile = "keys/pii-pseudonym.key"
+
+// runPseudonymize implements `fairrentd pseudonymize`: it prints the
+// pseudonym of each user ID given, e.g. to find a user's entries in the logs
+func runPseudonymize(args []string) int {
+	fs := flag.NewFlagSet("pseudonymize", flag.ContinueOnError)
+	keyFile := fs.String("key-file", defaultPIIKeyFile, "Pseudonymisation key file")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: fairrentd pseudonymize [-key-file path] <user_id>...")
+		return 2
+	}
+
+	p, err := loadPseudonymizer(*keyFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	for _, id := range fs.Args() {
+		fmt.Printf("%s\t%s\n", id, p.Pseudonym(id))
+	}
+	return 0
+}
+
+// runReidentify implements `fairrentd reidentify`: it finds the user ID
+// behind a logged pseudonym among candidates, one per line, read from a
+// file or stdin
+func runReidentify(args []string) int {
+	fs := flag.NewFlagSet("reidentify", flag.ContinueOnError)
+	keyFile := fs.String("key-file", defaultPIIKeyFile, "Pseudonymisation key file")
+	candidatesFile := fs.String("candidates", "", "File with one candidate user ID per line (default stdin)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: fairrentd reidentify [-key-file path] [-candidates path] <pseudonym>")
+		return 2
+	}
+
+	p, err := loadPseudonymizer(*keyFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	var in io.Reader = os.Stdin
+	if *candidatesFile != "" {
+		f, err := os.Open(*candidatesFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to open candidates: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		in = f
+	}
+
+	pseudonym := fs.Arg(0)
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		id := strings.TrimSpace(scanner.Text())
+		if id != "" && p.Matches(pseudonym, id) {
+			fmt.Println(id)
+			return 0
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read candidates: %v\n", err)
+		return 1
+	}
+
+	fmt.Fprintln(os.Stderr, "no candidate matches the pseudonym")
+	return 1
+}
+
+// loadPseudonymizer loads the key used by a running fairrentd
+func loadPseudonymizer(keyFile string) (*pii.Pseudonymizer, error) {
+	key, err := pii.LoadKey(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	return pii.NewPseudonymizer(key)
+}
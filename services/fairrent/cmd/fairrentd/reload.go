@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+	"time"
+
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/config"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/scheduler"
+	"go.uber.org/zap"
+)
+
+// configReloadInterval is how often the config file is checked for changes
+const configReloadInterval = 5 * time.Second
+
+// configReloadActor names config reloads in the audit log
+const configReloadActor = "config-reload"
+
+// watchConfig applies changes to α and the group weights in the config file
+// while fairrentd runs, each as a new policy version. The file is checked
+// every configReloadInterval and reloaded on SIGHUP.
+func watchConfig(ctx context.Context, path string, running *config.Config, fr *scheduler.FairRent, logger *zap.Logger) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(configReloadInterval)
+	defer ticker.Stop()
+
+	modTime := fileModTime(path)
+	loaded := running
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			modTime = fileModTime(path)
+		case <-ticker.C:
+			current := fileModTime(path)
+			if current.Equal(modTime) {
+				continue
+			}
+			modTime = current
+		}
+		loaded = reloadConfig(path, running, loaded, fr, logger)
+	}
+}
+
+// reloadConfig loads the config file and applies its fairness policy if it
+// differs from the one loaded before, so that editing other settings does not
+// override policy versions scheduled through the API. It returns the
+// configuration loaded last. Invalid files are logged and leave the running
+// policy in place.
+func reloadConfig(path string, running, loaded *config.Config, fr *scheduler.FairRent, logger *zap.Logger) *config.Config {
+	cfg, err := loadConfig(path)
+	if err != nil {
+		logger.Error("Ignoring invalid configuration", zap.String("config", path), zap.Error(err))
+		return loaded
+	}
+	if restartRequired(running, cfg) {
+		logger.Warn("Configuration changes other than scheduler.alpha and scheduler.group_weights take effect after a restart",
+			zap.String("config", path),
+		)
+	}
+	if cfg.Scheduler.Alpha == loaded.Scheduler.Alpha && reflect.DeepEqual(cfg.Scheduler.GroupWeights, loaded.Scheduler.GroupWeights) {
+		return cfg
+	}
+
+	changed, err := fr.SetPolicy(configReloadActor, cfg.Scheduler.Alpha, cfg.Scheduler.GroupWeights, "configuration reload")
+	if err != nil {
+		logger.Error("Failed to apply fairness policy", zap.String("config", path), zap.Error(err))
+		return loaded
+	}
+	if changed {
+		logger.Info("Fairness policy reloaded",
+			zap.String("config", path),
+			zap.Float64("alpha", cfg.Scheduler.Alpha),
+		)
+	}
+	return cfg
+}
+
+// restartRequired reports whether two configurations differ in more than the
+// settings applied on reload
+func restartRequired(running, loaded *config.Config) bool {
+	a, b := *running, *loaded
+	for _, c := range []*config.Config{&a, &b} {
+		c.Scheduler.Alpha = 0
+		c.Scheduler.GroupWeights = nil
+		c.Scheduler.AuditLog = nil
+		c.Scheduler.EventFeed = nil
+		c.Scheduler.Registerer = nil
+	}
+	return !reflect.DeepEqual(a, b)
+}
+
+// fileModTime returns when a file was last modified, or the zero time
+func fileModTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,219 @@
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GenesisHash is the previous-hash value of the first record in a log
+var GenesisHash = strings.Repeat("0", sha256.Size*2)
+
+// ErrRecordNotFound is returned when a sequence number is not in the log
+var ErrRecordNotFound = errors.New("audit record not found")
+
+// Record is a single entry of the hash-chained audit log
+type Record struct {
+	Sequence  uint64          `json:"sequence"`
+	Timestamp time.Time       `json:"timestamp"`
+	Type      string          `json:"type"`
+	Actor     string          `json:"actor,omitempty"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	PrevHash  string          `json:"prev_hash"`
+	Hash      string          `json:"hash"`
+}
+
+// Log is an append-only audit log in which every record commits to its
+// predecessor, so that any later modification breaks the chain
+type Log struct {
+	mu sync.RWMutex
+
+	records []Record
+	head    string
+
+	// Optional file backing, one JSON record per line
+	file *os.File
+}
+
+// NewLog creates an in-memory audit log
+func NewLog() *Log {
+	return &Log{head: GenesisHash}
+}
+
+// Open opens a file-backed audit log, verifying any records already in the
+// file. An empty path returns an in-memory log.
+func Open(path string) (*Log, error) {
+	l := NewLog()
+	if path == "" {
+		return l, nil
+	}
+
+	if existing, err := os.Open(path); err == nil {
+		scanner := bufio.NewScanner(existing)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			var record Record
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				existing.Close()
+				return nil, fmt.Errorf("failed to parse audit record %d: %w", len(l.records)+1, err)
+			}
+			l.records = append(l.records, record)
+		}
+		existing.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read audit log: %w", err)
+		}
+		if err := l.Verify(); err != nil {
+			return nil, err
+		}
+		if n := len(l.records); n > 0 {
+			l.head = l.records[n-1].Hash
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log for writing: %w", err)
+	}
+	l.file = file
+
+	return l, nil
+}
+
+// Append adds a record to the log and returns it with its sequence and hash
+func (l *Log) Append(recordType, actor string, payload interface{}) (Record, error) {
+	var raw json.RawMessage
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return Record{}, fmt.Errorf("failed to encode audit payload: %w", err)
+		}
+		raw = data
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	record := Record{
+		Sequence:  uint64(len(l.records)) + 1,
+		Timestamp: time.Now().UTC(),
+		Type:      recordType,
+		Actor:     actor,
+		Payload:   raw,
+		PrevHash:  l.head,
+	}
+	record.Hash = hashRecord(record)
+
+	if l.file != nil {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return Record{}, fmt.Errorf("failed to encode audit record: %w", err)
+		}
+		if _, err := l.file.Write(append(line, '\n')); err != nil {
+			return Record{}, fmt.Errorf("failed to write audit record: %w", err)
+		}
+		if err := l.file.Sync(); err != nil {
+			return Record{}, fmt.Errorf("failed to sync audit log: %w", err)
+		}
+	}
+
+	l.records = append(l.records, record)
+	l.head = record.Hash
+
+	return record, nil
+}
+
+// Head returns the sequence number and hash of the latest record. An empty
+// log has sequence 0 and the genesis hash.
+func (l *Log) Head() (uint64, string) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return uint64(len(l.records)), l.head
+}
+
+// Get returns the record with the given sequence number
+func (l *Log) Get(sequence uint64) (Record, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if sequence == 0 || sequence > uint64(len(l.records)) {
+		return Record{}, ErrRecordNotFound
+	}
+	return l.records[sequence-1], nil
+}
+
+// Records returns a copy of the records with sequence numbers in [from, to]
+func (l *Log) Records(from, to uint64) []Record {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if from == 0 {
+		from = 1
+	}
+	if to == 0 || to > uint64(len(l.records)) {
+		to = uint64(len(l.records))
+	}
+	if from > to {
+		return nil
+	}
+
+	records := make([]Record, to-from+1)
+	copy(records, l.records[from-1:to])
+	return records
+}
+
+// Verify recomputes the hash chain and reports the first broken link
+func (l *Log) Verify() error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return VerifyChain(l.records)
+}
+
+// Close closes the backing file, if any
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// VerifyChain checks that records form an unbroken chain from the genesis hash
+func VerifyChain(records []Record) error {
+	prev := GenesisHash
+	for i, record := range records {
+		if record.Sequence != uint64(i)+1 {
+			return fmt.Errorf("audit record %d has sequence %d", i+1, record.Sequence)
+		}
+		if record.PrevHash != prev {
+			return fmt.Errorf("audit record %d does not link to its predecessor", record.Sequence)
+		}
+		if hashRecord(record) != record.Hash {
+			return fmt.Errorf("audit record %d has been modified", record.Sequence)
+		}
+		prev = record.Hash
+	}
+	return nil
+}
+
+// hashRecord computes SHA-256 over the previous hash and the record contents
+func hashRecord(record Record) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%d\n%d\n%s\n%s\n", record.PrevHash, record.Sequence,
+		record.Timestamp.UnixNano(), record.Type, record.Actor)
+	h.Write(record.Payload)
+	return hex.EncodeToString(h.Sum(nil))
+}
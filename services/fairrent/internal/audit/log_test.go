@@ -0,0 +1,83 @@
+package audit
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLog_AppendAndVerify(t *testing.T) {
+	l := NewLog()
+
+	seq, head := l.Head()
+	assert.Equal(t, uint64(0), seq)
+	assert.Equal(t, GenesisHash, head)
+
+	first, err := l.Append("ticket_enqueued", "caseworker-1", map[string]string{"ticket_id": "TKT_1"})
+	require.NoError(t, err)
+	second, err := l.Append("ticket_allocated", "", nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(1), first.Sequence)
+	assert.Equal(t, GenesisHash, first.PrevHash)
+	assert.Equal(t, first.Hash, second.PrevHash)
+
+	seq, head = l.Head()
+	assert.Equal(t, uint64(2), seq)
+	assert.Equal(t, second.Hash, head)
+	require.NoError(t, l.Verify())
+
+	got, err := l.Get(1)
+	require.NoError(t, err)
+	assert.Equal(t, first, got)
+
+	_, err = l.Get(3)
+	assert.ErrorIs(t, err, ErrRecordNotFound)
+}
+
+func TestVerifyChain_DetectsTampering(t *testing.T) {
+	l := NewLog()
+	_, err := l.Append("report", "", map[string]float64{"gini": 0.1})
+	require.NoError(t, err)
+	_, err = l.Append("report", "", map[string]float64{"gini": 0.2})
+	require.NoError(t, err)
+
+	records := l.Records(0, 0)
+	require.Len(t, records, 2)
+
+	records[0].Payload = json.RawMessage(`{"gini":0.0}`)
+	assert.Error(t, VerifyChain(records))
+}
+
+func TestOpen_ReloadsAndContinuesChain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	l, err := Open(path)
+	require.NoError(t, err)
+	first, err := l.Append("report", "", nil)
+	require.NoError(t, err)
+	require.NoError(t, l.Close())
+
+	l, err = Open(path)
+	require.NoError(t, err)
+	seq, head := l.Head()
+	assert.Equal(t, uint64(1), seq)
+	assert.Equal(t, first.Hash, head)
+
+	second, err := l.Append("report", "", nil)
+	require.NoError(t, err)
+	assert.Equal(t, first.Hash, second.PrevHash)
+	require.NoError(t, l.Close())
+
+	// Rewriting the file breaks the chain on the next open
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, append([]byte(`{"sequence":1,"type":"forged","prev_hash":"`+GenesisHash+`"}`+"\n"), data...), 0o600))
+
+	_, err = Open(path)
+	assert.Error(t, err)
+}
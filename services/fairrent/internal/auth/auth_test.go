@@ -0,0 +1,222 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const testIssuer = "https://id.example.org/realms/wohnfair"
+
+// testKeys holds an RSA and an EC signing key published in a JWKS file
+type testKeys struct {
+	rsa  *rsa.PrivateKey
+	ec   *ecdsa.PrivateKey
+	jwks string
+}
+
+func newTestKeys(t *testing.T) *testKeys {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	b64 := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	doc := map[string]interface{}{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa1", "use": "sig", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{"kty": "EC", "kid": "ec1", "crv": "P-256", "x": b64(ecKey.X.FillBytes(make([]byte, 32))), "y": b64(ecKey.Y.FillBytes(make([]byte, 32)))},
+	}}
+	data, err := json.Marshal(doc)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+
+	return &testKeys{rsa: rsaKey, ec: ecKey, jwks: path}
+}
+
+// sign creates a compact JWS with the given algorithm and key ID
+func (k *testKeys) sign(t *testing.T, alg, kid string, claims map[string]interface{}) string {
+	header, err := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	require.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var sig []byte
+	switch alg {
+	case "RS256":
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k.rsa, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+	case "ES256":
+		r, s, err := ecdsa.Sign(rand.Reader, k.ec, digest[:])
+		require.NoError(t, err)
+		sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"iss":     testIssuer,
+		"sub":     "subject-1",
+		"aud":     []string{"fairrent"},
+		"exp":     time.Now().Add(time.Hour).Unix(),
+		"user_id": "user123",
+		"realm_access": map[string]interface{}{
+			"roles": []string{"housing-caseworker", "applicant", "offline_access"},
+		},
+	}
+}
+
+func TestVerifier(t *testing.T) {
+	keys := newTestKeys(t)
+	verifier := NewVerifier(NewKeySet(keys.jwks, "", 0), testIssuer, "fairrent", time.Second)
+	ctx := context.Background()
+
+	for _, alg := range []struct{ alg, kid string }{{"RS256", "rsa1"}, {"ES256", "ec1"}} {
+		claims, err := verifier.Verify(ctx, keys.sign(t, alg.alg, alg.kid, validClaims()))
+		require.NoError(t, err, alg.alg)
+		assert.Equal(t, "subject-1", claims.Subject)
+	}
+
+	expired := validClaims()
+	expired["exp"] = time.Now().Add(-time.Minute).Unix()
+	wrongIssuer := validClaims()
+	wrongIssuer["iss"] = "https://evil.example.org"
+	wrongAudience := validClaims()
+	wrongAudience["aud"] = "other-service"
+
+	for name, token := range map[string]string{
+		"expired":        keys.sign(t, "RS256", "rsa1", expired),
+		"wrong issuer":   keys.sign(t, "RS256", "rsa1", wrongIssuer),
+		"wrong audience": keys.sign(t, "RS256", "rsa1", wrongAudience),
+		"unknown key":    keys.sign(t, "RS256", "rsa2", validClaims()),
+		"key mismatch":   keys.sign(t, "RS256", "ec1", validClaims()),
+		"alg none":       base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + ".e30.",
+		"malformed":      "not-a-token",
+	} {
+		_, err := verifier.Verify(ctx, token)
+		assert.ErrorIs(t, err, ErrInvalidToken, name)
+	}
+
+	// A tampered payload breaks the signature
+	token := keys.sign(t, "RS256", "rsa1", validClaims())
+	tampered := validClaims()
+	tampered["user_id"] = "someone-else"
+	payload, _ := json.Marshal(tampered)
+	parts := strings.Split(token, ".")
+	_, err := verifier.Verify(ctx, parts[0]+"."+base64.RawURLEncoding.EncodeToString(payload)+"."+parts[2])
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestAuthenticator_Interceptor(t *testing.T) {
+	keys := newTestKeys(t)
+	cfg := DefaultConfig()
+	cfg.Enabled = true
+	cfg.Issuer = testIssuer
+	cfg.JWKS = keys.jwks
+	cfg.UserIDClaim = "user_id"
+	cfg.RolesClaim = "realm_access.roles"
+	cfg.RoleMapping = map[string]Role{"housing-caseworker": RoleCaseworker}
+	authenticator, err := NewAuthenticator(cfg, zap.NewNop())
+	require.NoError(t, err)
+	interceptor := authenticator.UnaryServerInterceptor()
+
+	var principal *Principal
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		principal, _ = FromContext(ctx)
+		return "ok", nil
+	}
+	call := func(ctx context.Context, method string) error {
+		principal = nil
+		_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+		return err
+	}
+
+	token := keys.sign(t, "RS256", "rsa1", validClaims())
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+	require.NoError(t, call(ctx, "/wohnfair.fairrent.v1.FairRentService/ScheduleNext"))
+	require.NotNil(t, principal)
+	assert.Equal(t, "user123", principal.UserID)
+	assert.Equal(t, []Role{RoleCaseworker, RoleApplicant}, principal.Roles)
+	assert.True(t, principal.HasRole(RoleCaseworker))
+	assert.False(t, principal.HasRole(RoleAdmin))
+
+	err = call(context.Background(), "/wohnfair.fairrent.v1.FairRentService/ScheduleNext")
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	bad := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token+"x"))
+	err = call(bad, "/wohnfair.fairrent.v1.FairRentService/ScheduleNext")
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// Health checks need no token
+	require.NoError(t, call(context.Background(), "/grpc.health.v1.Health/Check"))
+	assert.Nil(t, principal)
+}
+
+func TestAuthenticator_ClientCertificate(t *testing.T) {
+	keys := newTestKeys(t)
+	cfg := DefaultConfig()
+	cfg.Enabled = true
+	cfg.Issuer = testIssuer
+	cfg.JWKS = keys.jwks
+	cfg.CertificateRoles = map[string][]Role{"spiffe://wohnfair/allocation": {RoleService}}
+	authenticator, err := NewAuthenticator(cfg, zap.NewNop())
+	require.NoError(t, err)
+
+	withCertificate := func(ctx context.Context, uri string) context.Context {
+		u, err := url.Parse(uri)
+		require.NoError(t, err)
+		cert := &x509.Certificate{Raw: []byte(uri), Subject: pkix.Name{CommonName: "client"}, URIs: []*url.URL{u}}
+		return peer.NewContext(ctx, &peer.Peer{AuthInfo: credentials.TLSInfo{
+			State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}},
+		}})
+	}
+
+	// Listed certificates need no token
+	principal, err := authenticator.Authenticate(withCertificate(context.Background(), "spiffe://wohnfair/allocation"))
+	require.NoError(t, err)
+	assert.Equal(t, "spiffe://wohnfair/allocation", principal.Subject)
+	assert.Equal(t, []Role{RoleService}, principal.Roles)
+	assert.NotEmpty(t, principal.Certificate.Fingerprint)
+
+	_, err = authenticator.Authenticate(withCertificate(context.Background(), "spiffe://wohnfair/other"))
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// Token callers keep their token roles, with the certificate attached
+	token := keys.sign(t, "RS256", "rsa1", validClaims())
+	ctx := metadata.NewIncomingContext(withCertificate(context.Background(), "spiffe://wohnfair/other"), metadata.Pairs("authorization", "Bearer "+token))
+	principal, err = authenticator.Authenticate(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "subject-1", principal.Subject)
+	require.NotNil(t, principal.Certificate)
+	assert.Equal(t, "spiffe://wohnfair/other", principal.Certificate.Name())
+	assert.False(t, principal.HasRole(RoleService))
+}
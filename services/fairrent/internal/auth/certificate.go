@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// CertificateIdentity identifies a caller by its verified TLS client
+// certificate
+type CertificateIdentity struct {
+	Subject     string // distinguished name
+	CommonName  string
+	DNSNames    []string
+	URIs        []string // e.g. SPIFFE IDs
+	Issuer      string
+	Fingerprint string // hex SHA-256 of the certificate
+}
+
+// Name returns the most specific name of the certificate: its first URI,
+// else its first DNS name, else its common name
+func (c *CertificateIdentity) Name() string {
+	switch {
+	case len(c.URIs) > 0:
+		return c.URIs[0]
+	case len(c.DNSNames) > 0:
+		return c.DNSNames[0]
+	default:
+		return c.CommonName
+	}
+}
+
+// Matches reports whether name is any of the certificate's names
+func (c *CertificateIdentity) Matches(name string) bool {
+	if name == "" {
+		return false
+	}
+	if name == c.CommonName {
+		return true
+	}
+	for _, names := range [][]string{c.URIs, c.DNSNames} {
+		for _, n := range names {
+			if n == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// newCertificateIdentity describes a certificate
+func newCertificateIdentity(cert *x509.Certificate) *CertificateIdentity {
+	fingerprint := sha256.Sum256(cert.Raw)
+	identity := &CertificateIdentity{
+		Subject:     cert.Subject.String(),
+		CommonName:  cert.Subject.CommonName,
+		DNSNames:    cert.DNSNames,
+		Issuer:      cert.Issuer.String(),
+		Fingerprint: hex.EncodeToString(fingerprint[:]),
+	}
+	for _, uri := range cert.URIs {
+		identity.URIs = append(identity.URIs, uri.String())
+	}
+	return identity
+}
+
+// PeerCertificate returns the identity of the client certificate of a call.
+// Only certificates verified against the server's client CAs count.
+func PeerCertificate(ctx context.Context) (*CertificateIdentity, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, false
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return nil, false
+	}
+	return newCertificateIdentity(info.State.VerifiedChains[0][0]), true
+}
@@ -0,0 +1,299 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Authentication types
+const (
+	TypeJWT  = "jwt"
+	TypeNone = "none"
+)
+
+// DefaultPublicMethods can be called without a token: health checks and
+// server reflection
+var DefaultPublicMethods = []string{
+	"/grpc.health.v1.Health/Check",
+	"/grpc.health.v1.Health/Watch",
+	"/wohnfair.fairrent.v1.FairRentService/Health",
+	"/grpc.reflection.v1.ServerReflection/ServerReflectionInfo",
+	"/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo",
+}
+
+// Config configures bearer token authentication
+type Config struct {
+	Enabled bool   `yaml:"enabled"`
+	Type    string `yaml:"type"` // jwt or none
+
+	// Expected iss and aud claims; the audience is not checked if empty
+	Issuer   string `yaml:"issuer"`
+	Audience string `yaml:"audience"`
+
+	// JWKS URL or local file; empty discovers the URL from the issuer's
+	// OpenID configuration
+	JWKS        string        `yaml:"jwks"`
+	JWKSRefresh time.Duration `yaml:"jwks_refresh"`
+
+	// Allowed clock skew when checking exp and nbf
+	Leeway time.Duration `yaml:"leeway"`
+
+	// Claims holding the caller's user ID and roles. Nested claims are
+	// addressed with dots, e.g. realm_access.roles.
+	UserIDClaim string `yaml:"user_id_claim"`
+	RolesClaim  string `yaml:"roles_claim"`
+
+	// Role claim values mapped to roles; values equal to a role name need no
+	// mapping, others are ignored
+	RoleMapping map[string]Role `yaml:"role_mapping"`
+
+	// Roles of callers presenting a verified TLS client certificate without
+	// a token, by certificate URI, DNS name or common name, e.g. for other
+	// services. Needs TLS with client CAs.
+	CertificateRoles map[string][]Role `yaml:"certificate_roles"`
+
+	// Full method names callable without a token, in addition to
+	// DefaultPublicMethods
+	PublicMethods []string `yaml:"public_methods"`
+}
+
+// DefaultConfig returns authentication settings with authentication off
+func DefaultConfig() Config {
+	return Config{
+		Type:        TypeJWT,
+		JWKSRefresh: DefaultJWKSRefresh,
+		Leeway:      30 * time.Second,
+		UserIDClaim: "sub",
+		RolesClaim:  "roles",
+	}
+}
+
+// Validate checks an enabled configuration
+func (c Config) Validate() error {
+	switch c.Type {
+	case TypeJWT:
+	case TypeNone:
+		return nil
+	default:
+		return fmt.Errorf("unknown type %q", c.Type)
+	}
+	if c.Issuer == "" && c.JWKS == "" {
+		return fmt.Errorf("issuer or jwks is required")
+	}
+	if c.UserIDClaim == "" || c.RolesClaim == "" {
+		return fmt.Errorf("user_id_claim and roles_claim are required")
+	}
+	if c.Leeway < 0 {
+		return fmt.Errorf("leeway must not be negative")
+	}
+	for value, role := range c.RoleMapping {
+		if !knownRoles[role] {
+			return fmt.Errorf("role_mapping maps %q to unknown role %q", value, role)
+		}
+	}
+	for name, roles := range c.CertificateRoles {
+		for _, role := range roles {
+			if !knownRoles[role] {
+				return fmt.Errorf("certificate_roles gives %q unknown role %q", name, role)
+			}
+		}
+	}
+	return nil
+}
+
+// Authenticator verifies bearer tokens on incoming calls and puts the
+// caller's principal in the call context
+type Authenticator struct {
+	verifier *Verifier
+	config   Config
+	public   map[string]bool
+	logger   *zap.Logger
+}
+
+// NewAuthenticator creates an authenticator. Keys are fetched on first use,
+// so an unreachable issuer does not prevent startup.
+func NewAuthenticator(cfg Config, logger *zap.Logger) (*Authenticator, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	public := make(map[string]bool)
+	for _, method := range append(append([]string(nil), DefaultPublicMethods...), cfg.PublicMethods...) {
+		public[method] = true
+	}
+	return &Authenticator{
+		verifier: NewVerifier(NewKeySet(cfg.JWKS, cfg.Issuer, cfg.JWKSRefresh), cfg.Issuer, cfg.Audience, cfg.Leeway),
+		config:   cfg,
+		public:   public,
+		logger:   logger,
+	}, nil
+}
+
+// Authenticate verifies the bearer token in the call metadata and returns
+// the caller's principal. Callers without a token are authenticated by
+// their client certificate if it is listed in certificate_roles.
+func (a *Authenticator) Authenticate(ctx context.Context) (*Principal, error) {
+	certificate, _ := PeerCertificate(ctx)
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		if p := a.certificatePrincipal(certificate); p != nil {
+			return p, nil
+		}
+		return nil, status.Error(codes.Unauthenticated, "missing bearer token")
+	}
+	scheme, token, found := strings.Cut(values[0], " ")
+	if !found || !strings.EqualFold(scheme, "bearer") || token == "" {
+		return nil, status.Error(codes.Unauthenticated, "authorization must be a bearer token")
+	}
+
+	claims, err := a.verifier.Verify(ctx, strings.TrimSpace(token))
+	if err != nil {
+		if !errors.Is(err, ErrInvalidToken) {
+			a.logger.Error("Failed to verify token", zap.Error(err))
+			return nil, status.Error(codes.Unavailable, "token verification unavailable")
+		}
+		a.logger.Debug("Rejected token", zap.Error(err))
+		return nil, status.Error(codes.Unauthenticated, "invalid token")
+	}
+	p := a.principal(claims)
+	p.Certificate = certificate
+	return p, nil
+}
+
+// certificatePrincipal returns the principal of a client certificate listed
+// in certificate_roles, or nil
+func (a *Authenticator) certificatePrincipal(certificate *CertificateIdentity) *Principal {
+	if certificate == nil || len(a.config.CertificateRoles) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(a.config.CertificateRoles))
+	for name := range a.config.CertificateRoles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var roles []Role
+	seen := make(map[Role]bool)
+	for _, name := range names {
+		if !certificate.Matches(name) {
+			continue
+		}
+		for _, role := range a.config.CertificateRoles[name] {
+			if !seen[role] {
+				seen[role] = true
+				roles = append(roles, role)
+			}
+		}
+	}
+	if len(roles) == 0 {
+		return nil
+	}
+	return &Principal{
+		Subject:     certificate.Name(),
+		Issuer:      certificate.Issuer,
+		Roles:       roles,
+		Certificate: certificate,
+	}
+}
+
+// principal maps verified claims to a principal
+func (a *Authenticator) principal(claims *Claims) *Principal {
+	p := &Principal{
+		Subject: claims.Subject,
+		Issuer:  claims.Issuer,
+	}
+	if id, ok := claimAt(claims.Raw, a.config.UserIDClaim).(string); ok {
+		p.UserID = id
+	}
+
+	var values []string
+	switch roles := claimAt(claims.Raw, a.config.RolesClaim).(type) {
+	case string:
+		// Space-separated, as in the scope claim
+		values = strings.Fields(roles)
+	case []interface{}:
+		for _, role := range roles {
+			if s, ok := role.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+
+	seen := make(map[Role]bool)
+	for _, value := range values {
+		role, mapped := a.config.RoleMapping[value]
+		if !mapped {
+			role = Role(value)
+		}
+		if knownRoles[role] && !seen[role] {
+			seen[role] = true
+			p.Roles = append(p.Roles, role)
+		}
+	}
+	return p
+}
+
+// claimAt returns the claim at a dot-separated path
+func claimAt(claims map[string]interface{}, path string) interface{} {
+	var current interface{} = claims
+	for _, key := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = m[key]
+	}
+	return current
+}
+
+// UnaryServerInterceptor authenticates unary calls
+func (a *Authenticator) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if a.public[info.FullMethod] {
+			return handler(ctx, req)
+		}
+		p, err := a.Authenticate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return handler(NewContext(ctx, p), req)
+	}
+}
+
+// StreamServerInterceptor authenticates streaming calls
+func (a *Authenticator) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if a.public[info.FullMethod] {
+			return handler(srv, ss)
+		}
+		p, err := a.Authenticate(ss.Context())
+		if err != nil {
+			return err
+		}
+		return handler(srv, &principalStream{ServerStream: ss, ctx: NewContext(ss.Context(), p)})
+	}
+}
+
+// principalStream is a server stream whose context carries the principal
+type principalStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the stream context with the principal
+func (s *principalStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,285 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrUnknownKey is returned for a key ID the issuer does not publish
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// DefaultJWKSRefresh is how long fetched keys are used before refetching
+const DefaultJWKSRefresh = time.Hour
+
+// minRefetchInterval limits refetches triggered by unknown key IDs
+const minRefetchInterval = 30 * time.Second
+
+// maxDocumentSize bounds fetched JWKS and discovery documents
+const maxDocumentSize = 1 << 20
+
+// jwk is a JSON Web Key (RFC 7517) as found in a JWKS document
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+
+	// EC and OKP
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// publicKey decodes the key
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus: %w", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exponent: %w", err)
+		}
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("unsupported exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x coordinate: %w", err)
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid y coordinate: %w", err)
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point is not on curve %s", k.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// decodeBigInt decodes a base64url big-endian integer
+func decodeBigInt(s string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty value")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+// ParseJWKS returns the signing keys of a JWKS document by key ID. Keys
+// meant for encryption and keys of unsupported types are skipped.
+func ParseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse JWKS: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(doc.Keys))
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("JWKS contains no usable signing keys")
+	}
+	return keys, nil
+}
+
+// KeySet provides the issuer's signing keys from a JWKS URL or file. Keys are
+// refetched when they are older than the refresh interval or when a token
+// names an unknown key ID, e.g. after the issuer rotated its keys.
+type KeySet struct {
+	source string // URL or file path; empty discovers the URL from issuer
+	issuer string
+	client *http.Client
+
+	refresh time.Duration
+	now     func() time.Time
+
+	mu          sync.Mutex
+	keys        map[string]crypto.PublicKey
+	fetched     time.Time
+	lastAttempt time.Time
+}
+
+// NewKeySet creates a key set reading the JWKS at source, an http(s) URL or
+// a local file. With an empty source the JWKS URL is discovered from the
+// issuer's OpenID configuration.
+func NewKeySet(source, issuer string, refresh time.Duration) *KeySet {
+	if refresh <= 0 {
+		refresh = DefaultJWKSRefresh
+	}
+	return &KeySet{
+		source:  source,
+		issuer:  issuer,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		refresh: refresh,
+		now:     time.Now,
+	}
+}
+
+// Key returns the key with the given ID. An empty ID selects the only key of
+// a single-key set.
+func (ks *KeySet) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	now := ks.now()
+	stale := ks.keys == nil || now.Sub(ks.fetched) > ks.refresh
+	_, known := ks.lookup(kid)
+	if (stale || !known) && (ks.keys == nil || now.Sub(ks.lastAttempt) >= minRefetchInterval) {
+		ks.lastAttempt = now
+		keys, err := ks.fetch(ctx)
+		if err != nil && ks.keys == nil {
+			return nil, err
+		}
+		if err == nil {
+			ks.keys = keys
+			ks.fetched = now
+		}
+	}
+
+	key, ok := ks.lookup(kid)
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, kid)
+	}
+	return key, nil
+}
+
+// lookup finds a key in the current set
+func (ks *KeySet) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(ks.keys) == 1 {
+		for _, key := range ks.keys {
+			return key, true
+		}
+	}
+	key, ok := ks.keys[kid]
+	return key, ok
+}
+
+// fetch reads and parses the JWKS
+func (ks *KeySet) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	source := ks.source
+	if source == "" {
+		discovered, err := ks.discover(ctx)
+		if err != nil {
+			return nil, err
+		}
+		source = discovered
+	}
+
+	if !strings.HasPrefix(source, "https://") && !strings.HasPrefix(source, "http://") {
+		data, err := os.ReadFile(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWKS: %w", err)
+		}
+		return ParseJWKS(data)
+	}
+
+	data, err := ks.get(ctx, source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	return ParseJWKS(data)
+}
+
+// discover reads the JWKS URL from the issuer's OpenID configuration
+func (ks *KeySet) discover(ctx context.Context) (string, error) {
+	if ks.issuer == "" {
+		return "", fmt.Errorf("neither a JWKS source nor an issuer to discover it from is configured")
+	}
+
+	data, err := ks.get(ctx, strings.TrimSuffix(ks.issuer, "/")+"/.well-known/openid-configuration")
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch OpenID configuration: %w", err)
+	}
+	var doc struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return "", fmt.Errorf("failed to parse OpenID configuration: %w", err)
+	}
+	if doc.Issuer != ks.issuer {
+		return "", fmt.Errorf("OpenID configuration is for issuer %q, not %q", doc.Issuer, ks.issuer)
+	}
+	if doc.JWKSURI == "" {
+		return "", fmt.Errorf("OpenID configuration has no jwks_uri")
+	}
+	return doc.JWKSURI, nil
+}
+
+// get fetches a document over HTTP
+func (ks *KeySet) get(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := ks.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxDocumentSize))
+}
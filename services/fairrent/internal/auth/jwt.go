@@ -0,0 +1,259 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// ErrInvalidToken is returned for tokens that fail verification
+var ErrInvalidToken = errors.New("invalid token")
+
+// algorithm describes a JWS signature algorithm
+type algorithm struct {
+	hash   crypto.Hash
+	verify func(key crypto.PublicKey, hash crypto.Hash, digest, signed, sig []byte) error
+}
+
+// algorithms are the accepted signature algorithms. Symmetric algorithms and
+// "none" are rejected: keys come from the issuer's public JWKS.
+var algorithms = map[string]algorithm{
+	"RS256": {crypto.SHA256, verifyRSA},
+	"RS384": {crypto.SHA384, verifyRSA},
+	"RS512": {crypto.SHA512, verifyRSA},
+	"PS256": {crypto.SHA256, verifyRSAPSS},
+	"PS384": {crypto.SHA384, verifyRSAPSS},
+	"PS512": {crypto.SHA512, verifyRSAPSS},
+	"ES256": {crypto.SHA256, verifyECDSA},
+	"ES384": {crypto.SHA384, verifyECDSA},
+	"ES512": {crypto.SHA512, verifyECDSA},
+	"EdDSA": {0, verifyEd25519},
+}
+
+// Claims are the verified claims of a token
+type Claims struct {
+	Issuer    string
+	Subject   string
+	Audience  []string
+	ExpiresAt time.Time
+
+	// All claims as decoded from the payload
+	Raw map[string]interface{}
+}
+
+// Verifier verifies signed JWTs against an issuer's keys
+type Verifier struct {
+	keys     *KeySet
+	issuer   string
+	audience string
+	leeway   time.Duration
+	now      func() time.Time
+}
+
+// NewVerifier creates a verifier accepting tokens signed with keys from ks.
+// A non-empty issuer or audience must match the iss or aud claim; leeway
+// allows for clock skew on exp and nbf.
+func NewVerifier(ks *KeySet, issuer, audience string, leeway time.Duration) *Verifier {
+	return &Verifier{
+		keys:     ks,
+		issuer:   issuer,
+		audience: audience,
+		leeway:   leeway,
+		now:      time.Now,
+	}
+}
+
+// Verify checks a compact JWS token's signature and registered claims
+func (v *Verifier) Verify(ctx context.Context, token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidToken)
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: malformed header: %v", ErrInvalidToken, err)
+	}
+	alg, ok := algorithms[header.Alg]
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidToken, header.Alg)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed signature", ErrInvalidToken)
+	}
+	key, err := v.keys.Key(ctx, header.Kid)
+	if errors.Is(err, ErrUnknownKey) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get signing keys: %w", err)
+	}
+
+	signed := []byte(parts[0] + "." + parts[1])
+	var digest []byte
+	if alg.hash != 0 {
+		h := alg.hash.New()
+		h.Write(signed)
+		digest = h.Sum(nil)
+	}
+	if err := alg.verify(key, alg.hash, digest, signed, sig); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	var raw map[string]interface{}
+	if err := decodeSegment(parts[1], &raw); err != nil {
+		return nil, fmt.Errorf("%w: malformed payload: %v", ErrInvalidToken, err)
+	}
+	claims, err := v.checkClaims(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	return claims, nil
+}
+
+// checkClaims validates the registered claims
+func (v *Verifier) checkClaims(raw map[string]interface{}) (*Claims, error) {
+	claims := &Claims{Raw: raw}
+	claims.Issuer, _ = raw["iss"].(string)
+	claims.Subject, _ = raw["sub"].(string)
+	switch aud := raw["aud"].(type) {
+	case string:
+		claims.Audience = []string{aud}
+	case []interface{}:
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				claims.Audience = append(claims.Audience, s)
+			}
+		}
+	}
+
+	now := v.now()
+	exp, ok := numericDate(raw["exp"])
+	if !ok {
+		return nil, fmt.Errorf("missing exp claim")
+	}
+	claims.ExpiresAt = exp
+	if now.After(exp.Add(v.leeway)) {
+		return nil, fmt.Errorf("token expired at %s", exp.Format(time.RFC3339))
+	}
+	if nbf, ok := numericDate(raw["nbf"]); ok && now.Add(v.leeway).Before(nbf) {
+		return nil, fmt.Errorf("token not valid before %s", nbf.Format(time.RFC3339))
+	}
+
+	if v.issuer != "" && claims.Issuer != v.issuer {
+		return nil, fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	}
+	if v.audience != "" && !contains(claims.Audience, v.audience) {
+		return nil, fmt.Errorf("token is not intended for audience %q", v.audience)
+	}
+	return claims, nil
+}
+
+// decodeSegment decodes a base64url JSON segment
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// numericDate converts a NumericDate claim
+func numericDate(v interface{}) (time.Time, bool) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	f, err := n.Float64()
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return time.Time{}, false
+	}
+	sec := math.Floor(f)
+	return time.Unix(int64(sec), int64((f-sec)*1e9)), true
+}
+
+// contains reports whether values contains s
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyRSA verifies an RSASSA-PKCS1-v1_5 signature
+func verifyRSA(key crypto.PublicKey, hash crypto.Hash, digest, _, sig []byte) error {
+	pub, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("key is not an RSA key")
+	}
+	return rsa.VerifyPKCS1v15(pub, hash, digest, sig)
+}
+
+// verifyRSAPSS verifies an RSASSA-PSS signature
+func verifyRSAPSS(key crypto.PublicKey, hash crypto.Hash, digest, _, sig []byte) error {
+	pub, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("key is not an RSA key")
+	}
+	return rsa.VerifyPSS(pub, hash, digest, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+}
+
+// ecdsaCurveBits is the curve size each ECDSA algorithm's hash belongs to
+var ecdsaCurveBits = map[crypto.Hash]int{
+	crypto.SHA256: 256,
+	crypto.SHA384: 384,
+	crypto.SHA512: 521,
+}
+
+// verifyECDSA verifies a JWS ECDSA signature, the concatenation of r and s
+func verifyECDSA(key crypto.PublicKey, hash crypto.Hash, digest, _, sig []byte) error {
+	pub, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("key is not an EC key")
+	}
+	if pub.Curve.Params().BitSize != ecdsaCurveBits[hash] {
+		return fmt.Errorf("key curve does not match the algorithm")
+	}
+	size := (pub.Curve.Params().BitSize + 7) / 8
+	if len(sig) != 2*size {
+		return fmt.Errorf("invalid signature length")
+	}
+	r := new(big.Int).SetBytes(sig[:size])
+	s := new(big.Int).SetBytes(sig[size:])
+	if !ecdsa.Verify(pub, digest, r, s) {
+		return fmt.Errorf("signature verification failed")
+	}
+	return nil
+}
+
+// verifyEd25519 verifies an EdDSA signature over the signed input itself
+func verifyEd25519(key crypto.PublicKey, _ crypto.Hash, _, signed, sig []byte) error {
+	pub, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return fmt.Errorf("key is not an Ed25519 key")
+	}
+	if !ed25519.Verify(pub, signed, sig) {
+		return fmt.Errorf("signature verification failed")
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+// Package auth authenticates gRPC callers with bearer JWTs issued by an
+// OpenID Connect provider and maps their claims to a principal.
+package auth
+
+import "context"
+
+// Role is what a principal may act as
+type Role string
+
+// Roles known to the service
+const (
+	RoleApplicant  Role = "applicant"  // a person with housing requests
+	RoleCaseworker Role = "caseworker" // municipal staff handling requests
+	RoleAdmin      Role = "admin"      // operators of the service
+	RoleService    Role = "service"    // other services, e.g. the allocation service
+)
+
+// knownRoles are the roles a claim value may name directly
+var knownRoles = map[Role]bool{
+	RoleApplicant:  true,
+	RoleCaseworker: true,
+	RoleAdmin:      true,
+	RoleService:    true,
+}
+
+// Known reports whether r is one of the roles known to the service
+func (r Role) Known() bool {
+	return knownRoles[r]
+}
+
+// Principal is an authenticated caller
+type Principal struct {
+	Subject string // token subject
+	Issuer  string
+	UserID  string // the caller's user ID, matched against request user IDs
+	Roles   []Role
+
+	// Verified TLS client certificate of the call, if any
+	Certificate *CertificateIdentity
+}
+
+// HasRole reports whether the principal has any of the given roles
+func (p *Principal) HasRole(roles ...Role) bool {
+	for _, have := range p.Roles {
+		for _, want := range roles {
+			if have == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// principalKey is the context key of the principal
+type principalKey struct{}
+
+// NewContext returns ctx carrying the principal
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the principal of an authenticated call
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
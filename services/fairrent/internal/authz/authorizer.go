@@ -0,0 +1,214 @@
+package authz
+
+import (
+	"context"
+
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/audit"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/auth"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/common/v1"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DeniedRecordType is the audit record type of a denied call
+const DeniedRecordType = "authorization_denied"
+
+// OwnerFunc returns the user ID owning a ticket
+type OwnerFunc func(ticketID string) (string, bool)
+
+// Authorizer checks calls against a policy. Denials are logged and
+// recorded in the audit log.
+type Authorizer struct {
+	policy *Policy
+	owners OwnerFunc
+	audit  *audit.Log
+	logger *zap.Logger
+}
+
+// NewAuthorizer creates an authorizer looking up ticket owners with owners
+func NewAuthorizer(policy *Policy, owners OwnerFunc, auditLog *audit.Log, logger *zap.Logger) *Authorizer {
+	return &Authorizer{
+		policy: policy,
+		owners: owners,
+		audit:  auditLog,
+		logger: logger,
+	}
+}
+
+// userIDRequest is a request naming a user
+type userIDRequest interface {
+	GetUserId() *commonv1.UserID
+}
+
+// ticketIDRequest is a request naming a ticket
+type ticketIDRequest interface {
+	GetTicketId() *commonv1.TicketID
+}
+
+// Authorize checks whether the caller in ctx may call method with req. It
+// returns ctx marked with the caller's view of the response.
+func (a *Authorizer) Authorize(ctx context.Context, method string, req interface{}) (context.Context, error) {
+	rule, listed := a.policy.Rule(method)
+	if (listed && rule.Public) || isDefaultPublic(method) {
+		return ctx, nil
+	}
+
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		a.deny(nil, method, req, "unauthenticated caller")
+		return nil, status.Error(codes.Unauthenticated, "authentication required")
+	}
+	if !listed {
+		return nil, a.deny(principal, method, req, "method is not in the policy")
+	}
+	if !principal.HasRole(rule.Roles...) {
+		return nil, a.deny(principal, method, req, "no permitted role")
+	}
+	if rule.RequireCertificate && principal.Certificate == nil {
+		return nil, a.deny(principal, method, req, "no client certificate")
+	}
+
+	// Callers holding only owner-only roles must name their own resources
+	if !principal.HasRole(rule.unrestricted()...) {
+		if reason := a.checkOwnership(principal, req); reason != "" {
+			return nil, a.deny(principal, method, req, reason)
+		}
+	}
+
+	if len(rule.FullView) > 0 && !principal.HasRole(rule.FullView...) {
+		ctx = context.WithValue(ctx, summaryViewKey{}, true)
+	}
+	return ctx, nil
+}
+
+// checkOwnership returns why req is not limited to the principal's own
+// resources, or an empty string if it is
+func (a *Authorizer) checkOwnership(principal *auth.Principal, req interface{}) string {
+	if principal.UserID == "" {
+		return "token carries no user ID"
+	}
+
+	named := false
+	if r, ok := req.(userIDRequest); ok && r.GetUserId() != nil {
+		named = true
+		if r.GetUserId().GetValue() != principal.UserID {
+			return "user ID belongs to another user"
+		}
+	}
+	if r, ok := req.(ticketIDRequest); ok && r.GetTicketId() != nil {
+		named = true
+		// Unknown tickets are denied like other users' tickets, so that
+		// denials do not reveal which ticket IDs exist
+		owner, found := a.owners(r.GetTicketId().GetValue())
+		if !found || owner != principal.UserID {
+			return "ticket belongs to another user"
+		}
+	}
+	if !named {
+		return "request names no user or ticket"
+	}
+	return ""
+}
+
+// deny logs and audits a denied call and returns its status
+func (a *Authorizer) deny(principal *auth.Principal, method string, req interface{}, reason string) error {
+	actor := "anonymous"
+	var roles []auth.Role
+	if principal != nil {
+		actor = principal.Subject
+		roles = principal.Roles
+	}
+
+	payload := map[string]interface{}{
+		"method": method,
+		"roles":  roles,
+		"reason": reason,
+	}
+	if r, ok := req.(ticketIDRequest); ok && r.GetTicketId() != nil {
+		payload["ticket_id"] = r.GetTicketId().GetValue()
+	}
+	if principal != nil && principal.Certificate != nil {
+		payload["certificate"] = principal.Certificate.Name()
+	}
+	if _, err := a.audit.Append(DeniedRecordType, actor, payload); err != nil {
+		a.logger.Error("Failed to audit denied call", zap.Error(err))
+	}
+
+	a.logger.Warn("Call denied",
+		zap.String("method", method),
+		zap.String("subject", actor),
+		zap.String("reason", reason),
+	)
+	return status.Errorf(codes.PermissionDenied, "permission denied: %s", reason)
+}
+
+// isDefaultPublic reports whether method is public for every service
+func isDefaultPublic(method string) bool {
+	for _, public := range auth.DefaultPublicMethods {
+		if method == public {
+			return true
+		}
+	}
+	return false
+}
+
+// summaryViewKey marks a call whose caller sees response summaries
+type summaryViewKey struct{}
+
+// FullView reports whether the caller may see full responses. It is true
+// for calls that were not authorized, e.g. with authorization disabled.
+func FullView(ctx context.Context) bool {
+	summary, _ := ctx.Value(summaryViewKey{}).(bool)
+	return !summary
+}
+
+// UnaryServerInterceptor authorizes unary calls. It must run after the
+// authentication interceptor.
+func (a *Authorizer) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := a.Authorize(ctx, info.FullMethod, req)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor authorizes streaming calls. Requests are checked
+// as they are received, so that ownership rules apply to server streams.
+func (a *Authorizer) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &authorizedStream{ServerStream: ss, authorizer: a, method: info.FullMethod})
+	}
+}
+
+// authorizedStream authorizes every message received on a stream
+type authorizedStream struct {
+	grpc.ServerStream
+	authorizer *Authorizer
+	method     string
+	ctx        context.Context
+}
+
+// Context returns the stream context as marked by authorization
+func (s *authorizedStream) Context() context.Context {
+	if s.ctx != nil {
+		return s.ctx
+	}
+	return s.ServerStream.Context()
+}
+
+// RecvMsg receives and authorizes a request
+func (s *authorizedStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	ctx, err := s.authorizer.Authorize(s.ServerStream.Context(), s.method, m)
+	if err != nil {
+		return err
+	}
+	s.ctx = ctx
+	return nil
+}
@@ -0,0 +1,127 @@
+package authz
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/audit"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/auth"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/common/v1"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/fairrent/v1"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const service = "/wohnfair.fairrent.v1.FairRentService/"
+
+func TestLoadPolicy_Shipped(t *testing.T) {
+	policy, err := LoadPolicy("../../config/policies/rbac.yaml")
+	require.NoError(t, err)
+
+	rule, ok := policy.Rule(service + "ScheduleNext")
+	require.True(t, ok)
+	assert.ElementsMatch(t, []auth.Role{auth.RoleCaseworker, auth.RoleService}, rule.Roles)
+}
+
+func TestParsePolicy_Invalid(t *testing.T) {
+	for name, doc := range map[string]string{
+		"unknown role":       "service: s\nmethods:\n  A:\n    roles: [tenant]\n",
+		"owner not in role":  "service: s\nmethods:\n  A:\n    roles: [admin]\n    owner_only: [applicant]\n",
+		"no roles":           "service: s\nmethods:\n  A: {}\n",
+		"no service":         "methods:\n  A:\n    roles: [admin]\n",
+		"public certificate": "service: s\nmethods:\n  A:\n    public: true\n    require_certificate: true\n",
+	} {
+		_, err := ParsePolicy([]byte(doc))
+		assert.Error(t, err, name)
+	}
+}
+
+func TestAuthorizer(t *testing.T) {
+	policy, err := LoadPolicy("../../config/policies/rbac.yaml")
+	require.NoError(t, err)
+	owners := map[string]string{"TKT_1": "user1", "TKT_2": "user2"}
+	auditLog := audit.NewLog()
+	authorizer := NewAuthorizer(policy, func(ticketID string) (string, bool) {
+		owner, ok := owners[ticketID]
+		return owner, ok
+	}, auditLog, zap.NewNop())
+
+	applicant := &auth.Principal{Subject: "s1", UserID: "user1", Roles: []auth.Role{auth.RoleApplicant}}
+	caseworker := &auth.Principal{Subject: "s2", UserID: "cw", Roles: []auth.Role{auth.RoleCaseworker}}
+	peek := func(ticketID string) *fairrentv1.PeekPositionRequest {
+		return &fairrentv1.PeekPositionRequest{TicketId: &commonv1.TicketID{Value: ticketID}}
+	}
+
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		method    string
+		req       interface{}
+		code      codes.Code
+	}{
+		{"own ticket", applicant, "PeekPosition", peek("TKT_1"), codes.OK},
+		{"other's ticket", applicant, "PeekPosition", peek("TKT_2"), codes.PermissionDenied},
+		{"unknown ticket", applicant, "PeekPosition", peek("TKT_404"), codes.PermissionDenied},
+		{"caseworker any ticket", caseworker, "PeekPosition", peek("TKT_2"), codes.OK},
+		{"own enqueue", applicant, "Enqueue", &fairrentv1.EnqueueRequest{UserId: &commonv1.UserID{Value: "user1"}}, codes.OK},
+		{"enqueue for other", applicant, "Enqueue", &fairrentv1.EnqueueRequest{UserId: &commonv1.UserID{Value: "user2"}}, codes.PermissionDenied},
+		{"applicant schedules", applicant, "ScheduleNext", &fairrentv1.ScheduleNextRequest{}, codes.PermissionDenied},
+		{"caseworker schedules", caseworker, "ScheduleNext", &fairrentv1.ScheduleNextRequest{}, codes.OK},
+		{"unlisted method", caseworker, "Unknown", nil, codes.PermissionDenied},
+		{"no principal", nil, "GetMetrics", nil, codes.Unauthenticated},
+		{"public", nil, "Health", nil, codes.OK},
+	}
+	denied := 0
+	for _, tt := range tests {
+		ctx := context.Background()
+		if tt.principal != nil {
+			ctx = auth.NewContext(ctx, tt.principal)
+		}
+		_, err := authorizer.Authorize(ctx, service+tt.method, tt.req)
+		assert.Equal(t, tt.code, status.Code(err), tt.name)
+		if err != nil {
+			denied++
+		}
+	}
+
+	// Every denial is audited
+	records := auditLog.Records(1, 0)
+	require.Len(t, records, denied)
+	assert.Equal(t, DeniedRecordType, records[0].Type)
+	assert.Equal(t, "s1", records[0].Actor)
+	assert.Contains(t, string(records[0].Payload), "TKT_2")
+
+	// Applicants see a summary of the metrics, caseworkers the full metrics
+	ctx, err := authorizer.Authorize(auth.NewContext(context.Background(), applicant), service+"GetMetrics", nil)
+	require.NoError(t, err)
+	assert.False(t, FullView(ctx))
+	ctx, err = authorizer.Authorize(auth.NewContext(context.Background(), caseworker), service+"GetMetrics", nil)
+	require.NoError(t, err)
+	assert.True(t, FullView(ctx))
+}
+
+func TestAuthorizer_RequireCertificate(t *testing.T) {
+	policy, err := ParsePolicy([]byte("service: s\nmethods:\n  A:\n    roles: [service]\n    require_certificate: true\n"))
+	require.NoError(t, err)
+	auditLog := audit.NewLog()
+	authorizer := NewAuthorizer(policy, nil, auditLog, zap.NewNop())
+
+	token := &auth.Principal{Subject: "allocation", Roles: []auth.Role{auth.RoleService}}
+	_, err = authorizer.Authorize(auth.NewContext(context.Background(), token), "/s/A", nil)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	mtls := &auth.Principal{
+		Subject:     "spiffe://wohnfair/allocation",
+		Roles:       []auth.Role{auth.RoleService},
+		Certificate: &auth.CertificateIdentity{URIs: []string{"spiffe://wohnfair/allocation"}},
+	}
+	_, err = authorizer.Authorize(auth.NewContext(context.Background(), mtls), "/s/A", nil)
+	assert.NoError(t, err)
+
+	records := auditLog.Records(1, 0)
+	require.Len(t, records, 1)
+	assert.Contains(t, string(records[0].Payload), "no client certificate")
+}
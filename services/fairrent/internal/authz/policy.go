@@ -0,0 +1,138 @@
+// Package authz decides which principals may call which RPCs, following a
+// per-method role matrix and ownership rules loaded from a policy file.
+package authz
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/auth"
+	"gopkg.in/yaml.v3"
+)
+
+// Rule grants access to one method
+type Rule struct {
+	// Public methods need no principal, e.g. health checks
+	Public bool `yaml:"public"`
+
+	// Roles that may call the method
+	Roles []auth.Role `yaml:"roles"`
+
+	// Roles among Roles that may only act on their own user ID and tickets
+	OwnerOnly []auth.Role `yaml:"owner_only"`
+
+	// Roles that see the full response; other callers get a summary where
+	// the method offers one. Empty shows every caller the full response.
+	FullView []auth.Role `yaml:"full_view"`
+
+	// Callers must present a verified TLS client certificate, e.g. on
+	// methods meant for other services only
+	RequireCertificate bool `yaml:"require_certificate"`
+}
+
+// unrestricted returns the roles that may call the method for any owner
+func (r Rule) unrestricted() []auth.Role {
+	var roles []auth.Role
+	for _, role := range r.Roles {
+		if !containsRole(r.OwnerOnly, role) {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// Policy is the role matrix of a service
+type Policy struct {
+	// Service whose methods are named without a service prefix
+	Service string `yaml:"service"`
+
+	// Rules by method; names starting with a slash are full method names
+	Methods map[string]Rule `yaml:"methods"`
+}
+
+// LoadPolicy reads and validates a policy file
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file: %w", err)
+	}
+	policy, err := ParsePolicy(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return policy, nil
+}
+
+// ParsePolicy parses and validates a YAML policy. Method names are resolved
+// to full method names.
+func ParsePolicy(data []byte) (*Policy, error) {
+	var doc Policy
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse policy: %w", err)
+	}
+
+	policy := &Policy{Service: doc.Service, Methods: make(map[string]Rule, len(doc.Methods))}
+	for name, rule := range doc.Methods {
+		method := name
+		if !strings.HasPrefix(name, "/") {
+			if doc.Service == "" {
+				return nil, fmt.Errorf("method %s: a service is required for short method names", name)
+			}
+			method = "/" + doc.Service + "/" + name
+		}
+		if err := rule.validate(); err != nil {
+			return nil, fmt.Errorf("method %s: %w", name, err)
+		}
+		if _, exists := policy.Methods[method]; exists {
+			return nil, fmt.Errorf("method %s is listed twice", name)
+		}
+		policy.Methods[method] = rule
+	}
+	return policy, nil
+}
+
+// validate checks that a rule only names known roles, consistently
+func (r Rule) validate() error {
+	if r.Public {
+		if len(r.Roles) > 0 || len(r.OwnerOnly) > 0 || len(r.FullView) > 0 || r.RequireCertificate {
+			return fmt.Errorf("public methods take no roles")
+		}
+		return nil
+	}
+	if len(r.Roles) == 0 {
+		return fmt.Errorf("roles are required")
+	}
+	for _, role := range r.Roles {
+		if !role.Known() {
+			return fmt.Errorf("unknown role %q", role)
+		}
+	}
+	for _, role := range r.OwnerOnly {
+		if !containsRole(r.Roles, role) {
+			return fmt.Errorf("owner_only role %q is not in roles", role)
+		}
+	}
+	for _, role := range r.FullView {
+		if !containsRole(r.Roles, role) {
+			return fmt.Errorf("full_view role %q is not in roles", role)
+		}
+	}
+	return nil
+}
+
+// Rule returns the rule of a full method name
+func (p *Policy) Rule(method string) (Rule, bool) {
+	rule, ok := p.Methods[method]
+	return rule, ok
+}
+
+// containsRole reports whether roles contains role
+func containsRole(roles []auth.Role, role auth.Role) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,170 @@
+package bulk
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/scheduler"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/validation"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/common/v1"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/fairrent/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// fieldCodes maps each reported field to its code
+func fieldCodes(t *testing.T, err error) map[string]string {
+	t.Helper()
+	require.Error(t, err)
+	fields := make(map[string]string)
+	for _, detail := range validation.Details(err) {
+		fields[detail.Field] = detail.Code
+	}
+	return fields
+}
+
+func exportedTicket() scheduler.ExportedTicket {
+	return scheduler.ExportedTicket{
+		ID:            "ticket_1",
+		UserID:        "user1",
+		EnqueueTime:   time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC),
+		PriorityScore: 0.75,
+		Request: &fairrentv1.EnqueueRequest{
+			UserId:    &commonv1.UserID{Value: "user1"},
+			UserGroup: commonv1.UserGroup_USER_GROUP_STUDENT,
+			Urgency:   commonv1.UrgencyLevel_URGENCY_LEVEL_HIGH,
+			PreferredLocations: []*commonv1.Location{
+				{Latitude: 52.52, Longitude: 13.405, City: "Berlin", PostalCode: "10115", Country: "DE"},
+			},
+			PreferredCities: []string{"Berlin", "Potsdam"},
+			PropertyTypes:   []commonv1.PropertyType{commonv1.PropertyType_PROPERTY_TYPE_APARTMENT},
+			FinancialConstraints: &commonv1.FinancialConstraints{
+				MaxMonthlyRent:          800,
+				AcceptsHousingAllowance: true,
+			},
+			MinRooms: 2,
+			AccessibilityRequirements: &commonv1.AccessibilityRequirements{
+				WheelchairAccessible: true,
+				ElevatorRequired:     true,
+			},
+		},
+		Preferences:   map[string]string{"floor": "ground"},
+		Status:        commonv1.AllocationStatus_ALLOCATION_STATUS_QUEUED,
+		QueuePosition: 1,
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, format := range []Format{FormatJSON, FormatNDJSON, FormatCSV} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			w := NewWriter(&buf, format)
+			require.NoError(t, w.Write(NewRecord(exportedTicket())))
+			require.NoError(t, w.Close())
+
+			rows, err := Read(&buf, format)
+			require.NoError(t, err)
+			require.Len(t, rows, 1)
+			require.NoError(t, rows[0].Err)
+			assert.Equal(t, NewRecord(exportedTicket()), rows[0].Record)
+
+			ticket, err := rows[0].Record.Ticket(validation.DefaultConfig())
+			require.NoError(t, err)
+			assert.Equal(t, "ticket_1", ticket.TicketID)
+			assert.True(t, ticket.EnqueueTime.Equal(exportedTicket().EnqueueTime))
+			assert.Equal(t, commonv1.UserGroup_USER_GROUP_STUDENT, ticket.Request.UserGroup)
+			assert.Equal(t, float64(800), ticket.Request.FinancialConstraints.MaxMonthlyRent)
+			assert.Equal(t, "ground", ticket.Request.AdditionalPreferences["floor"])
+			require.Len(t, ticket.Request.PreferredLocations, 1)
+			assert.True(t, proto.Equal(exportedTicket().Request.PreferredLocations[0], ticket.Request.PreferredLocations[0]))
+			assert.True(t, proto.Equal(exportedTicket().Request.AccessibilityRequirements, ticket.Request.AccessibilityRequirements))
+		})
+	}
+}
+
+func TestEmptyExport(t *testing.T) {
+	for _, format := range []Format{FormatJSON, FormatNDJSON, FormatCSV} {
+		var buf bytes.Buffer
+		require.NoError(t, NewWriter(&buf, format).Close())
+		rows, err := Read(&buf, format)
+		require.NoError(t, err, format)
+		assert.Empty(t, rows, format)
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	input := "\ufeffUser_ID,user_group,urgency,enqueue_time,min_rooms,preferred_cities\n" +
+		"user1,student,high,2024-03-01,2,Berlin;Potsdam\n" +
+		"user2,family,low,2024-03-02,two,\n" +
+		"user3,family\n"
+
+	rows, err := Read(strings.NewReader(input), FormatCSV)
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+
+	require.NoError(t, rows[0].Err)
+	assert.Equal(t, []string{"Berlin", "Potsdam"}, rows[0].Record.PreferredCities)
+	assert.Equal(t, int32(2), rows[0].Record.MinRooms)
+
+	assert.Equal(t, map[string]string{"min_rooms": validation.CodeInvalidFormat}, fieldCodes(t, rows[1].Err))
+	assert.Equal(t, 3, rows[2].Number)
+	assert.Error(t, rows[2].Err)
+
+	_, err = Read(strings.NewReader("user_id,rooms\nuser1,2\n"), FormatCSV)
+	assert.ErrorContains(t, err, `unknown CSV column "rooms"`)
+}
+
+func TestReadJSON(t *testing.T) {
+	input := `[{"user_id": "user1", "urgency": "HIGH"}, {"user_id": "user2", "min_rooms": "two"}, {"user": "user3"}]`
+
+	rows, err := Read(strings.NewReader(input), FormatJSON)
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	assert.NoError(t, rows[0].Err)
+	assert.Equal(t, map[string]string{"min_rooms": validation.CodeInvalidFormat}, fieldCodes(t, rows[1].Err))
+	assert.Error(t, rows[2].Err)
+
+	_, err = Read(strings.NewReader(`{"user_id": "user1"}`), FormatJSON)
+	assert.Error(t, err)
+}
+
+func TestRecordTicket(t *testing.T) {
+	record := Record{
+		UserID:      "user1",
+		UserGroup:   "senior",
+		Urgency:     "URGENCY_LEVEL_MEDIUM",
+		EnqueueTime: "2019-11-04",
+	}
+	ticket, err := record.Ticket(validation.DefaultConfig())
+	require.NoError(t, err)
+	assert.Empty(t, ticket.TicketID)
+	assert.Equal(t, time.Date(2019, 11, 4, 0, 0, 0, 0, time.UTC), ticket.EnqueueTime)
+	assert.Equal(t, commonv1.UserGroup_USER_GROUP_SENIOR, ticket.Request.UserGroup)
+	assert.Nil(t, ticket.Request.FinancialConstraints)
+
+	record.UserGroup = "pensioner"
+	record.EnqueueTime = "04.11.2019"
+	record.Status = "ALLOCATION_STATUS_ALLOCATED"
+	_, err = record.Ticket(validation.DefaultConfig())
+	assert.Equal(t, map[string]string{
+		"user_group":   validation.CodeInvalidEnum,
+		"enqueue_time": validation.CodeInvalidFormat,
+		"status":       validation.CodeInconsistent,
+	}, fieldCodes(t, err))
+}
+
+func TestFormatOf(t *testing.T) {
+	format, err := FormatOf("waitlist.jsonl")
+	require.NoError(t, err)
+	assert.Equal(t, FormatNDJSON, format)
+
+	format, err = FormatOf("waitlist.CSV")
+	require.NoError(t, err)
+	assert.Equal(t, FormatCSV, format)
+
+	_, err = FormatOf("waitlist.xlsx")
+	assert.Error(t, err)
+}
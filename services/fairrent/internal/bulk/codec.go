@@ -0,0 +1,453 @@
+package bulk
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/validation"
+)
+
+// maxLineLength bounds NDJSON lines
+const maxLineLength = 1 << 20
+
+// listSeparator separates the entries of list columns in CSV
+const listSeparator = ";"
+
+// Row is a record read from a file
+type Row struct {
+	Number int // 1-based, not counting the CSV header
+	Record Record
+
+	// Err explains why the row could not be decoded, as an InvalidArgument
+	// status with field violations
+	Err error
+}
+
+// Read decodes every record of a file. Rows that cannot be decoded are
+// returned with an error; files that cannot be read as a whole fail.
+func Read(r io.Reader, format Format) ([]Row, error) {
+	switch format {
+	case FormatJSON:
+		return readJSON(r)
+	case FormatNDJSON:
+		return readNDJSON(r)
+	case FormatCSV:
+		return readCSV(r)
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// readJSON decodes an array of records
+func readJSON(r io.Reader) ([]Row, error) {
+	dec := json.NewDecoder(r)
+	if token, err := dec.Token(); err != nil {
+		return nil, fmt.Errorf("failed to read JSON: %w", err)
+	} else if token != json.Delim('[') {
+		return nil, fmt.Errorf("expected a JSON array of records")
+	}
+
+	var rows []Row
+	for dec.More() {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("failed to read JSON record %d: %w", len(rows)+1, err)
+		}
+		rows = append(rows, decodeJSON(len(rows)+1, raw))
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, fmt.Errorf("failed to read JSON: %w", err)
+	}
+	return rows, nil
+}
+
+// readNDJSON decodes one record per line, skipping blank lines
+func readNDJSON(r io.Reader) ([]Row, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineLength)
+
+	var rows []Row
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		rows = append(rows, decodeJSON(len(rows)+1, []byte(line)))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read NDJSON: %w", err)
+	}
+	return rows, nil
+}
+
+// decodeJSON decodes one record, rejecting unknown fields so that misspelt
+// ones are not silently dropped
+func decodeJSON(number int, data []byte) Row {
+	row := Row{Number: number}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&row.Record); err != nil {
+		v := &validation.Violations{}
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			v.Add(typeErr.Field, validation.CodeInvalidFormat,
+				fmt.Sprintf("%s must be a JSON %s", typeErr.Field, jsonKind(typeErr.Type.Kind().String())))
+		} else {
+			v.Add("", validation.CodeInvalidFormat, "record is not valid: "+err.Error())
+		}
+		row.Err = v.Err()
+	}
+	return row
+}
+
+// jsonKind names the JSON type expected for a Go kind
+func jsonKind(kind string) string {
+	switch {
+	case strings.HasPrefix(kind, "int"), strings.HasPrefix(kind, "float"):
+		return "number"
+	case kind == "slice":
+		return "array"
+	case kind == "map":
+		return "object"
+	default:
+		return kind
+	}
+}
+
+// readCSV decodes rows under a header naming the columns, in any order
+func readCSV(r io.Reader) ([]Row, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	cols := make([]*column, len(header))
+	seen := make(map[string]bool)
+	for i, name := range header {
+		// Spreadsheets may start the file with a byte order mark
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		col, ok := columnsByName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown CSV column %q", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate CSV column %q", name)
+		}
+		seen[name] = true
+		cols[i] = col
+	}
+
+	var rows []Row
+	for {
+		values, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		row := Row{Number: len(rows) + 1}
+		if errors.Is(err, csv.ErrFieldCount) {
+			v := &validation.Violations{}
+			v.Add("", validation.CodeInvalidFormat,
+				fmt.Sprintf("row has %d values for %d columns", len(values), len(cols)))
+			row.Err = v.Err()
+			rows = append(rows, row)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV row %d: %w", row.Number, err)
+		}
+
+		v := &validation.Violations{}
+		for i, value := range values {
+			if value == "" {
+				continue
+			}
+			if err := cols[i].set(&row.Record, value); err != nil {
+				v.Add(cols[i].name, validation.CodeInvalidFormat, fmt.Sprintf("%s %s", cols[i].name, err))
+			}
+		}
+		row.Err = v.Err()
+		rows = append(rows, row)
+	}
+}
+
+// Writer writes records in a format
+type Writer struct {
+	w       io.Writer
+	format  Format
+	csv     *csv.Writer
+	written int
+}
+
+// NewWriter returns a writer of records to w
+func NewWriter(w io.Writer, format Format) *Writer {
+	writer := &Writer{w: w, format: format}
+	if format == FormatCSV {
+		writer.csv = csv.NewWriter(w)
+	}
+	return writer
+}
+
+// Write writes a record
+func (w *Writer) Write(r Record) error {
+	defer func() { w.written++ }()
+
+	switch w.format {
+	case FormatCSV:
+		if w.written == 0 {
+			if err := w.writeHeader(); err != nil {
+				return err
+			}
+		}
+		values := make([]string, len(columns))
+		for i, col := range columns {
+			values[i] = col.get(&r)
+		}
+		return w.csv.Write(values)
+
+	case FormatJSON:
+		data, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		separator := ",\n"
+		if w.written == 0 {
+			separator = "[\n"
+		}
+		_, err = io.WriteString(w.w, separator+string(data))
+		return err
+
+	case FormatNDJSON:
+		data, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w.w, string(data)+"\n")
+		return err
+
+	default:
+		return fmt.Errorf("unknown format %q", w.format)
+	}
+}
+
+// Close completes the file. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	switch w.format {
+	case FormatCSV:
+		if w.written == 0 {
+			if err := w.writeHeader(); err != nil {
+				return err
+			}
+		}
+		w.csv.Flush()
+		return w.csv.Error()
+	case FormatJSON:
+		end := "\n]\n"
+		if w.written == 0 {
+			end = "[]\n"
+		}
+		_, err := io.WriteString(w.w, end)
+		return err
+	}
+	return nil
+}
+
+// writeHeader writes the CSV header
+func (w *Writer) writeHeader() error {
+	header := make([]string, len(columns))
+	for i, col := range columns {
+		header[i] = col.name
+	}
+	return w.csv.Write(header)
+}
+
+// column maps a record field to a CSV column. Zero values are left empty.
+type column struct {
+	name string
+	get  func(*Record) string
+	set  func(*Record, string) error
+}
+
+// columns in the order they are written, named like the JSON fields
+var columns = []*column{
+	stringColumn("ticket_id", func(r *Record) *string { return &r.TicketID }),
+	stringColumn("user_id", func(r *Record) *string { return &r.UserID }),
+	stringColumn("user_group", func(r *Record) *string { return &r.UserGroup }),
+	stringColumn("urgency", func(r *Record) *string { return &r.Urgency }),
+	stringColumn("enqueue_time", func(r *Record) *string { return &r.EnqueueTime }),
+	floatColumn("priority_score", func(r *Record) *float64 { return &r.PriorityScore }),
+	jsonColumn("preferred_locations", "array of locations",
+		func(r *Record) interface{} { return &r.PreferredLocations },
+		func(r *Record) bool { return len(r.PreferredLocations) == 0 }),
+	listColumn("preferred_cities", func(r *Record) *[]string { return &r.PreferredCities }),
+	listColumn("preferred_postal_codes", func(r *Record) *[]string { return &r.PreferredPostalCodes }),
+	listColumn("property_types", func(r *Record) *[]string { return &r.PropertyTypes }),
+	stringColumn("preferred_duration", func(r *Record) *string { return &r.PreferredDuration }),
+	floatColumn("max_monthly_rent", func(r *Record) *float64 { return &r.MaxMonthlyRent }),
+	floatColumn("max_deposit", func(r *Record) *float64 { return &r.MaxDeposit }),
+	floatColumn("min_income_requirement", func(r *Record) *float64 { return &r.MinIncomeRequirement }),
+	boolColumn("accepts_housing_allowance", func(r *Record) *bool { return &r.AcceptsHousingAllowance }),
+	boolColumn("accepts_rent_control", func(r *Record) *bool { return &r.AcceptsRentControl }),
+	intColumn("min_rooms", func(r *Record) *int32 { return &r.MinRooms }),
+	intColumn("max_roommates", func(r *Record) *int32 { return &r.MaxRoommates }),
+	boolColumn("pets_allowed", func(r *Record) *bool { return &r.PetsAllowed }),
+	boolColumn("smoking_allowed", func(r *Record) *bool { return &r.SmokingAllowed }),
+	mapColumn("additional_preferences", func(r *Record) *map[string]string { return &r.AdditionalPreferences }),
+	jsonColumn("accessibility_requirements", "object of flags",
+		func(r *Record) interface{} { return &r.AccessibilityRequirements },
+		func(r *Record) bool { return r.AccessibilityRequirements == nil }),
+	stringColumn("status", func(r *Record) *string { return &r.Status }),
+	floatColumn("score", func(r *Record) *float64 { return &r.Score }),
+	intColumn("policy_version", func(r *Record) *int32 { return &r.PolicyVersion }),
+	intColumn("queue_position", func(r *Record) *int32 { return &r.QueuePosition }),
+	stringColumn("allocated_at", func(r *Record) *string { return &r.AllocatedAt }),
+}
+
+// columnsByName indexes columns by name
+var columnsByName = func() map[string]*column {
+	byName := make(map[string]*column, len(columns))
+	for _, col := range columns {
+		byName[col.name] = col
+	}
+	return byName
+}()
+
+func stringColumn(name string, field func(*Record) *string) *column {
+	return &column{
+		name: name,
+		get:  func(r *Record) string { return *field(r) },
+		set: func(r *Record, s string) error {
+			*field(r) = s
+			return nil
+		},
+	}
+}
+
+func floatColumn(name string, field func(*Record) *float64) *column {
+	return &column{
+		name: name,
+		get: func(r *Record) string {
+			if *field(r) == 0 {
+				return ""
+			}
+			return strconv.FormatFloat(*field(r), 'f', -1, 64)
+		},
+		set: func(r *Record, s string) error {
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return errors.New("must be a number")
+			}
+			*field(r) = f
+			return nil
+		},
+	}
+}
+
+func intColumn(name string, field func(*Record) *int32) *column {
+	return &column{
+		name: name,
+		get: func(r *Record) string {
+			if *field(r) == 0 {
+				return ""
+			}
+			return strconv.Itoa(int(*field(r)))
+		},
+		set: func(r *Record, s string) error {
+			i, err := strconv.ParseInt(s, 10, 32)
+			if err != nil {
+				return errors.New("must be a whole number")
+			}
+			*field(r) = int32(i)
+			return nil
+		},
+	}
+}
+
+func boolColumn(name string, field func(*Record) *bool) *column {
+	return &column{
+		name: name,
+		get: func(r *Record) string {
+			if !*field(r) {
+				return ""
+			}
+			return "true"
+		},
+		set: func(r *Record, s string) error {
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return errors.New("must be true or false")
+			}
+			*field(r) = b
+			return nil
+		},
+	}
+}
+
+// listColumn holds entries separated by semicolons
+func listColumn(name string, field func(*Record) *[]string) *column {
+	return &column{
+		name: name,
+		get:  func(r *Record) string { return strings.Join(*field(r), listSeparator) },
+		set: func(r *Record, s string) error {
+			for _, entry := range strings.Split(s, listSeparator) {
+				if entry = strings.TrimSpace(entry); entry != "" {
+					*field(r) = append(*field(r), entry)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// jsonColumn holds a JSON value, as nested fields are written in JSON
+func jsonColumn(name, kind string, field func(*Record) interface{}, empty func(*Record) bool) *column {
+	return &column{
+		name: name,
+		get: func(r *Record) string {
+			if empty(r) {
+				return ""
+			}
+			data, _ := json.Marshal(field(r))
+			return string(data)
+		},
+		set: func(r *Record, s string) error {
+			dec := json.NewDecoder(strings.NewReader(s))
+			dec.DisallowUnknownFields()
+			if err := dec.Decode(field(r)); err != nil {
+				return errors.New("must be a JSON " + kind)
+			}
+			return nil
+		},
+	}
+}
+
+// mapColumn holds a JSON object of strings
+func mapColumn(name string, field func(*Record) *map[string]string) *column {
+	return &column{
+		name: name,
+		get: func(r *Record) string {
+			if len(*field(r)) == 0 {
+				return ""
+			}
+			data, _ := json.Marshal(*field(r))
+			return string(data)
+		},
+		set: func(r *Record, s string) error {
+			if err := json.Unmarshal([]byte(s), field(r)); err != nil {
+				return errors.New("must be a JSON object of strings")
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,61 @@
+// Package bulk reads and writes queue records for migrations between
+// waitlists, in JSON, NDJSON and CSV. All formats carry the same fields.
+package bulk
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/fairrent/v1"
+)
+
+// Format is a file format of queue records
+type Format string
+
+// Supported formats
+const (
+	FormatJSON   Format = "json"   // a JSON array of records
+	FormatNDJSON Format = "ndjson" // one JSON record per line
+	FormatCSV    Format = "csv"    // a header row naming the columns, then one record per row
+)
+
+// protoFormats maps formats to their protobuf enum values
+var protoFormats = map[Format]fairrentv1.BulkFormat{
+	FormatJSON:   fairrentv1.BulkFormat_BULK_FORMAT_JSON,
+	FormatNDJSON: fairrentv1.BulkFormat_BULK_FORMAT_NDJSON,
+	FormatCSV:    fairrentv1.BulkFormat_BULK_FORMAT_CSV,
+}
+
+// ParseFormat returns the format with the given name
+func ParseFormat(name string) (Format, error) {
+	format := Format(strings.ToLower(name))
+	if _, ok := protoFormats[format]; !ok {
+		return "", fmt.Errorf("unknown format %q, expected json, ndjson or csv", name)
+	}
+	return format, nil
+}
+
+// FormatOf returns the format named by a file's extension
+func FormatOf(path string) (Format, error) {
+	ext := strings.TrimPrefix(filepath.Ext(path), ".")
+	if ext == "jsonl" {
+		return FormatNDJSON, nil
+	}
+	return ParseFormat(ext)
+}
+
+// FormatFromProto returns the format of a protobuf enum value
+func FormatFromProto(f fairrentv1.BulkFormat) (Format, bool) {
+	for format, value := range protoFormats {
+		if value == f {
+			return format, true
+		}
+	}
+	return "", false
+}
+
+// Proto returns the protobuf enum value of the format
+func (f Format) Proto() fairrentv1.BulkFormat {
+	return protoFormats[f]
+}
@@ -0,0 +1,244 @@
+package bulk
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/scheduler"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/validation"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/common/v1"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/fairrent/v1"
+)
+
+// dateLayout is accepted for enqueue times, as legacy waitlists often only
+// record the application date
+const dateLayout = "2006-01-02"
+
+// Record is a ticket as imported or exported. Enums are given by name, with
+// or without their prefix (USER_GROUP_STUDENT or STUDENT), and times in RFC
+// 3339.
+type Record struct {
+	TicketID    string `json:"ticket_id,omitempty"` // generated on import if empty
+	UserID      string `json:"user_id"`
+	UserGroup   string `json:"user_group"`
+	Urgency     string `json:"urgency"`
+	EnqueueTime string `json:"enqueue_time"` // RFC 3339, or a date on import
+
+	PriorityScore           float64           `json:"priority_score,omitempty"`
+	PreferredLocations      []Location        `json:"preferred_locations,omitempty"`
+	PreferredCities         []string          `json:"preferred_cities,omitempty"`
+	PreferredPostalCodes    []string          `json:"preferred_postal_codes,omitempty"`
+	PropertyTypes           []string          `json:"property_types,omitempty"`
+	PreferredDuration       string            `json:"preferred_duration,omitempty"`
+	MaxMonthlyRent          float64           `json:"max_monthly_rent,omitempty"`
+	MaxDeposit              float64           `json:"max_deposit,omitempty"`
+	MinIncomeRequirement    float64           `json:"min_income_requirement,omitempty"`
+	AcceptsHousingAllowance bool              `json:"accepts_housing_allowance,omitempty"`
+	AcceptsRentControl      bool              `json:"accepts_rent_control,omitempty"`
+	MinRooms                int32             `json:"min_rooms,omitempty"`
+	MaxRoommates            int32             `json:"max_roommates,omitempty"`
+	PetsAllowed             bool              `json:"pets_allowed,omitempty"`
+	SmokingAllowed          bool              `json:"smoking_allowed,omitempty"`
+	AdditionalPreferences   map[string]string `json:"additional_preferences,omitempty"`
+
+	AccessibilityRequirements *Accessibility `json:"accessibility_requirements,omitempty"`
+
+	// Set on export. Imports reject records that are not queued.
+	Status        string  `json:"status,omitempty"`
+	Score         float64 `json:"score,omitempty"`
+	PolicyVersion int32   `json:"policy_version,omitempty"`
+	QueuePosition int32   `json:"queue_position,omitempty"`
+	AllocatedAt   string  `json:"allocated_at,omitempty"`
+}
+
+// Location is a preferred location of a record
+type Location struct {
+	Latitude   float64 `json:"latitude"`
+	Longitude  float64 `json:"longitude"`
+	City       string  `json:"city,omitempty"`
+	PostalCode string  `json:"postal_code,omitempty"`
+	Country    string  `json:"country,omitempty"`
+}
+
+// Accessibility holds the accessibility requirements of a record
+type Accessibility struct {
+	WheelchairAccessible  bool `json:"wheelchair_accessible,omitempty"`
+	ElevatorRequired      bool `json:"elevator_required,omitempty"`
+	GroundFloorOnly       bool `json:"ground_floor_only,omitempty"`
+	HearingLoop           bool `json:"hearing_loop,omitempty"`
+	VisualAlerts          bool `json:"visual_alerts,omitempty"`
+	ServiceAnimalFriendly bool `json:"service_animal_friendly,omitempty"`
+}
+
+// NewRecord describes an exported ticket
+func NewRecord(t scheduler.ExportedTicket) Record {
+	r := Record{
+		TicketID:      t.ID,
+		UserID:        t.UserID,
+		EnqueueTime:   t.EnqueueTime.UTC().Format(time.RFC3339Nano),
+		Status:        t.Status.String(),
+		Score:         t.PriorityScore,
+		PolicyVersion: int32(t.PolicyVersion),
+		QueuePosition: int32(t.QueuePosition),
+	}
+	if !t.AllocatedAt.IsZero() {
+		r.AllocatedAt = t.AllocatedAt.UTC().Format(time.RFC3339Nano)
+	}
+
+	if req := t.Request; req != nil {
+		r.UserGroup = req.UserGroup.String()
+		r.Urgency = req.Urgency.String()
+		r.PriorityScore = req.PriorityScore
+		for _, location := range req.PreferredLocations {
+			r.PreferredLocations = append(r.PreferredLocations, Location{
+				Latitude:   location.Latitude,
+				Longitude:  location.Longitude,
+				City:       location.City,
+				PostalCode: location.PostalCode,
+				Country:    location.Country,
+			})
+		}
+		r.PreferredCities = req.PreferredCities
+		r.PreferredPostalCodes = req.PreferredPostalCodes
+		for _, propertyType := range req.PropertyTypes {
+			r.PropertyTypes = append(r.PropertyTypes, propertyType.String())
+		}
+		if req.PreferredDuration != commonv1.LeaseDuration_LEASE_DURATION_UNSPECIFIED {
+			r.PreferredDuration = req.PreferredDuration.String()
+		}
+		if fc := req.FinancialConstraints; fc != nil {
+			r.MaxMonthlyRent = fc.MaxMonthlyRent
+			r.MaxDeposit = fc.MaxDeposit
+			r.MinIncomeRequirement = fc.MinIncomeRequirement
+			r.AcceptsHousingAllowance = fc.AcceptsHousingAllowance
+			r.AcceptsRentControl = fc.AcceptsRentControl
+		}
+		r.MinRooms = req.MinRooms
+		r.MaxRoommates = req.MaxRoommates
+		r.PetsAllowed = req.PetsAllowed
+		r.SmokingAllowed = req.SmokingAllowed
+		r.AdditionalPreferences = req.AdditionalPreferences
+		if ar := req.AccessibilityRequirements; ar != nil {
+			accessibility := Accessibility{
+				WheelchairAccessible:  ar.WheelchairAccessible,
+				ElevatorRequired:      ar.ElevatorRequired,
+				GroundFloorOnly:       ar.GroundFloorOnly,
+				HearingLoop:           ar.HearingLoop,
+				VisualAlerts:          ar.VisualAlerts,
+				ServiceAnimalFriendly: ar.ServiceAnimalFriendly,
+			}
+			if accessibility != (Accessibility{}) {
+				r.AccessibilityRequirements = &accessibility
+			}
+		}
+	}
+	// Preferences changed with UpdateRequest replace the original ones
+	if t.Preferences != nil {
+		r.AdditionalPreferences = t.Preferences
+	}
+	return r
+}
+
+// Ticket converts an import record into a ticket, reporting every invalid
+// field as an InvalidArgument status like API requests. The request is
+// validated as configured.
+func (r Record) Ticket(cfg validation.Config) (scheduler.ImportedTicket, error) {
+	v := &validation.Violations{}
+
+	ticket := scheduler.ImportedTicket{TicketID: r.TicketID}
+	if r.TicketID != "" {
+		validation.Identifier(v, "ticket_id", r.TicketID)
+	}
+
+	switch strings.ToUpper(r.Status) {
+	case "", "QUEUED", commonv1.AllocationStatus_ALLOCATION_STATUS_QUEUED.String():
+	default:
+		v.Add("status", validation.CodeInconsistent, "only queued tickets can be imported", "status", r.Status)
+	}
+
+	if r.EnqueueTime == "" {
+		v.Add("enqueue_time", validation.CodeRequired, "enqueue_time is required")
+	} else if t, err := parseTime(r.EnqueueTime); err != nil {
+		v.Add("enqueue_time", validation.CodeInvalidFormat, "enqueue_time must be an RFC 3339 time or a date")
+	} else {
+		ticket.EnqueueTime = t
+	}
+
+	req := &fairrentv1.EnqueueRequest{
+		UserId:                &commonv1.UserID{Value: r.UserID},
+		UserGroup:             commonv1.UserGroup(parseEnum(v, "user_group", r.UserGroup, commonv1.UserGroup_value, "USER_GROUP_")),
+		Urgency:               commonv1.UrgencyLevel(parseEnum(v, "urgency", r.Urgency, commonv1.UrgencyLevel_value, "URGENCY_LEVEL_")),
+		PreferredCities:       r.PreferredCities,
+		PreferredPostalCodes:  r.PreferredPostalCodes,
+		PreferredDuration:     commonv1.LeaseDuration(parseEnum(v, "preferred_duration", r.PreferredDuration, commonv1.LeaseDuration_value, "LEASE_DURATION_")),
+		MinRooms:              r.MinRooms,
+		MaxRoommates:          r.MaxRoommates,
+		PetsAllowed:           r.PetsAllowed,
+		SmokingAllowed:        r.SmokingAllowed,
+		PriorityScore:         r.PriorityScore,
+		AdditionalPreferences: r.AdditionalPreferences,
+	}
+	for _, location := range r.PreferredLocations {
+		req.PreferredLocations = append(req.PreferredLocations, &commonv1.Location{
+			Latitude:   location.Latitude,
+			Longitude:  location.Longitude,
+			City:       location.City,
+			PostalCode: location.PostalCode,
+			Country:    location.Country,
+		})
+	}
+	if a := r.AccessibilityRequirements; a != nil {
+		req.AccessibilityRequirements = &commonv1.AccessibilityRequirements{
+			WheelchairAccessible:  a.WheelchairAccessible,
+			ElevatorRequired:      a.ElevatorRequired,
+			GroundFloorOnly:       a.GroundFloorOnly,
+			HearingLoop:           a.HearingLoop,
+			VisualAlerts:          a.VisualAlerts,
+			ServiceAnimalFriendly: a.ServiceAnimalFriendly,
+		}
+	}
+	for i, name := range r.PropertyTypes {
+		field := fmt.Sprintf("property_types[%d]", i)
+		req.PropertyTypes = append(req.PropertyTypes,
+			commonv1.PropertyType(parseEnum(v, field, name, commonv1.PropertyType_value, "PROPERTY_TYPE_")))
+	}
+	if r.MaxMonthlyRent != 0 || r.MaxDeposit != 0 || r.MinIncomeRequirement != 0 || r.AcceptsHousingAllowance || r.AcceptsRentControl {
+		req.FinancialConstraints = &commonv1.FinancialConstraints{
+			MaxMonthlyRent:          r.MaxMonthlyRent,
+			MaxDeposit:              r.MaxDeposit,
+			MinIncomeRequirement:    r.MinIncomeRequirement,
+			AcceptsHousingAllowance: r.AcceptsHousingAllowance,
+			AcceptsRentControl:      r.AcceptsRentControl,
+		}
+	}
+	ticket.Request = req
+
+	v.Merge(validation.EnqueueRequest(req, cfg))
+	return ticket, v.Err()
+}
+
+// parseEnum looks up an enum value by name, with or without its prefix. An
+// empty name is the unspecified value.
+func parseEnum(v *validation.Violations, field, name string, values map[string]int32, prefix string) int32 {
+	if name == "" {
+		return 0
+	}
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if value, ok := values[name]; ok {
+		return value
+	}
+	if value, ok := values[prefix+name]; ok {
+		return value
+	}
+	v.Add(field, validation.CodeInvalidEnum, fmt.Sprintf("%s has unknown value %q", field, name), "value", name)
+	return 0
+}
+
+// parseTime parses an RFC 3339 time or a date, taken as midnight UTC
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(dateLayout, s)
+}
@@ -0,0 +1,275 @@
+// Package certs provides TLS for the gRPC server and its clients. Server
+// certificates and client CAs are reloaded from disk when they are rotated,
+// without a restart.
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Client certificate requirements
+const (
+	// ClientAuthRequire rejects callers without a certificate issued by a
+	// client CA
+	ClientAuthRequire = "require"
+
+	// ClientAuthOptional verifies certificates that callers present, but
+	// accepts callers without one, e.g. applicants using bearer tokens
+	ClientAuthOptional = "optional"
+)
+
+// DefaultReloadInterval is how often certificate files are checked for
+// changes by default
+const DefaultReloadInterval = time.Minute
+
+// Config configures TLS for the gRPC server
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+
+	// PEM server certificate chain and private key
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+
+	// PEM bundle of the CAs issuing client certificates; empty does not ask
+	// callers for certificates
+	ClientCAFile string `yaml:"client_ca_file"`
+
+	// require or optional; only used with a client CA
+	ClientAuth string `yaml:"client_auth"`
+
+	// Lowest TLS version accepted: 1.2 or 1.3
+	MinVersion string `yaml:"min_version"`
+
+	// How often the files are checked for rotated certificates
+	ReloadInterval time.Duration `yaml:"reload_interval"`
+}
+
+// DefaultConfig returns TLS settings with TLS off
+func DefaultConfig() Config {
+	return Config{
+		ClientAuth:     ClientAuthRequire,
+		MinVersion:     "1.2",
+		ReloadInterval: DefaultReloadInterval,
+	}
+}
+
+// Validate checks an enabled configuration
+func (c Config) Validate() error {
+	if c.CertFile == "" || c.KeyFile == "" {
+		return fmt.Errorf("cert_file and key_file are required")
+	}
+	if c.ClientCAFile != "" && c.ClientAuth != ClientAuthRequire && c.ClientAuth != ClientAuthOptional {
+		return fmt.Errorf("client_auth must be %s or %s", ClientAuthRequire, ClientAuthOptional)
+	}
+	if _, err := parseVersion(c.MinVersion); err != nil {
+		return err
+	}
+	if c.ReloadInterval <= 0 {
+		return fmt.Errorf("reload_interval must be positive")
+	}
+	return nil
+}
+
+// parseVersion returns the TLS version of a min_version setting
+func parseVersion(version string) (uint16, error) {
+	switch version {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("min_version must be 1.2 or 1.3")
+	}
+}
+
+// Reloader holds the server certificate and client CAs, reloading them when
+// their files change. Handshakes use the files loaded last, so connections
+// established before a rotation are not affected.
+type Reloader struct {
+	config     Config
+	minVersion uint16
+	logger     *zap.Logger
+
+	mu          sync.RWMutex
+	certificate *tls.Certificate
+	clientCAs   *x509.CertPool
+	modTimes    []time.Time
+}
+
+// NewReloader loads the configured certificate and client CAs
+func NewReloader(cfg Config, logger *zap.Logger) (*Reloader, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	minVersion, _ := parseVersion(cfg.MinVersion)
+
+	r := &Reloader{
+		config:     cfg,
+		minVersion: minVersion,
+		logger:     logger,
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// files returns the paths of the files the reloader reads
+func (r *Reloader) files() []string {
+	files := []string{r.config.CertFile, r.config.KeyFile}
+	if r.config.ClientCAFile != "" {
+		files = append(files, r.config.ClientCAFile)
+	}
+	return files
+}
+
+// Reload reads the certificate and client CAs from disk. On failure the
+// previously loaded ones stay in use.
+func (r *Reloader) Reload() error {
+	modTimes := make([]time.Time, 0, 3)
+	for _, path := range r.files() {
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("failed to read TLS file: %w", err)
+		}
+		modTimes = append(modTimes, info.ModTime())
+	}
+
+	certificate, err := tls.LoadX509KeyPair(r.config.CertFile, r.config.KeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load server certificate: %w", err)
+	}
+	leaf, err := x509.ParseCertificate(certificate.Certificate[0])
+	if err != nil {
+		return fmt.Errorf("failed to parse server certificate: %w", err)
+	}
+	certificate.Leaf = leaf
+
+	var clientCAs *x509.CertPool
+	if r.config.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.config.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read client CAs: %w", err)
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in %s", r.config.ClientCAFile)
+		}
+	}
+
+	r.mu.Lock()
+	r.certificate = &certificate
+	r.clientCAs = clientCAs
+	r.modTimes = modTimes
+	r.mu.Unlock()
+
+	r.logger.Info("Loaded TLS certificate",
+		zap.String("subject", leaf.Subject.String()),
+		zap.Time("not_after", leaf.NotAfter),
+		zap.Bool("client_certificates", clientCAs != nil),
+	)
+	if time.Until(leaf.NotAfter) < 7*24*time.Hour {
+		r.logger.Warn("TLS certificate expires soon", zap.Time("not_after", leaf.NotAfter))
+	}
+	return nil
+}
+
+// changed reports whether any file was modified since it was loaded
+func (r *Reloader) changed() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for i, path := range r.files() {
+		info, err := os.Stat(path)
+		if err != nil {
+			// Files being replaced may briefly be missing
+			continue
+		}
+		if !info.ModTime().Equal(r.modTimes[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Run reloads the files when they change until ctx is done
+func (r *Reloader) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.config.ReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !r.changed() {
+				continue
+			}
+			if err := r.Reload(); err != nil {
+				r.logger.Error("Failed to reload TLS certificate, keeping the current one", zap.Error(err))
+			}
+		}
+	}
+}
+
+// ServerConfig returns the TLS configuration of the gRPC server. Every
+// handshake uses the certificate and client CAs loaded last.
+func (r *Reloader) ServerConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: r.minVersion,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+
+			cfg := &tls.Config{
+				MinVersion:   r.minVersion,
+				Certificates: []tls.Certificate{*r.certificate},
+			}
+			if r.clientCAs != nil {
+				cfg.ClientCAs = r.clientCAs
+				cfg.ClientAuth = tls.RequireAndVerifyClientCert
+				if r.config.ClientAuth == ClientAuthOptional {
+					cfg.ClientAuth = tls.VerifyClientCertIfGiven
+				}
+			}
+			return cfg, nil
+		},
+	}
+}
+
+// ClientConfig returns the TLS configuration of a client. caFile verifies
+// the server instead of the system roots, certFile and keyFile present a
+// client certificate, and serverName overrides the name verified; each may
+// be empty.
+func ClientConfig(caFile, certFile, keyFile, serverName string) (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+	}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+	}
+	if certFile != "" || keyFile != "" {
+		certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{certificate}
+	}
+	return cfg, nil
+}
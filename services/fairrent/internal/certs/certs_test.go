@@ -0,0 +1,203 @@
+package certs
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// testCA issues certificates for tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	dir  string
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	ca := &testCA{cert: cert, key: key, dir: t.TempDir()}
+	writePEM(t, filepath.Join(ca.dir, "ca.pem"), "CERTIFICATE", der)
+	return ca
+}
+
+// issue writes a certificate and key for name and returns their paths
+func (ca *testCA) issue(t *testing.T, name string, serial int64, usage x509.ExtKeyUsage, uri string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	if uri != "" {
+		u, err := url.Parse(uri)
+		require.NoError(t, err)
+		template.URIs = []*url.URL{u}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile := filepath.Join(ca.dir, name+".pem")
+	keyFile := filepath.Join(ca.dir, name+"-key.pem")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDER)
+	return certFile, keyFile
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600))
+}
+
+// handshake connects a client to a server over a pipe and returns the
+// server's connection state and the client's error
+func handshake(t *testing.T, server, client *tls.Config) (tls.ConnectionState, error) {
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+	defer clientConn.Close()
+
+	done := make(chan tls.ConnectionState, 1)
+	go func() {
+		conn := tls.Server(serverConn, server)
+		_ = conn.Handshake()
+		done <- conn.ConnectionState()
+		conn.Close()
+	}()
+
+	conn := tls.Client(clientConn, client)
+	err := conn.Handshake()
+	if err == nil {
+		// TLS 1.3 reports client certificate rejections on the first read;
+		// accepted clients read the server closing the connection
+		if _, err = conn.Read(make([]byte, 1)); err == io.EOF {
+			err = nil
+		}
+	}
+	conn.Close()
+	return <-done, err
+}
+
+func TestConfig_Validate(t *testing.T) {
+	cfg := DefaultConfig()
+	assert.Error(t, cfg.Validate())
+
+	cfg.CertFile, cfg.KeyFile = "server.pem", "server-key.pem"
+	assert.NoError(t, cfg.Validate())
+
+	cfg.MinVersion = "1.1"
+	assert.Error(t, cfg.Validate())
+
+	cfg.MinVersion = "1.3"
+	cfg.ClientCAFile = "ca.pem"
+	cfg.ClientAuth = "sometimes"
+	assert.Error(t, cfg.Validate())
+}
+
+func TestReloader_MutualTLS(t *testing.T) {
+	ca := newTestCA(t)
+	certFile, keyFile := ca.issue(t, "fairrent", 2, x509.ExtKeyUsageServerAuth, "")
+	clientCert, clientKey := ca.issue(t, "allocation", 3, x509.ExtKeyUsageClientAuth, "spiffe://wohnfair/allocation")
+
+	cfg := DefaultConfig()
+	cfg.Enabled = true
+	cfg.CertFile, cfg.KeyFile = certFile, keyFile
+	cfg.ClientCAFile = filepath.Join(ca.dir, "ca.pem")
+	reloader, err := NewReloader(cfg, zap.NewNop())
+	require.NoError(t, err)
+
+	client, err := ClientConfig(cfg.ClientCAFile, clientCert, clientKey, "fairrent")
+	require.NoError(t, err)
+	state, err := handshake(t, reloader.ServerConfig(), client)
+	require.NoError(t, err)
+	require.Len(t, state.VerifiedChains, 1)
+	assert.Equal(t, "allocation", state.VerifiedChains[0][0].Subject.CommonName)
+
+	// Required client certificates are enforced
+	anonymous, err := ClientConfig(cfg.ClientCAFile, "", "", "fairrent")
+	require.NoError(t, err)
+	_, err = handshake(t, reloader.ServerConfig(), anonymous)
+	assert.Error(t, err)
+
+	// Optional ones are not
+	cfg.ClientAuth = ClientAuthOptional
+	optional, err := NewReloader(cfg, zap.NewNop())
+	require.NoError(t, err)
+	state, err = handshake(t, optional.ServerConfig(), anonymous)
+	require.NoError(t, err)
+	assert.Empty(t, state.VerifiedChains)
+}
+
+func TestReloader_Rotation(t *testing.T) {
+	ca := newTestCA(t)
+	certFile, keyFile := ca.issue(t, "fairrent", 2, x509.ExtKeyUsageServerAuth, "")
+
+	cfg := DefaultConfig()
+	cfg.Enabled = true
+	cfg.CertFile, cfg.KeyFile = certFile, keyFile
+	cfg.ReloadInterval = 10 * time.Millisecond
+	reloader, err := NewReloader(cfg, zap.NewNop())
+	require.NoError(t, err)
+
+	serial := func() int64 {
+		server, err := reloader.ServerConfig().GetConfigForClient(nil)
+		require.NoError(t, err)
+		leaf, err := x509.ParseCertificate(server.Certificates[0].Certificate[0])
+		require.NoError(t, err)
+		return leaf.SerialNumber.Int64()
+	}
+	assert.Equal(t, int64(2), serial())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reloader.Run(ctx)
+
+	// A broken file keeps the current certificate
+	require.NoError(t, os.WriteFile(keyFile, []byte("not a key"), 0o600))
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(keyFile, future, future))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int64(2), serial())
+
+	ca.issue(t, "fairrent", 4, x509.ExtKeyUsageServerAuth, "")
+	later := future.Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, later, later))
+	require.NoError(t, os.Chtimes(keyFile, later, later))
+	assert.Eventually(t, func() bool { return serial() == 4 }, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,201 @@
+package commitment
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Domain separation prefixes for tree hashes
+const (
+	leafPrefix    = 0x00
+	nodePrefix    = 0x01
+	paddingPrefix = 0x02
+)
+
+// SaltSize is the size of the random salt hiding each ticket commitment
+const SaltSize = 32
+
+var (
+	// ErrTicketNotCommitted is returned when a ticket is not part of a commitment
+	ErrTicketNotCommitted = errors.New("ticket is not part of the queue commitment")
+
+	// ErrInvalidProof is returned when a position proof does not verify
+	ErrInvalidProof = errors.New("invalid position proof")
+)
+
+// Entry is a queued ticket, in queue order, to be committed to
+type Entry struct {
+	TicketID string
+	Score    float64
+}
+
+// Commitment is a Merkle tree over salted ticket commitments ordered by
+// queue position. Only the root is published; each applicant receives the
+// salt for their own ticket and the sibling hashes on the path to the root,
+// which reveal nothing about other tickets.
+type Commitment struct {
+	Epoch     uint64
+	Root      []byte
+	Size      int
+	CreatedAt time.Time
+
+	levels    [][][]byte // levels[0] are the (padded) leaves
+	positions map[string]int
+	salts     map[string][]byte
+	scores    map[string]float64
+}
+
+// Proof shows that a ticket sits at a position under a commitment root
+type Proof struct {
+	Epoch    uint64
+	Root     []byte
+	Size     int
+	Position int // 1-based queue position
+	TicketID string
+	Score    float64
+	Salt     []byte
+	Siblings [][]byte // leaf to root
+}
+
+// Build commits to the given entries, which must already be in queue order
+func Build(epoch uint64, entries []Entry) (*Commitment, error) {
+	c := &Commitment{
+		Epoch:     epoch,
+		Size:      len(entries),
+		CreatedAt: time.Now().UTC(),
+		positions: make(map[string]int, len(entries)),
+		salts:     make(map[string][]byte, len(entries)),
+		scores:    make(map[string]float64, len(entries)),
+	}
+
+	// Pad to a power of two so every proof has a sibling at every level
+	width := 1
+	for width < len(entries) {
+		width *= 2
+	}
+
+	leaves := make([][]byte, width)
+	for i, entry := range entries {
+		if _, exists := c.positions[entry.TicketID]; exists {
+			return nil, fmt.Errorf("ticket %s appears twice", entry.TicketID)
+		}
+
+		salt := make([]byte, SaltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, fmt.Errorf("failed to generate salt: %w", err)
+		}
+
+		c.positions[entry.TicketID] = i
+		c.salts[entry.TicketID] = salt
+		c.scores[entry.TicketID] = entry.Score
+		leaves[i] = LeafHash(salt, entry.TicketID, entry.Score, i+1)
+	}
+	for i := len(entries); i < width; i++ {
+		leaves[i] = paddingHash(i)
+	}
+
+	c.levels = append(c.levels, leaves)
+	for level := leaves; len(level) > 1; {
+		next := make([][]byte, len(level)/2)
+		for i := range next {
+			next[i] = nodeHash(level[2*i], level[2*i+1])
+		}
+		c.levels = append(c.levels, next)
+		level = next
+	}
+	c.Root = c.levels[len(c.levels)-1][0]
+
+	return c, nil
+}
+
+// Prove returns the inclusion proof for a ticket
+func (c *Commitment) Prove(ticketID string) (*Proof, error) {
+	index, exists := c.positions[ticketID]
+	if !exists {
+		return nil, ErrTicketNotCommitted
+	}
+
+	proof := &Proof{
+		Epoch:    c.Epoch,
+		Root:     c.Root,
+		Size:     c.Size,
+		Position: index + 1,
+		TicketID: ticketID,
+		Score:    c.scores[ticketID],
+		Salt:     c.salts[ticketID],
+	}
+	for _, level := range c.levels[:len(c.levels)-1] {
+		proof.Siblings = append(proof.Siblings, level[index^1])
+		index /= 2
+	}
+
+	return proof, nil
+}
+
+// VerifyProof recomputes the root from the ticket's own data and the sibling
+// hashes. The position is bound both by the leaf contents and by the path.
+func VerifyProof(proof *Proof) error {
+	if proof.Position < 1 || proof.Position > proof.Size {
+		return ErrInvalidProof
+	}
+
+	index := proof.Position - 1
+	if index>>uint(len(proof.Siblings)) != 0 {
+		return ErrInvalidProof
+	}
+
+	hash := LeafHash(proof.Salt, proof.TicketID, proof.Score, proof.Position)
+	for _, sibling := range proof.Siblings {
+		if index%2 == 0 {
+			hash = nodeHash(hash, sibling)
+		} else {
+			hash = nodeHash(sibling, hash)
+		}
+		index /= 2
+	}
+
+	if !bytes.Equal(hash, proof.Root) {
+		return ErrInvalidProof
+	}
+	return nil
+}
+
+// LeafHash commits to a ticket, its score and its 1-based position
+func LeafHash(salt []byte, ticketID string, score float64, position int) []byte {
+	h := sha256.New()
+	h.Write([]byte{leafPrefix})
+	h.Write(salt)
+
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(position))
+	h.Write(buf[:])
+	binary.BigEndian.PutUint64(buf[:], math.Float64bits(score))
+	h.Write(buf[:])
+	h.Write([]byte(ticketID))
+
+	return h.Sum(nil)
+}
+
+// nodeHash combines two child hashes
+func nodeHash(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{nodePrefix})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// paddingHash fills unused leaves up to the next power of two
+func paddingHash(index int) []byte {
+	var buf [9]byte
+	buf[0] = paddingPrefix
+	binary.BigEndian.PutUint64(buf[1:], uint64(index))
+	sum := sha256.Sum256(buf[:])
+	return sum[:]
+}
@@ -0,0 +1,69 @@
+package commitment
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommitment_ProveAndVerify(t *testing.T) {
+	for _, size := range []int{1, 2, 5, 8} {
+		t.Run(fmt.Sprintf("size_%d", size), func(t *testing.T) {
+			entries := make([]Entry, size)
+			for i := range entries {
+				entries[i] = Entry{TicketID: fmt.Sprintf("TKT_%d", i), Score: float64(size - i)}
+			}
+
+			c, err := Build(7, entries)
+			require.NoError(t, err)
+			assert.Equal(t, size, c.Size)
+
+			for i, entry := range entries {
+				proof, err := c.Prove(entry.TicketID)
+				require.NoError(t, err)
+				assert.Equal(t, i+1, proof.Position)
+				assert.Equal(t, uint64(7), proof.Epoch)
+				assert.NoError(t, VerifyProof(proof))
+			}
+		})
+	}
+}
+
+func TestVerifyProof_RejectsTampering(t *testing.T) {
+	entries := []Entry{
+		{TicketID: "TKT_a", Score: 3},
+		{TicketID: "TKT_b", Score: 2},
+		{TicketID: "TKT_c", Score: 1},
+	}
+	c, err := Build(1, entries)
+	require.NoError(t, err)
+
+	proof, err := c.Prove("TKT_c")
+	require.NoError(t, err)
+	require.NoError(t, VerifyProof(proof))
+
+	// Claiming a better position
+	moved := *proof
+	moved.Position = 1
+	assert.ErrorIs(t, VerifyProof(&moved), ErrInvalidProof)
+
+	// Claiming a higher score
+	rescored := *proof
+	rescored.Score = 10
+	assert.ErrorIs(t, VerifyProof(&rescored), ErrInvalidProof)
+
+	// Proof against a different root
+	other, err := Build(2, entries)
+	require.NoError(t, err)
+	foreign := *proof
+	foreign.Root = other.Root
+	assert.ErrorIs(t, VerifyProof(&foreign), ErrInvalidProof)
+
+	_, err = c.Prove("TKT_unknown")
+	assert.ErrorIs(t, err, ErrTicketNotCommitted)
+
+	_, err = Build(1, append(entries, Entry{TicketID: "TKT_a"}))
+	assert.Error(t, err)
+}
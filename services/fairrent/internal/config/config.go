@@ -0,0 +1,464 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/auth"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/certs"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/events"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/ratelimit"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/scheduler"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/slo"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/telemetry"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/validation"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the typed form of config/config.yaml
+type Config struct {
+	Service     ServiceConfig     `yaml:"service"`
+	Scheduler   scheduler.Config  `yaml:"scheduler"`
+	Queue       QueueConfig       `yaml:"queue"`
+	Logging     LoggingConfig     `yaml:"logging"`
+	Audit       AuditConfig       `yaml:"audit"`
+	Events      EventsConfig      `yaml:"events"`
+	Reports     ReportsConfig     `yaml:"reports"`
+	Commitments CommitmentsConfig `yaml:"commitments"`
+	SLO         SLOConfig         `yaml:"slo"`
+	Metrics     MetricsConfig     `yaml:"metrics"`
+	Telemetry   TelemetryConfig   `yaml:"telemetry"`
+	Health      HealthConfig      `yaml:"health"`
+	Performance PerformanceConfig `yaml:"performance"`
+	Security    SecurityConfig    `yaml:"security"`
+	Development DevelopmentConfig `yaml:"development"`
+}
+
+// ServiceConfig identifies the service and where it serves gRPC
+type ServiceConfig struct {
+	Name    string `yaml:"name"`    // service.name in traces
+	Version string `yaml:"version"` // service.version in traces
+	Host    string `yaml:"host"`    // empty listens on all interfaces
+	Port    int    `yaml:"port"`
+}
+
+// QueueConfig configures the ticket queue
+type QueueConfig struct {
+	// Most tickets queued at once; Enqueue and imports fail beyond it. Zero
+	// is unlimited.
+	MaxSize int `yaml:"max_size"`
+
+	// Only the in-memory heap is implemented
+	Implementation string                 `yaml:"implementation"`
+	Persistence    QueuePersistenceConfig `yaml:"persistence"`
+}
+
+// QueuePersistenceConfig configures storing the queue outside the process.
+// Not implemented yet, so it must stay disabled.
+type QueuePersistenceConfig struct {
+	Enabled      bool          `yaml:"enabled"`
+	Type         string        `yaml:"type"`
+	SyncInterval time.Duration `yaml:"sync_interval"`
+}
+
+// LoggingConfig configures logging
+type LoggingConfig struct {
+	Level  string `yaml:"level"`  // debug, info, warn or error
+	Format string `yaml:"format"` // json or console
+	Output string `yaml:"output"` // stdout, stderr or a file path
+
+	PII PIIConfig `yaml:"pii"`
+}
+
+// PIIConfig configures pseudonymisation of user identifiers in logs
+type PIIConfig struct {
+	// Hex-encoded HMAC key, generated on first start if missing; empty uses
+	// an ephemeral key, so pseudonyms cannot be re-identified
+	KeyFile string `yaml:"key_file"`
+}
+
+// AuditConfig configures the hash-chained audit log
+type AuditConfig struct {
+	// Path of the append-only log file; empty keeps the log in memory
+	Path string `yaml:"path"`
+}
+
+// EventsConfig configures the ticket event feed
+type EventsConfig struct {
+	// Events retained for consumers resuming from an earlier sequence number
+	Capacity int `yaml:"capacity"`
+
+	// Path of the JSONL feed file; empty keeps the feed in memory
+	Path string `yaml:"path"`
+}
+
+// ReportsConfig configures periodically signed fairness reports
+type ReportsConfig struct {
+	Enabled        bool          `yaml:"enabled"`
+	Interval       time.Duration `yaml:"interval"`
+	SigningKeyFile string        `yaml:"signing_key_file"`
+	Directory      string        `yaml:"directory"`
+	Retention      time.Duration `yaml:"retention"` // zero keeps reports forever
+}
+
+// CommitmentsConfig configures periodic Merkle commitments over the queue order
+type CommitmentsConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	Interval time.Duration `yaml:"interval"`
+}
+
+// SLOConfig configures continuous evaluation of fairness SLOs
+type SLOConfig struct {
+	Enabled    bool           `yaml:"enabled"`
+	Interval   time.Duration  `yaml:"interval"`
+	Objectives slo.Objectives `yaml:",inline"`
+}
+
+// MetricsConfig configures metrics exposition
+type MetricsConfig struct {
+	Prometheus PrometheusConfig    `yaml:"prometheus"`
+	Custom     CustomMetricsConfig `yaml:"custom"`
+}
+
+// PrometheusConfig configures the Prometheus scrape endpoint on the ops server
+type PrometheusConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Port    int    `yaml:"port"`
+	Path    string `yaml:"path"`
+}
+
+// CustomMetricsConfig configures the recorded fairness history
+type CustomMetricsConfig struct {
+	Enabled            bool          `yaml:"enabled"`
+	CollectionInterval time.Duration `yaml:"collection_interval"`
+	RetentionPeriod    time.Duration `yaml:"retention_period"`
+
+	// Path of the JSONL history file; empty keeps the history in memory
+	Path string `yaml:"path"`
+}
+
+// TelemetryConfig configures OpenTelemetry export
+type TelemetryConfig struct {
+	Tracing telemetry.TracingConfig `yaml:"tracing"`
+}
+
+// HealthConfig configures the liveness and readiness endpoints on the ops server
+type HealthConfig struct {
+	Enabled bool          `yaml:"enabled"`
+	Port    int           `yaml:"port"`
+	Path    string        `yaml:"path"`
+	Timeout time.Duration `yaml:"timeout"` // per readiness probe
+}
+
+// PerformanceConfig configures load protection
+type PerformanceConfig struct {
+	RateLimit ratelimit.Config `yaml:"rate_limit"`
+}
+
+// SecurityConfig configures access to the gRPC API
+type SecurityConfig struct {
+	TLS           certs.Config        `yaml:"tls"`
+	Auth          auth.Config         `yaml:"auth"`
+	Authorization AuthorizationConfig `yaml:"authorization"`
+	Validation    validation.Config   `yaml:"validation"`
+}
+
+// AuthorizationConfig configures role-based access per RPC
+type AuthorizationConfig struct {
+	Enabled bool `yaml:"enabled"`
+
+	// YAML role matrix and ownership rules, see config/policies/rbac.yaml
+	PolicyFile string `yaml:"policy_file"`
+}
+
+// DevelopmentConfig holds settings meant for development only
+type DevelopmentConfig struct {
+	// Logs at debug level regardless of logging.level
+	Debug bool `yaml:"debug"`
+
+	// Applies changes to scheduler.alpha and scheduler.group_weights in the
+	// config file without a restart
+	HotReload bool `yaml:"hot_reload"`
+
+	Profiling ProfilingConfig `yaml:"profiling"`
+}
+
+// ProfilingConfig configures pprof endpoints on the ops server
+type ProfilingConfig struct {
+	Enabled bool `yaml:"enabled"`
+	Port    int  `yaml:"port"`
+
+	// Interface to listen on; empty listens on every interface
+	Host string `yaml:"host"`
+}
+
+// Default returns the configuration used when no file is given
+func Default() *Config {
+	return &Config{
+		Service: ServiceConfig{
+			Name:    "fairrent",
+			Version: "0.1.0",
+			Port:    50051,
+		},
+		Scheduler: *scheduler.DefaultConfig(),
+		Queue: QueueConfig{
+			Implementation: "heap",
+		},
+		Logging: LoggingConfig{
+			Level:  "info",
+			Format: "json",
+			Output: "stdout",
+		},
+		Events: EventsConfig{
+			Capacity: events.DefaultCapacity,
+		},
+		Reports: ReportsConfig{
+			Enabled:   false,
+			Interval:  time.Hour,
+			Retention: 365 * 24 * time.Hour,
+		},
+		Commitments: CommitmentsConfig{
+			Enabled:  false,
+			Interval: 15 * time.Minute,
+		},
+		SLO: SLOConfig{
+			Enabled:  false,
+			Interval: time.Minute,
+		},
+		Metrics: MetricsConfig{
+			Prometheus: PrometheusConfig{
+				Enabled: true,
+				Port:    9090,
+				Path:    "/metrics",
+			},
+			Custom: CustomMetricsConfig{
+				Enabled:            false,
+				CollectionInterval: 5 * time.Minute,
+				RetentionPeriod:    180 * 24 * time.Hour,
+			},
+		},
+		Telemetry: TelemetryConfig{
+			Tracing: telemetry.DefaultTracingConfig(),
+		},
+		Health: HealthConfig{
+			Enabled: true,
+			Port:    8080,
+			Path:    "/healthz",
+			Timeout: 5 * time.Second,
+		},
+		Performance: PerformanceConfig{
+			RateLimit: ratelimit.DefaultConfig(),
+		},
+		Security: SecurityConfig{
+			TLS:  certs.DefaultConfig(),
+			Auth: auth.DefaultConfig(),
+			Authorization: AuthorizationConfig{
+				Enabled:    false,
+				PolicyFile: "config/policies/rbac.yaml",
+			},
+			Validation: validation.DefaultConfig(),
+		},
+		Development: DevelopmentConfig{
+			Profiling: ProfilingConfig{
+				Enabled: false,
+				Port:    6060,
+				Host:    "127.0.0.1",
+			},
+		},
+	}
+}
+
+// Load reads a YAML configuration file on top of the defaults, then applies
+// environment overrides (see EnvName) and validates the result. Without a path
+// only the defaults and environment are used. Maps in the file replace their
+// defaults rather than adding to them. Unknown keys are errors, so that
+// misspelt settings are not silently ignored.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		// Maps set in the file replace their defaults instead of being
+		// merged into them
+		var doc yaml.Node
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("failed to parse config file: %w", err)
+		}
+		resetMaps(&doc, reflect.ValueOf(cfg).Elem())
+
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to parse config file: %w", err)
+		}
+	}
+
+	if err := applyEnv(cfg, os.LookupEnv); err != nil {
+		return nil, err
+	}
+	cfg.Scheduler.MaxQueueSize = cfg.Queue.MaxSize
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// resetMaps clears the map-valued settings of a struct that a YAML node sets
+func resetMaps(node *yaml.Node, v reflect.Value) {
+	if node.Kind == yaml.DocumentNode {
+		for _, child := range node.Content {
+			resetMaps(child, v)
+		}
+		return
+	}
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		value := v.Field(i)
+		name, inline := yamlName(field)
+		if inline {
+			resetMaps(node, value)
+			continue
+		}
+
+		child := mappingValue(node, name)
+		if child == nil {
+			continue
+		}
+		switch {
+		case value.Kind() == reflect.Map:
+			value.Set(reflect.Zero(value.Type()))
+		case value.Kind() == reflect.Struct && !isScalar(value.Type()):
+			resetMaps(child, value)
+		}
+	}
+}
+
+// mappingValue returns the value of a key in a YAML mapping, or nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// Validate checks that the settings are consistent and usable
+func (c *Config) Validate() error {
+	if !validPort(c.Service.Port) {
+		return fmt.Errorf("service.port must be between 1 and 65535")
+	}
+	if err := c.Scheduler.Validate(); err != nil {
+		return fmt.Errorf("scheduler: %w", err)
+	}
+	if c.Queue.MaxSize < 0 {
+		return fmt.Errorf("queue.max_size must not be negative")
+	}
+	if c.Queue.Implementation != "" && c.Queue.Implementation != "heap" {
+		return fmt.Errorf("queue.implementation %q is not supported, only heap", c.Queue.Implementation)
+	}
+	if c.Queue.Persistence.Enabled {
+		return fmt.Errorf("queue.persistence is not supported yet; the queue is kept in memory")
+	}
+	switch c.Logging.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		return fmt.Errorf("logging.level must be debug, info, warn or error")
+	}
+	if c.Logging.Format != "json" && c.Logging.Format != "console" {
+		return fmt.Errorf("logging.format must be json or console")
+	}
+	if c.Logging.Output == "" {
+		return fmt.Errorf("logging.output is required")
+	}
+	if c.Events.Capacity <= 0 {
+		return fmt.Errorf("events.capacity must be positive")
+	}
+	if c.Reports.Enabled && c.Reports.Interval <= 0 {
+		return fmt.Errorf("reports.interval must be positive")
+	}
+	if c.Reports.Retention < 0 {
+		return fmt.Errorf("reports.retention must not be negative")
+	}
+	if c.Commitments.Enabled && c.Commitments.Interval <= 0 {
+		return fmt.Errorf("commitments.interval must be positive")
+	}
+	if c.SLO.Enabled && c.SLO.Interval <= 0 {
+		return fmt.Errorf("slo.interval must be positive")
+	}
+	if c.Metrics.Prometheus.Enabled && !validPort(c.Metrics.Prometheus.Port) {
+		return fmt.Errorf("metrics.prometheus.port must be between 1 and 65535")
+	}
+	if c.Metrics.Custom.Enabled && c.Metrics.Custom.CollectionInterval <= 0 {
+		return fmt.Errorf("metrics.custom.collection_interval must be positive")
+	}
+	if c.Metrics.Custom.Enabled && c.Metrics.Custom.RetentionPeriod < c.Metrics.Custom.CollectionInterval {
+		return fmt.Errorf("metrics.custom.retention_period must be at least the collection interval")
+	}
+	if c.Telemetry.Tracing.Enabled {
+		if err := c.Telemetry.Tracing.Validate(); err != nil {
+			return fmt.Errorf("telemetry.tracing: %w", err)
+		}
+	}
+	if c.Health.Enabled && !validPort(c.Health.Port) {
+		return fmt.Errorf("health.port must be between 1 and 65535")
+	}
+	if c.Performance.RateLimit.Enabled {
+		if err := c.Performance.RateLimit.Validate(); err != nil {
+			return fmt.Errorf("performance.rate_limit: %w", err)
+		}
+	}
+	if c.Security.TLS.Enabled {
+		if err := c.Security.TLS.Validate(); err != nil {
+			return fmt.Errorf("security.tls: %w", err)
+		}
+	}
+	if c.Security.Auth.Enabled {
+		if err := c.Security.Auth.Validate(); err != nil {
+			return fmt.Errorf("security.auth: %w", err)
+		}
+		if len(c.Security.Auth.CertificateRoles) > 0 && (!c.Security.TLS.Enabled || c.Security.TLS.ClientCAFile == "") {
+			return fmt.Errorf("security.auth.certificate_roles requires security.tls with a client_ca_file")
+		}
+	}
+	if c.Security.Authorization.Enabled {
+		if !c.Security.Auth.Enabled || c.Security.Auth.Type != auth.TypeJWT {
+			return fmt.Errorf("security.authorization requires security.auth with type %s", auth.TypeJWT)
+		}
+		if c.Security.Authorization.PolicyFile == "" {
+			return fmt.Errorf("security.authorization.policy_file is required")
+		}
+	}
+	if c.Development.Profiling.Enabled && !validPort(c.Development.Profiling.Port) {
+		return fmt.Errorf("development.profiling.port must be between 1 and 65535")
+	}
+	if c.Development.Profiling.Enabled && c.Development.Profiling.Host != "" {
+		port := c.Development.Profiling.Port
+		if (c.Metrics.Prometheus.Enabled && port == c.Metrics.Prometheus.Port) || (c.Health.Enabled && port == c.Health.Port) {
+			return fmt.Errorf("development.profiling.port must not be shared with metrics or health while development.profiling.host is set")
+		}
+	}
+
+	return nil
+}
+
+// validPort reports whether port can be listened on
+func validPort(port int) bool {
+	return port > 0 && port <= 65535
+}
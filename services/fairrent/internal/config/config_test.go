@@ -0,0 +1,113 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/scheduler"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/common/v1"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/fairrent/v1"
+	"go.uber.org/zap"
+)
+
+func TestLoad_ConfigFile(t *testing.T) {
+	cfg, err := Load("../../config/config.yaml")
+	require.NoError(t, err)
+
+	assert.Equal(t, "fairrent", cfg.Service.Name)
+	assert.Equal(t, 50051, cfg.Service.Port)
+	assert.Equal(t, 2.0, cfg.Scheduler.Alpha)
+	assert.Equal(t, 1.5, cfg.Scheduler.GroupWeights["USER_GROUP_REFUGEE"])
+	assert.Equal(t, cfg.Queue.MaxSize, cfg.Scheduler.MaxQueueSize)
+}
+
+func TestLoad_EnvOverrides(t *testing.T) {
+	path := writeConfig(t, `
+scheduler:
+  alpha: 2.0
+  group_weights:
+    USER_GROUP_STUDENT: 1.2
+    USER_GROUP_REFUGEE: 1.5
+`)
+	t.Setenv("FAIRRENT_SCHEDULER_ALPHA", "3.5")
+	t.Setenv("FAIRRENT_SCHEDULER_GROUP_WEIGHTS", "{USER_GROUP_REFUGEE: 1.6}")
+	t.Setenv("FAIRRENT_SCHEDULER_MAX_WAIT_TIME", "720h")
+	t.Setenv("FAIRRENT_LOGGING_LEVEL", "debug")
+	t.Setenv("FAIRRENT_QUEUE_MAX_SIZE", "100")
+
+	cfg, err := Load(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, 3.5, cfg.Scheduler.Alpha)
+	assert.Equal(t, map[string]float64{"USER_GROUP_REFUGEE": 1.6}, cfg.Scheduler.GroupWeights)
+	assert.Equal(t, 720*time.Hour, cfg.Scheduler.MaxWaitTime)
+	assert.Equal(t, "debug", cfg.Logging.Level)
+	assert.Equal(t, 100, cfg.Scheduler.MaxQueueSize)
+
+	t.Setenv("FAIRRENT_SCHEDULER_ALPHA", "high")
+	_, err = Load(path)
+	assert.ErrorContains(t, err, "FAIRRENT_SCHEDULER_ALPHA")
+}
+
+func TestLoad_MapsReplaceDefaults(t *testing.T) {
+	path := writeConfig(t, `
+scheduler:
+  group_weights:
+    USER_GROUP_REFUGEE: 1.5
+`)
+	cfg, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"USER_GROUP_REFUGEE": 1.5}, cfg.Scheduler.GroupWeights)
+
+	// A group left out of the file weighs 1.0, not its built-in weight
+	fr := scheduler.NewFairRent(&cfg.Scheduler, zap.NewNop())
+	ctx := context.Background()
+	ticket, err := fr.Enqueue(ctx, &fairrentv1.EnqueueRequest{
+		UserId:    &commonv1.UserID{Value: "user1"},
+		UserGroup: commonv1.UserGroup_USER_GROUP_HIGH_INCOME,
+		Urgency:   commonv1.UrgencyLevel_URGENCY_LEVEL_HIGH,
+	})
+	require.NoError(t, err)
+	explained, err := fr.ExplainDecision(ctx, &fairrentv1.ExplainDecisionRequest{TicketId: ticket.TicketId})
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, explained.Factors.GroupWeight)
+
+	// Without the key the defaults are kept
+	cfg, err = Load(writeConfig(t, "scheduler:\n  alpha: 1.0\n"))
+	require.NoError(t, err)
+	assert.Equal(t, 0.7, cfg.Scheduler.GroupWeights["USER_GROUP_HIGH_INCOME"])
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	tests := map[string]struct {
+		yaml string
+		err  string
+	}{
+		"non-positive alpha": {"scheduler:\n  alpha: 0\n", "alpha must be positive"},
+		"unknown group":      {"scheduler:\n  group_weights:\n    USER_GROUP_PIRATE: 2\n", "unknown user group"},
+		"negative weight":    {"scheduler:\n  group_weights:\n    USER_GROUP_STUDENT: -1\n", "must be positive"},
+		"unknown key":        {"scheduler:\n  alhpa: 2\n", "alhpa"},
+		"log level":          {"logging:\n  level: verbose\n", "logging.level"},
+		"queue persistence":  {"queue:\n  persistence:\n    enabled: true\n", "queue.persistence"},
+		"shared pprof port":  {"development:\n  profiling:\n    enabled: true\n    port: 8080\n", "development.profiling.port"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Load(writeConfig(t, tt.yaml))
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix starts the names of environment variables overriding settings
+const EnvPrefix = "FAIRRENT_"
+
+// EnvName returns the environment variable that overrides the setting at a
+// dotted YAML path, e.g. FAIRRENT_SCHEDULER_ALPHA for scheduler.alpha
+func EnvName(path string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(path, ".", "_"))
+}
+
+// applyEnv overrides every setting whose environment variable is set. Values
+// are read as YAML, so lists and maps are written in flow style, e.g.
+// FAIRRENT_SCHEDULER_GROUP_WEIGHTS='{USER_GROUP_REFUGEE: 1.6}', and replace
+// the whole list or map. Strings are taken as they are.
+func applyEnv(cfg *Config, lookup func(string) (string, bool)) error {
+	return applyEnvFields(reflect.ValueOf(cfg).Elem(), "", lookup)
+}
+
+// applyEnvFields applies overrides to the fields of a struct under a path
+func applyEnvFields(v reflect.Value, path string, lookup func(string) (string, bool)) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, inline := yamlName(field)
+		if name == "-" {
+			continue
+		}
+
+		fieldPath := path
+		if !inline {
+			if fieldPath != "" {
+				fieldPath += "."
+			}
+			fieldPath += name
+		}
+
+		value := v.Field(i)
+		if value.Kind() == reflect.Struct && !isScalar(value.Type()) {
+			if err := applyEnvFields(value, fieldPath, lookup); err != nil {
+				return err
+			}
+			continue
+		}
+
+		env := EnvName(fieldPath)
+		raw, ok := lookup(env)
+		if !ok {
+			continue
+		}
+		if value.Kind() == reflect.String {
+			value.SetString(raw)
+			continue
+		}
+		parsed := reflect.New(value.Type())
+		if err := yaml.Unmarshal([]byte(raw), parsed.Interface()); err != nil {
+			return fmt.Errorf("invalid %s: %w", env, err)
+		}
+		value.Set(parsed.Elem())
+	}
+	return nil
+}
+
+// yamlName returns the key of a struct field and whether it is inlined
+func yamlName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("yaml")
+	name, opts, _ := strings.Cut(tag, ",")
+	if strings.Contains(opts, "inline") {
+		return "", true
+	}
+	if name == "" {
+		name = strings.ToLower(field.Name)
+	}
+	return name, false
+}
+
+// isScalar reports whether a struct type is decoded from a single YAML value
+func isScalar(t reflect.Type) bool {
+	_, ok := reflect.New(t).Interface().(yaml.Unmarshaler)
+	return ok || t.PkgPath() == "time"
+}
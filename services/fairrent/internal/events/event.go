@@ -0,0 +1,95 @@
+package events
+
+import (
+	"time"
+
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/common/v1"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/fairrent/v1"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Type is the kind of scheduler event
+type Type string
+
+// Event types
+const (
+	TypeEnqueued  Type = "enqueued"
+	TypeUpdated   Type = "updated"
+	TypeCancelled Type = "cancelled"
+	TypeOffered   Type = "offered"
+	TypeAllocated Type = "allocated"
+	TypeImported  Type = "imported"
+)
+
+// protoTypes maps event types to their protobuf enum values
+var protoTypes = map[Type]fairrentv1.SchedulerEventType{
+	TypeEnqueued:  fairrentv1.SchedulerEventType_SCHEDULER_EVENT_TYPE_ENQUEUED,
+	TypeUpdated:   fairrentv1.SchedulerEventType_SCHEDULER_EVENT_TYPE_UPDATED,
+	TypeCancelled: fairrentv1.SchedulerEventType_SCHEDULER_EVENT_TYPE_CANCELLED,
+	TypeOffered:   fairrentv1.SchedulerEventType_SCHEDULER_EVENT_TYPE_OFFERED,
+	TypeAllocated: fairrentv1.SchedulerEventType_SCHEDULER_EVENT_TYPE_ALLOCATED,
+	TypeImported:  fairrentv1.SchedulerEventType_SCHEDULER_EVENT_TYPE_IMPORTED,
+}
+
+// Event is something that happened to a ticket
+type Event struct {
+	Sequence  uint64    `json:"sequence"`
+	Timestamp time.Time `json:"timestamp"`
+	Type      Type      `json:"type"`
+
+	TicketID      string  `json:"ticket_id"`
+	UserID        string  `json:"user_id"`
+	UserGroup     string  `json:"user_group"`
+	Urgency       int     `json:"urgency"`
+	PriorityScore float64 `json:"priority_score"`
+
+	// Fairness policy version that produced the score
+	PolicyVersion int `json:"policy_version,omitempty"`
+
+	// Position after an enqueue, import or update
+	QueuePosition int `json:"queue_position,omitempty"`
+
+	// Property offered or allocated
+	PropertyID string `json:"property_id,omitempty"`
+
+	// Time in the queue, for allocations and cancellations
+	WaitTime time.Duration `json:"wait_time,omitempty"`
+
+	// Cancellation reason
+	Reason string `json:"reason,omitempty"`
+}
+
+// Proto converts the event to its protobuf form
+func (e Event) Proto() *fairrentv1.SchedulerEvent {
+	event := &fairrentv1.SchedulerEvent{
+		Sequence:      e.Sequence,
+		Timestamp:     timestamppb.New(e.Timestamp),
+		Type:          protoTypes[e.Type],
+		TicketId:      &commonv1.TicketID{Value: e.TicketID},
+		UserId:        &commonv1.UserID{Value: e.UserID},
+		UserGroup:     commonv1.UserGroup(commonv1.UserGroup_value[e.UserGroup]),
+		Urgency:       commonv1.UrgencyLevel(e.Urgency),
+		PriorityScore: e.PriorityScore,
+		PolicyVersion: int32(e.PolicyVersion),
+		QueuePosition: int32(e.QueuePosition),
+		Reason:        e.Reason,
+	}
+	if e.PropertyID != "" {
+		event.PropertyId = &commonv1.PropertyID{Value: e.PropertyID}
+	}
+	if e.WaitTime > 0 {
+		event.WaitTime = durationpb.New(e.WaitTime)
+	}
+	return event
+}
+
+// TypeFromProto returns the event type of a protobuf enum value
+func TypeFromProto(t fairrentv1.SchedulerEventType) (Type, bool) {
+	for eventType, value := range protoTypes {
+		if value == t {
+			return eventType, true
+		}
+	}
+	return "", false
+}
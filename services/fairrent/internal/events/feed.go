@@ -0,0 +1,292 @@
+// Package events keeps a sequenced feed of scheduler events that downstream
+// consumers stream and resume from their last acknowledged sequence number.
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultCapacity is the default number of events retained for resuming
+const DefaultCapacity = 100000
+
+// streamBatch is how many events Stream reads from the feed at once
+const streamBatch = 256
+
+// ErrExpired is returned when events after the requested sequence number are
+// no longer retained
+var ErrExpired = errors.New("events after the requested sequence have expired")
+
+// ErrAhead is returned for a sequence number the feed has not reached
+var ErrAhead = errors.New("requested sequence is ahead of the feed")
+
+// Feed is an append-only, in-order sequence of events. It retains the latest
+// events up to its capacity, optionally mirrored to a JSONL file so that
+// sequence numbers and retained events survive a restart.
+type Feed struct {
+	mu sync.RWMutex
+
+	events   []Event // ordered by sequence
+	last     uint64
+	capacity int
+
+	// Closed and replaced by Publish to wake streams
+	published chan struct{}
+
+	path    string
+	file    *os.File // nil for a file-backed feed once it cannot be written
+	expired int      // events dropped since the file was last rewritten
+}
+
+// NewFeed creates an in-memory feed retaining capacity events, or
+// DefaultCapacity if capacity is not positive
+func NewFeed(capacity int) *Feed {
+	if capacity <= 0 {
+		capacity = DefaultCapacity
+	}
+	return &Feed{capacity: capacity, published: make(chan struct{})}
+}
+
+// Open creates a feed backed by a JSONL file, loading the events retained in
+// it. An empty path returns an in-memory feed.
+func Open(path string, capacity int) (*Feed, error) {
+	f := NewFeed(capacity)
+	if path == "" {
+		return f, nil
+	}
+	f.path = path
+
+	if existing, err := os.Open(path); err == nil {
+		scanner := bufio.NewScanner(existing)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			var event Event
+			if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+				existing.Close()
+				return nil, fmt.Errorf("failed to parse event %d: %w", len(f.events)+1, err)
+			}
+			if event.Sequence <= f.last {
+				existing.Close()
+				return nil, fmt.Errorf("event %d follows sequence %d", event.Sequence, f.last)
+			}
+			f.events = append(f.events, event)
+			f.last = event.Sequence
+		}
+		existing.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read event feed: %w", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to open event feed: %w", err)
+	}
+	f.prune()
+
+	// Start from a compacted file without expired events
+	if err := f.rewrite(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Publish appends an event, assigning the next sequence number and, if
+// unset, the timestamp. Open streams are woken up. Events of a file-backed
+// feed are only published once written, so that sequence numbers handed out
+// survive a restart.
+func (f *Feed) Publish(event Event) (Event, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.path != "" && f.file == nil {
+		return Event{}, fmt.Errorf("event feed %s is not open for writing", f.path)
+	}
+
+	event.Sequence = f.last + 1
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now().UTC()
+	}
+
+	if f.file != nil {
+		line, err := json.Marshal(event)
+		if err != nil {
+			return Event{}, fmt.Errorf("failed to encode event: %w", err)
+		}
+		if _, err := f.file.Write(append(line, '\n')); err != nil {
+			return Event{}, fmt.Errorf("failed to write event: %w", err)
+		}
+	}
+
+	f.events = append(f.events, event)
+	f.last = event.Sequence
+	f.prune()
+
+	close(f.published)
+	f.published = make(chan struct{})
+
+	// Rewrite once the file holds more expired events than live ones
+	if f.file != nil && f.expired > len(f.events) {
+		if err := f.rewrite(); err != nil {
+			// Events are still appended to the current file; retry once as
+			// many events have expired again rather than on every publish
+			f.expired = 0
+			return event, err
+		}
+	}
+	return event, nil
+}
+
+// LastSequence returns the sequence number of the latest event, 0 if none
+func (f *Feed) LastSequence() uint64 {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.last
+}
+
+// Since returns up to limit events with sequence numbers above after (all of
+// them if limit is not positive). It fails with ErrExpired if some of those
+// events are no longer retained and with ErrAhead if after is beyond the
+// latest event.
+func (f *Feed) Since(after uint64, limit int) ([]Event, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	events, _, err := f.since(after, limit)
+	return events, err
+}
+
+// since implements Since and also returns the channel closed by the next
+// Publish. Callers hold f.mu.
+func (f *Feed) since(after uint64, limit int) ([]Event, <-chan struct{}, error) {
+	if after > f.last {
+		return nil, nil, fmt.Errorf("%w: %d > %d", ErrAhead, after, f.last)
+	}
+	if after == f.last {
+		return nil, f.published, nil
+	}
+
+	first := f.events[0].Sequence
+	if after+1 < first {
+		return nil, nil, fmt.Errorf("%w: oldest retained event is %d", ErrExpired, first)
+	}
+
+	start := int(after + 1 - first)
+	end := len(f.events)
+	if limit > 0 && start+limit < end {
+		end = start + limit
+	}
+	events := make([]Event, end-start)
+	copy(events, f.events[start:end])
+	return events, f.published, nil
+}
+
+// Stream calls send with every event after the given sequence number, in
+// order, then with each new event as it is published, until ctx is done or
+// send fails. A consumer that falls behind the retained events gets
+// ErrExpired.
+func (f *Feed) Stream(ctx context.Context, after uint64, send func(Event) error) error {
+	for {
+		f.mu.RLock()
+		events, published, err := f.since(after, streamBatch)
+		f.mu.RUnlock()
+		if err != nil {
+			return err
+		}
+
+		for _, event := range events {
+			if err := send(event); err != nil {
+				return err
+			}
+			after = event.Sequence
+		}
+		if len(events) > 0 {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-published:
+		}
+	}
+}
+
+// Close closes the backing file, if any
+func (f *Feed) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// prune drops the oldest events beyond the capacity. The events are
+// resliced rather than copied; append moves the retained ones to a new array
+// once the old one is used up, so a full feed copies its events once every
+// few thousand publishes instead of on each.
+func (f *Feed) prune() {
+	if drop := len(f.events) - f.capacity; drop > 0 {
+		f.events = f.events[drop:]
+		f.expired += drop
+	}
+}
+
+// rewrite replaces the backing file with the retained events. The current
+// file stays open for appends until the new one has taken its place.
+func (f *Feed) rewrite() error {
+	if f.path == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o750); err != nil {
+		return fmt.Errorf("failed to create event feed directory: %w", err)
+	}
+
+	tmp := f.path + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to rewrite event feed: %w", err)
+	}
+	writer := bufio.NewWriter(out)
+	encoder := json.NewEncoder(writer)
+	for _, event := range f.events {
+		if err := encoder.Encode(event); err != nil {
+			out.Close()
+			return fmt.Errorf("failed to encode event: %w", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to rewrite event feed: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to rewrite event feed: %w", err)
+	}
+	if err := os.Rename(tmp, f.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to replace event feed: %w", err)
+	}
+
+	// Appends to the replaced file would be lost, so without the new one
+	// Publish fails until the feed is reopened
+	if f.file != nil {
+		f.file.Close()
+		f.file = nil
+	}
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open event feed for writing: %w", err)
+	}
+	f.file = file
+	f.expired = 0
+	return nil
+}
@@ -0,0 +1,118 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeed_SinceAndRetention(t *testing.T) {
+	feed := NewFeed(3)
+	for i := 0; i < 5; i++ {
+		event, err := feed.Publish(Event{Type: TypeEnqueued, TicketID: "t"})
+		require.NoError(t, err)
+		assert.Equal(t, uint64(i+1), event.Sequence)
+	}
+
+	events, err := feed.Since(2, 0)
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+	assert.Equal(t, uint64(3), events[0].Sequence)
+
+	events, err = feed.Since(3, 1)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, uint64(4), events[0].Sequence)
+
+	events, err = feed.Since(5, 0)
+	require.NoError(t, err)
+	assert.Empty(t, events)
+
+	_, err = feed.Since(1, 0)
+	assert.True(t, errors.Is(err, ErrExpired))
+	_, err = feed.Since(6, 0)
+	assert.True(t, errors.Is(err, ErrAhead))
+}
+
+func TestFeed_ResumesAfterRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	feed, err := Open(path, 10)
+	require.NoError(t, err)
+	_, err = feed.Publish(Event{Type: TypeEnqueued, TicketID: "t1"})
+	require.NoError(t, err)
+	_, err = feed.Publish(Event{Type: TypeAllocated, TicketID: "t1", PropertyID: "p1", WaitTime: time.Hour})
+	require.NoError(t, err)
+	require.NoError(t, feed.Close())
+
+	reopened, err := Open(path, 10)
+	require.NoError(t, err)
+	defer reopened.Close()
+	assert.Equal(t, uint64(2), reopened.LastSequence())
+
+	event, err := reopened.Publish(Event{Type: TypeEnqueued, TicketID: "t2"})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), event.Sequence)
+
+	events, err := reopened.Since(1, 0)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, "p1", events[0].PropertyID)
+	assert.Equal(t, time.Hour, events[0].WaitTime)
+}
+
+func TestFeed_FailedRewrite(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "events.jsonl")
+	feed, err := Open(path, 2)
+	require.NoError(t, err)
+
+	// The temporary file cannot be created while a directory is in its way
+	require.NoError(t, os.MkdirAll(filepath.Join(path+".tmp", "blocked"), 0o750))
+	failed := false
+	for i := 0; i < 6; i++ {
+		if _, err := feed.Publish(Event{Type: TypeEnqueued, TicketID: "t"}); err != nil {
+			failed = true
+		}
+	}
+	assert.True(t, failed)
+	require.NoError(t, feed.Close())
+
+	// Every event was still written to the file
+	require.NoError(t, os.RemoveAll(path+".tmp"))
+	reopened, err := Open(path, 2)
+	require.NoError(t, err)
+	defer reopened.Close()
+	assert.Equal(t, uint64(6), reopened.LastSequence())
+}
+
+func TestFeed_Stream(t *testing.T) {
+	feed := NewFeed(10)
+	_, err := feed.Publish(Event{Type: TypeEnqueued, TicketID: "t1"})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	received := make(chan Event, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- feed.Stream(ctx, 0, func(event Event) error {
+			received <- event
+			return nil
+		})
+	}()
+
+	assert.Equal(t, uint64(1), (<-received).Sequence)
+	_, err = feed.Publish(Event{Type: TypeCancelled, TicketID: "t1"})
+	require.NoError(t, err)
+	event := <-received
+	assert.Equal(t, uint64(2), event.Sequence)
+	assert.Equal(t, TypeCancelled, event.Type)
+
+	cancel()
+	assert.NoError(t, <-done)
+}
@@ -0,0 +1,124 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/common/v1"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/fairrent/v1"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// SnapshotFunc returns the current fairness metrics
+type SnapshotFunc func(ctx context.Context) (*fairrentv1.FairnessMetrics, error)
+
+// Collector periodically records fairness snapshots into a store
+type Collector struct {
+	snapshot SnapshotFunc
+	store    *Store
+	logger   *zap.Logger
+}
+
+// NewCollector creates a new history collector
+func NewCollector(snapshot SnapshotFunc, store *Store, logger *zap.Logger) *Collector {
+	return &Collector{
+		snapshot: snapshot,
+		store:    store,
+		logger:   logger,
+	}
+}
+
+// Run records a snapshot every interval until the context is cancelled
+func (c *Collector) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.Collect(ctx); err != nil {
+			c.logger.Error("Failed to record fairness history", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Collect records the current fairness metrics
+func (c *Collector) Collect(ctx context.Context) error {
+	metrics, err := c.snapshot(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to snapshot metrics: %w", err)
+	}
+	return c.store.Add(PointFromMetrics(metrics, time.Now().UTC()))
+}
+
+// PointFromMetrics extracts the charted values from a metrics snapshot
+func PointFromMetrics(metrics *fairrentv1.FairnessMetrics, at time.Time) Point {
+	point := Point{
+		Timestamp:        at,
+		TotalRequests:    int64(metrics.TotalRequests),
+		TotalAllocations: int64(metrics.TotalAllocations),
+		ActiveRequests:   int64(metrics.ActiveRequests),
+		GiniCoefficient:  metrics.GiniCoefficient,
+		JainIndex:        metrics.GetExtended().GetJainIndex(),
+		MaxWaitTimeRatio: metrics.MaxWaitTimeRatio,
+		AverageWaitTime:  metrics.AverageWaitTime.AsDuration(),
+		P95WaitTime:      metrics.P95WaitTime.AsDuration(),
+	}
+
+	if len(metrics.GroupMetrics) > 0 {
+		point.Groups = make(map[string]*GroupPoint, len(metrics.GroupMetrics))
+	}
+	for _, gm := range metrics.GroupMetrics {
+		point.Groups[gm.UserGroup.String()] = &GroupPoint{
+			AllocationShare: gm.AllocationRate,
+			TargetShare:     gm.TargetAllocationRate,
+			FairnessScore:   gm.FairnessScore,
+			AverageWaitTime: gm.AverageWaitTime.AsDuration(),
+		}
+	}
+
+	return point
+}
+
+// Proto converts the point to protobuf format
+func (p Point) Proto() *fairrentv1.FairnessHistoryPoint {
+	pb := &fairrentv1.FairnessHistoryPoint{
+		Timestamp:        timestamppb.New(p.Timestamp),
+		Samples:          int32(p.Samples),
+		TotalRequests:    p.TotalRequests,
+		TotalAllocations: p.TotalAllocations,
+		ActiveRequests:   p.ActiveRequests,
+		GiniCoefficient:  p.GiniCoefficient,
+		JainIndex:        p.JainIndex,
+		MaxWaitTimeRatio: p.MaxWaitTimeRatio,
+		AverageWaitTime:  durationpb.New(p.AverageWaitTime),
+		P95WaitTime:      durationpb.New(p.P95WaitTime),
+	}
+
+	groups := make([]string, 0, len(p.Groups))
+	for group := range p.Groups {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+
+	for _, group := range groups {
+		gp := p.Groups[group]
+		pb.Groups = append(pb.Groups, &fairrentv1.GroupHistoryPoint{
+			UserGroup:       commonv1.UserGroup(commonv1.UserGroup_value[group]),
+			AllocationShare: gp.AllocationShare,
+			TargetShare:     gp.TargetShare,
+			FairnessScore:   gp.FairnessScore,
+			AverageWaitTime: durationpb.New(gp.AverageWaitTime),
+		})
+	}
+
+	return pb
+}
@@ -0,0 +1,293 @@
+// Package history keeps a time series of fairness snapshots so that trends
+// in group shares and inequality can be charted over months.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Point is a compact fairness snapshot at one instant
+type Point struct {
+	Timestamp        time.Time              `json:"timestamp"`
+	TotalRequests    int64                  `json:"total_requests"`
+	TotalAllocations int64                  `json:"total_allocations"`
+	ActiveRequests   int64                  `json:"active_requests"`
+	GiniCoefficient  float64                `json:"gini_coefficient"`
+	JainIndex        float64                `json:"jain_index"`
+	MaxWaitTimeRatio float64                `json:"max_wait_time_ratio"`
+	AverageWaitTime  time.Duration          `json:"average_wait_time"`
+	P95WaitTime      time.Duration          `json:"p95_wait_time"`
+	Groups           map[string]*GroupPoint `json:"groups,omitempty"`
+
+	// Samples is the number of snapshots aggregated into this point
+	Samples int `json:"-"`
+}
+
+// GroupPoint is one group's share of allocations at one instant
+type GroupPoint struct {
+	AllocationShare float64       `json:"allocation_share"`
+	TargetShare     float64       `json:"target_share"`
+	FairnessScore   float64       `json:"fairness_score"`
+	AverageWaitTime time.Duration `json:"average_wait_time"`
+}
+
+// Store keeps points for the retention period in memory, optionally mirrored
+// to an append-only JSONL file
+type Store struct {
+	mu sync.RWMutex
+
+	points    []Point // ordered by timestamp
+	retention time.Duration
+
+	path    string
+	file    *os.File
+	expired int // points dropped since the file was last rewritten
+}
+
+// NewStore creates a history store keeping points for retention. With a
+// non-empty path, retained points are loaded from the file and new points are
+// appended to it.
+func NewStore(path string, retention time.Duration) (*Store, error) {
+	s := &Store{retention: retention, path: path}
+	if path == "" {
+		return s, nil
+	}
+
+	if existing, err := os.Open(path); err == nil {
+		scanner := bufio.NewScanner(existing)
+		for scanner.Scan() {
+			var point Point
+			if err := json.Unmarshal(scanner.Bytes(), &point); err != nil {
+				existing.Close()
+				return nil, fmt.Errorf("failed to parse history point %d: %w", len(s.points)+1, err)
+			}
+			point.Samples = 1
+			s.points = append(s.points, point)
+		}
+		existing.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read history file: %w", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to open history file: %w", err)
+	}
+
+	sort.SliceStable(s.points, func(i, j int) bool {
+		return s.points[i].Timestamp.Before(s.points[j].Timestamp)
+	})
+	s.prune(time.Now())
+
+	// Start from a compacted file without expired points
+	if err := s.rewrite(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Add stores a point and drops points older than the retention period
+func (s *Store) Add(point Point) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	point.Samples = 1
+	s.points = append(s.points, point)
+	s.prune(point.Timestamp)
+
+	if s.file == nil {
+		return nil
+	}
+
+	// Rewrite once the file holds more expired points than live ones
+	if s.expired > len(s.points) {
+		return s.rewrite()
+	}
+
+	data, err := json.Marshal(point)
+	if err != nil {
+		return fmt.Errorf("failed to encode history point: %w", err)
+	}
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write history point: %w", err)
+	}
+	return nil
+}
+
+// Query returns the points between start and end (zero values leave the
+// range open). With a positive resolution, points are averaged into buckets
+// of that width, each stamped with the bucket start.
+func (s *Store) Query(start, end time.Time, resolution time.Duration) []Point {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var selected []Point
+	for _, point := range s.points {
+		if !start.IsZero() && point.Timestamp.Before(start) {
+			continue
+		}
+		if !end.IsZero() && point.Timestamp.After(end) {
+			continue
+		}
+		selected = append(selected, point)
+	}
+
+	if resolution <= 0 || len(selected) == 0 {
+		return selected
+	}
+	return downsample(selected, resolution)
+}
+
+// Close closes the backing file, if any
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// prune drops points older than the retention period relative to now
+func (s *Store) prune(now time.Time) {
+	if s.retention <= 0 {
+		return
+	}
+
+	cutoff := now.Add(-s.retention)
+	drop := sort.Search(len(s.points), func(i int) bool {
+		return !s.points[i].Timestamp.Before(cutoff)
+	})
+	if drop > 0 {
+		s.points = append([]Point(nil), s.points[drop:]...)
+		s.expired += drop
+	}
+}
+
+// rewrite replaces the backing file with the retained points
+func (s *Store) rewrite() error {
+	if s.path == "" {
+		return nil
+	}
+	if s.file != nil {
+		s.file.Close()
+		s.file = nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o750); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	tmp := s.path + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to rewrite history file: %w", err)
+	}
+	writer := bufio.NewWriter(out)
+	encoder := json.NewEncoder(writer)
+	for _, point := range s.points {
+		if err := encoder.Encode(point); err != nil {
+			out.Close()
+			return fmt.Errorf("failed to encode history point: %w", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to rewrite history file: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to rewrite history file: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to replace history file: %w", err)
+	}
+
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open history file for writing: %w", err)
+	}
+	s.file = file
+	s.expired = 0
+	return nil
+}
+
+// downsample averages ordered points into buckets of the given width
+func downsample(points []Point, resolution time.Duration) []Point {
+	var result []Point
+	bucketStart := points[0].Timestamp.Truncate(resolution)
+	var bucket []Point
+
+	flush := func() {
+		if len(bucket) > 0 {
+			result = append(result, average(bucketStart, bucket))
+		}
+	}
+	for _, point := range points {
+		if start := point.Timestamp.Truncate(resolution); !start.Equal(bucketStart) {
+			flush()
+			bucketStart, bucket = start, nil
+		}
+		bucket = append(bucket, point)
+	}
+	flush()
+
+	return result
+}
+
+// average combines points into one, stamped at timestamp. Counters take the
+// last value; rates and durations are averaged, per group over the points in
+// which the group appears.
+func average(timestamp time.Time, points []Point) Point {
+	last := points[len(points)-1]
+	avg := Point{
+		Timestamp:        timestamp,
+		TotalRequests:    last.TotalRequests,
+		TotalAllocations: last.TotalAllocations,
+		ActiveRequests:   last.ActiveRequests,
+		Samples:          len(points),
+	}
+
+	n := float64(len(points))
+	groupSamples := make(map[string]int)
+	for _, point := range points {
+		avg.GiniCoefficient += point.GiniCoefficient / n
+		avg.JainIndex += point.JainIndex / n
+		avg.MaxWaitTimeRatio += point.MaxWaitTimeRatio / n
+		avg.AverageWaitTime += point.AverageWaitTime / time.Duration(len(points))
+		avg.P95WaitTime += point.P95WaitTime / time.Duration(len(points))
+
+		for group, gp := range point.Groups {
+			if avg.Groups == nil {
+				avg.Groups = make(map[string]*GroupPoint)
+			}
+			sum, exists := avg.Groups[group]
+			if !exists {
+				sum = &GroupPoint{}
+				avg.Groups[group] = sum
+			}
+			sum.AllocationShare += gp.AllocationShare
+			sum.TargetShare += gp.TargetShare
+			sum.FairnessScore += gp.FairnessScore
+			sum.AverageWaitTime += gp.AverageWaitTime
+			groupSamples[group]++
+		}
+	}
+
+	for group, sum := range avg.Groups {
+		k := groupSamples[group]
+		sum.AllocationShare /= float64(k)
+		sum.TargetShare /= float64(k)
+		sum.FairnessScore /= float64(k)
+		sum.AverageWaitTime /= time.Duration(k)
+	}
+
+	return avg
+}
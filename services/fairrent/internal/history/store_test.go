@@ -0,0 +1,61 @@
+package history
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_QueryAndDownsample(t *testing.T) {
+	store, err := NewStore("", 0)
+	require.NoError(t, err)
+
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 6; i++ {
+		require.NoError(t, store.Add(Point{
+			Timestamp:        start.Add(time.Duration(i) * 10 * time.Minute),
+			TotalAllocations: int64(i),
+			GiniCoefficient:  float64(i) / 10,
+			Groups: map[string]*GroupPoint{
+				"USER_GROUP_STUDENT": {AllocationShare: float64(i) / 10},
+			},
+		}))
+	}
+
+	raw := store.Query(start.Add(15*time.Minute), start.Add(45*time.Minute), 0)
+	require.Len(t, raw, 3)
+	assert.Equal(t, int64(2), raw[0].TotalAllocations)
+
+	hourly := store.Query(time.Time{}, time.Time{}, 30*time.Minute)
+	require.Len(t, hourly, 2)
+	assert.Equal(t, start, hourly[0].Timestamp)
+	assert.Equal(t, 3, hourly[0].Samples)
+	assert.InDelta(t, 0.1, hourly[0].GiniCoefficient, 1e-9)
+	assert.InDelta(t, 0.4, hourly[1].Groups["USER_GROUP_STUDENT"].AllocationShare, 1e-9)
+	assert.Equal(t, int64(5), hourly[1].TotalAllocations)
+}
+
+func TestStore_RetentionAndPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	store, err := NewStore(path, 24*time.Hour)
+	require.NoError(t, err)
+
+	now := time.Now().UTC()
+	require.NoError(t, store.Add(Point{Timestamp: now.Add(-48 * time.Hour), GiniCoefficient: 0.9}))
+	require.NoError(t, store.Add(Point{Timestamp: now.Add(-time.Hour), GiniCoefficient: 0.2}))
+	require.NoError(t, store.Add(Point{Timestamp: now, GiniCoefficient: 0.3}))
+	require.NoError(t, store.Close())
+
+	// The expired point is dropped and does not come back after a restart
+	reopened, err := NewStore(path, 24*time.Hour)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	points := reopened.Query(time.Time{}, time.Time{}, 0)
+	require.Len(t, points, 2)
+	assert.Equal(t, 0.2, points[0].GiniCoefficient)
+	assert.Equal(t, 0.3, points[1].GiniCoefficient)
+}
@@ -0,0 +1,185 @@
+// Package ops serves the HTTP operations endpoints of fairrentd: Prometheus
+// metrics, liveness and readiness probes, and optionally pprof.
+package ops
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ReadinessCheck reports why a component is not ready, or nil if it is
+type ReadinessCheck func(ctx context.Context) error
+
+// Server serves operations endpoints. Endpoints are grouped by port, so
+// metrics, health and profiling can listen separately or share a listener.
+// Ports listen on every interface unless bound to a host.
+type Server struct {
+	logger *zap.Logger
+
+	mu      sync.Mutex
+	muxes   map[int]*http.ServeMux
+	hosts   map[int]string
+	servers []*http.Server
+}
+
+// NewServer creates an operations server without endpoints
+func NewServer(logger *zap.Logger) *Server {
+	return &Server{
+		logger: logger,
+		muxes:  make(map[int]*http.ServeMux),
+		hosts:  make(map[int]string),
+	}
+}
+
+// Bind listens on port only at host, e.g. 127.0.0.1 for local access
+func (s *Server) Bind(port int, host string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hosts[port] = host
+}
+
+// Handle serves handler at pattern on the given port
+func (s *Server) Handle(port int, pattern string, handler http.Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mux, exists := s.muxes[port]
+	if !exists {
+		mux = http.NewServeMux()
+		s.muxes[port] = mux
+	}
+	mux.Handle(pattern, handler)
+}
+
+// HandlePprof serves the net/http/pprof endpoints under /debug/pprof/ on
+// port at host; an empty host listens on every interface
+func (s *Server) HandlePprof(host string, port int) {
+	s.Bind(port, host)
+	s.Handle(port, "/debug/pprof/", http.HandlerFunc(pprof.Index))
+	s.Handle(port, "/debug/pprof/cmdline", http.HandlerFunc(pprof.Cmdline))
+	s.Handle(port, "/debug/pprof/profile", http.HandlerFunc(pprof.Profile))
+	s.Handle(port, "/debug/pprof/symbol", http.HandlerFunc(pprof.Symbol))
+	s.Handle(port, "/debug/pprof/trace", http.HandlerFunc(pprof.Trace))
+}
+
+// Start listens on every configured port and serves in the background. If
+// any port cannot be bound, listeners opened so far are closed again.
+func (s *Server) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ports := make([]int, 0, len(s.muxes))
+	for port := range s.muxes {
+		ports = append(ports, port)
+	}
+	sort.Ints(ports)
+
+	listeners := make([]net.Listener, 0, len(ports))
+	for _, port := range ports {
+		lis, err := net.Listen("tcp", net.JoinHostPort(s.hosts[port], strconv.Itoa(port)))
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return fmt.Errorf("failed to listen on ops port %d: %w", port, err)
+		}
+		listeners = append(listeners, lis)
+	}
+
+	for i, lis := range listeners {
+		server := &http.Server{
+			Handler:           s.muxes[ports[i]],
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		s.servers = append(s.servers, server)
+
+		s.logger.Info("Starting ops HTTP server", zap.String("address", lis.Addr().String()))
+		go func(server *http.Server, lis net.Listener) {
+			if err := server.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				s.logger.Error("Ops HTTP server failed", zap.Error(err))
+			}
+		}(server, lis)
+	}
+
+	return nil
+}
+
+// Shutdown gracefully stops all listeners
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs []error
+	for _, server := range s.servers {
+		if err := server.Shutdown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	s.servers = nil
+	return errors.Join(errs...)
+}
+
+// HealthHandler answers liveness probes; it only fails if the process
+// cannot serve HTTP at all
+func HealthHandler(service string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeStatus(w, http.StatusOK, map[string]string{
+			"status":    "ok",
+			"service":   service,
+			"timestamp": time.Now().UTC().Format(time.RFC3339),
+		})
+	})
+}
+
+// ReadinessHandler answers readiness probes by running the named checks with
+// the given timeout. It returns 503 with the failing checks if any fails.
+func ReadinessHandler(timeout time.Duration, checks map[string]ReadinessCheck) http.Handler {
+	names := make([]string, 0, len(checks))
+	for name := range checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		failures := make(map[string]string)
+		for _, name := range names {
+			if err := checks[name](ctx); err != nil {
+				failures[name] = err.Error()
+			}
+		}
+
+		if len(failures) > 0 {
+			writeStatus(w, http.StatusServiceUnavailable, map[string]interface{}{
+				"status": "not ready",
+				"checks": failures,
+			})
+			return
+		}
+		writeStatus(w, http.StatusOK, map[string]string{"status": "ready"})
+	})
+}
+
+// writeStatus writes a JSON status body
+func writeStatus(w http.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,114 @@
+package pii
+
+import (
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/protobuf/proto"
+)
+
+// Redacted replaces the value of sensitive fields
+const Redacted = "[REDACTED]"
+
+// identifierKeys are field keys holding user identifiers, including the
+// token subjects of callers, which are pseudonymised
+var identifierKeys = map[string]bool{
+	"user_id": true,
+	"userid":  true,
+	"subject": true,
+	"actor":   true,
+}
+
+// sensitiveKeys are field keys holding personal request details, which are
+// redacted
+var sensitiveKeys = map[string]bool{
+	"financial_constraints":      true,
+	"max_monthly_rent":           true,
+	"max_deposit":                true,
+	"min_income_requirement":     true,
+	"accessibility_requirements": true,
+	"additional_preferences":     true,
+	"preferences":                true,
+	"preferred_locations":        true,
+	"preferred_postal_codes":     true,
+	"income":                     true,
+	"email":                      true,
+	"phone":                      true,
+	"address":                    true,
+}
+
+// core scrubs fields before passing them to the wrapped core
+type core struct {
+	zapcore.Core
+	p *Pseudonymizer
+}
+
+// NewCore wraps c so that every entry, including fields added with With, has
+// user identifiers pseudonymised and sensitive fields and protobuf messages
+// redacted
+func NewCore(c zapcore.Core, p *Pseudonymizer) zapcore.Core {
+	return &core{Core: c, p: p}
+}
+
+// WrapLogger applies NewCore to every core of logger
+func WrapLogger(logger *zap.Logger, p *Pseudonymizer) *zap.Logger {
+	return logger.WithOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		return NewCore(c, p)
+	}))
+}
+
+// With adds scrubbed fields to the wrapped core
+func (c *core) With(fields []zapcore.Field) zapcore.Core {
+	return &core{Core: c.Core.With(c.scrub(fields)), p: c.p}
+}
+
+// Check adds this core, not the wrapped one, so that Write scrubs fields
+func (c *core) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+// Write writes the entry with scrubbed fields
+func (c *core) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(entry, c.scrub(fields))
+}
+
+// scrub returns fields with identifiers pseudonymised and sensitive values
+// redacted
+func (c *core) scrub(fields []zapcore.Field) []zapcore.Field {
+	scrubbed := make([]zapcore.Field, len(fields))
+	for i, field := range fields {
+		scrubbed[i] = c.scrubField(field)
+	}
+	return scrubbed
+}
+
+// scrubField returns a field safe to log
+func (c *core) scrubField(field zapcore.Field) zapcore.Field {
+	key := strings.ToLower(field.Key)
+
+	switch {
+	case identifierKeys[key]:
+		if field.Type == zapcore.StringType {
+			return zap.String(field.Key, c.p.Pseudonym(field.String))
+		}
+		// commonv1.UserID and other wrappers
+		if id, ok := field.Interface.(interface{ GetValue() string }); ok {
+			return zap.String(field.Key, c.p.Pseudonym(id.GetValue()))
+		}
+		return zap.String(field.Key, Redacted)
+
+	case sensitiveKeys[key]:
+		return zap.String(field.Key, Redacted)
+	}
+
+	// Messages such as EnqueueRequest carry identifiers and constraints
+	if msg, ok := field.Interface.(proto.Message); ok {
+		return zap.String(field.Key, fmt.Sprintf("%s %s", Redacted, msg.ProtoReflect().Descriptor().FullName()))
+	}
+	return field
+}
@@ -0,0 +1,66 @@
+package pii
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestPseudonymizer(t *testing.T) {
+	key, err := LoadOrCreateKey(filepath.Join(t.TempDir(), "keys", "pii.key"))
+	require.NoError(t, err)
+	p, err := NewPseudonymizer(key)
+	require.NoError(t, err)
+
+	pseudonym := p.Pseudonym("user123")
+	assert.Equal(t, pseudonym, p.Pseudonym("user123"))
+	assert.NotEqual(t, pseudonym, p.Pseudonym("user124"))
+	assert.NotContains(t, pseudonym, "user123")
+
+	// A different key gives unlinkable pseudonyms
+	other, err := NewPseudonymizer([]byte("another-key-of-32-bytes-length!!"))
+	require.NoError(t, err)
+	assert.NotEqual(t, pseudonym, other.Pseudonym("user123"))
+
+	// The key holder can re-identify
+	id, ok := p.Reidentify(pseudonym, []string{"user1", "user123", "user9"})
+	require.True(t, ok)
+	assert.Equal(t, "user123", id)
+	_, ok = other.Reidentify(pseudonym, []string{"user123"})
+	assert.False(t, ok)
+
+	_, err = NewPseudonymizer([]byte("short"))
+	assert.Error(t, err)
+}
+
+func TestCore_ScrubsFields(t *testing.T) {
+	p, err := NewPseudonymizer([]byte("0123456789abcdef0123456789abcdef"))
+	require.NoError(t, err)
+	observed, logs := observer.New(zapcore.DebugLevel)
+	logger := WrapLogger(zap.New(observed), p)
+
+	logger.With(zap.String("user_id", "alice")).Info("Enqueue request received",
+		zap.String("user_group", "USER_GROUP_STUDENT"),
+		zap.Float64("max_monthly_rent", 800),
+		zap.Any("request", wrapperspb.String("alice")),
+		zap.Any("userId", wrapperspb.String("alice")),
+		zap.String("subject", "alice"),
+		zap.String("actor", "alice"),
+	)
+
+	require.Equal(t, 1, logs.Len())
+	fields := logs.All()[0].ContextMap()
+	assert.Equal(t, p.Pseudonym("alice"), fields["user_id"])
+	assert.Equal(t, p.Pseudonym("alice"), fields["userId"])
+	assert.Equal(t, p.Pseudonym("alice"), fields["subject"])
+	assert.Equal(t, p.Pseudonym("alice"), fields["actor"])
+	assert.Equal(t, "USER_GROUP_STUDENT", fields["user_group"])
+	assert.Equal(t, Redacted, fields["max_monthly_rent"])
+	assert.Equal(t, Redacted+" google.protobuf.StringValue", fields["request"])
+}
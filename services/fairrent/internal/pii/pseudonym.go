@@ -0,0 +1,113 @@
+// Package pii keeps personal data out of logs: user identifiers are replaced
+// by keyed-hash pseudonyms and sensitive request fields are redacted.
+package pii
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// KeySize is the size of generated pseudonymisation keys in bytes
+const KeySize = 32
+
+// pseudonymPrefix marks pseudonymised user IDs
+const pseudonymPrefix = "u_"
+
+// pseudonymBytes is how much of the HMAC is kept in a pseudonym
+const pseudonymBytes = 12
+
+// Pseudonymizer maps identifiers to stable pseudonyms with HMAC-SHA256.
+// Without the key a pseudonym cannot be linked to its identifier; with it,
+// an operator can re-identify a user by recomputing pseudonyms.
+type Pseudonymizer struct {
+	key []byte
+}
+
+// NewPseudonymizer creates a pseudonymizer with the given key
+func NewPseudonymizer(key []byte) (*Pseudonymizer, error) {
+	if len(key) < 16 {
+		return nil, fmt.Errorf("pseudonymisation key must be at least 16 bytes, got %d", len(key))
+	}
+	return &Pseudonymizer{key: append([]byte(nil), key...)}, nil
+}
+
+// Pseudonym returns the pseudonym of an identifier
+func (p *Pseudonymizer) Pseudonym(id string) string {
+	return pseudonymPrefix + hex.EncodeToString(p.mac(id))
+}
+
+// Matches reports whether pseudonym belongs to id
+func (p *Pseudonymizer) Matches(pseudonym, id string) bool {
+	raw, err := hex.DecodeString(strings.TrimPrefix(pseudonym, pseudonymPrefix))
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(raw, p.mac(id))
+}
+
+// Reidentify returns the candidate whose pseudonym is pseudonym
+func (p *Pseudonymizer) Reidentify(pseudonym string, candidates []string) (string, bool) {
+	for _, id := range candidates {
+		if p.Matches(pseudonym, id) {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+// mac returns the truncated HMAC of an identifier
+func (p *Pseudonymizer) mac(id string) []byte {
+	h := hmac.New(sha256.New, p.key)
+	h.Write([]byte(id))
+	return h.Sum(nil)[:pseudonymBytes]
+}
+
+// GenerateKey returns a new random key
+func GenerateKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate pseudonymisation key: %w", err)
+	}
+	return key, nil
+}
+
+// LoadKey reads a hex-encoded key file
+func LoadKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pseudonymisation key: %w", err)
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode pseudonymisation key: %w", err)
+	}
+	return key, nil
+}
+
+// LoadOrCreateKey reads the key at path, generating and storing a new one if
+// the file does not exist
+func LoadOrCreateKey(path string) ([]byte, error) {
+	if _, err := os.Stat(path); err == nil {
+		return LoadKey(path)
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to stat pseudonymisation key: %w", err)
+	}
+
+	key, err := GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create key directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(hex.EncodeToString(key)+"\n"), 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write pseudonymisation key: %w", err)
+	}
+	return key, nil
+}
@@ -2,6 +2,7 @@ package queue
 
 import (
 	"container/heap"
+	"math"
 	"time"
 )
 
@@ -13,7 +14,29 @@ type Ticket struct {
 	Urgency       int
 	EnqueueTime   time.Time
 	PriorityScore float64
-	Constraints   interface{} // Will be the protobuf request
+	Factors       ScoreFactors
+	Constraints   interface{}       // Will be the protobuf request
+	Preferences   map[string]string // free-form, set with UpdateRequest
+}
+
+// ScoreFactors records the inputs that produced a ticket's priority score.
+// Scores do not age: time in the queue only breaks ties between equal scores.
+type ScoreFactors struct {
+	UrgencyComponent float64
+	GroupWeight      float64
+	PriorityBonus    float64
+	Alpha            float64
+	PolicyVersion    int // policy that supplied GroupWeight and Alpha
+}
+
+// BasePriority returns the priority before the α exponent is applied
+func (f ScoreFactors) BasePriority() float64 {
+	return f.UrgencyComponent*f.GroupWeight + f.PriorityBonus
+}
+
+// Score returns the α-fair priority score
+func (f ScoreFactors) Score() float64 {
+	return math.Pow(f.BasePriority(), f.Alpha)
 }
 
 // PriorityQueue implements heap.Interface for managing tickets by priority
@@ -0,0 +1,69 @@
+// Package ratelimit limits gRPC calls with token buckets, globally, per
+// method and per principal.
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// Limit is a sustained rate with a burst allowance. A zero rate is
+// unlimited.
+type Limit struct {
+	RequestsPerSecond float64 `yaml:"requests_per_second"`
+	BurstSize         int     `yaml:"burst_size"`
+}
+
+// Unlimited reports whether the limit lets every call through
+func (l Limit) Unlimited() bool {
+	return l.RequestsPerSecond <= 0
+}
+
+// burst is the bucket capacity, at least one call
+func (l Limit) burst() float64 {
+	if l.BurstSize < 1 {
+		return 1
+	}
+	return float64(l.BurstSize)
+}
+
+// bucket is a token bucket, refilled at the limit's rate up to its burst
+type bucket struct {
+	limit  Limit
+	tokens float64
+	last   time.Time
+}
+
+// newBucket creates a full bucket
+func newBucket(limit Limit, now time.Time) *bucket {
+	return &bucket{limit: limit, tokens: limit.burst(), last: now}
+}
+
+// refill adds the tokens accrued since the last refill
+func (b *bucket) refill(now time.Time) {
+	if now.After(b.last) {
+		b.tokens = math.Min(b.limit.burst(), b.tokens+now.Sub(b.last).Seconds()*b.limit.RequestsPerSecond)
+		b.last = now
+	}
+}
+
+// wait returns how long until a token is available, zero if one is
+func (b *bucket) wait(now time.Time) time.Duration {
+	b.refill(now)
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.limit.RequestsPerSecond * float64(time.Second))
+}
+
+// take removes a token; wait must have returned zero
+func (b *bucket) take() {
+	b.tokens--
+}
+
+// full reports whether the bucket is back at its burst, so that dropping it
+// loses nothing
+func (b *bucket) full(now time.Time) bool {
+	b.refill(now)
+	return b.tokens >= b.limit.burst()
+}
@@ -0,0 +1,339 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/auth"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Scopes a call can be limited in, used as metric labels
+const (
+	ScopeGlobal    = "global"
+	ScopeMethod    = "method"
+	ScopePrincipal = "principal"
+)
+
+// RetryAfterKey is the metadata key telling a limited caller how many
+// seconds to wait before retrying
+const RetryAfterKey = "retry-after"
+
+// sweepInterval is how often idle principal buckets are dropped
+const sweepInterval = time.Minute
+
+// Config configures rate limits
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Limit across all callers and methods
+	Limit `yaml:",inline"`
+
+	// Limits per method across all callers, by full or short method name
+	Methods map[string]Limit `yaml:"methods"`
+
+	// Limit per caller: the token subject, or the peer address for
+	// unauthenticated calls
+	PerPrincipal Limit `yaml:"per_principal"`
+
+	// Per-caller limits for principals with a role, overriding PerPrincipal.
+	// A principal with several of these roles gets the highest limit.
+	Roles map[auth.Role]Limit `yaml:"roles"`
+}
+
+// DefaultConfig returns rate limit settings with limiting off
+func DefaultConfig() Config {
+	return Config{
+		Limit:        Limit{RequestsPerSecond: 1000, BurstSize: 100},
+		PerPrincipal: Limit{RequestsPerSecond: 10, BurstSize: 20},
+	}
+}
+
+// Validate checks the limits
+func (c Config) Validate() error {
+	check := func(name string, l Limit) error {
+		if l.RequestsPerSecond < 0 || l.BurstSize < 0 {
+			return fmt.Errorf("%s: requests_per_second and burst_size must not be negative", name)
+		}
+		return nil
+	}
+	if err := check("global", c.Limit); err != nil {
+		return err
+	}
+	if err := check("per_principal", c.PerPrincipal); err != nil {
+		return err
+	}
+	for method, l := range c.Methods {
+		if err := check("methods."+method, l); err != nil {
+			return err
+		}
+	}
+	for role, l := range c.Roles {
+		if !role.Known() {
+			return fmt.Errorf("roles: unknown role %q", role)
+		}
+		if err := check("roles."+string(role), l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Limiter enforces the configured limits with token buckets
+type Limiter struct {
+	config Config
+	now    func() time.Time
+	logger *zap.Logger
+
+	mu         sync.Mutex
+	global     *bucket
+	methods    map[string]*bucket // by full method name, nil if unlimited
+	principals map[string]*bucket
+	lastSweep  time.Time
+
+	// Metrics
+	allowed         *prometheus.CounterVec
+	limited         *prometheus.CounterVec
+	tokens          *prometheus.GaugeVec
+	principalTokens prometheus.Histogram
+	principalCount  prometheus.Gauge
+}
+
+// NewLimiter creates a limiter registering its metrics with reg, or leaving
+// them unregistered if reg is nil
+func NewLimiter(cfg Config, reg prometheus.Registerer, logger *zap.Logger) *Limiter {
+	factory := promauto.With(reg)
+	now := time.Now()
+
+	l := &Limiter{
+		config:     cfg,
+		now:        time.Now,
+		logger:     logger,
+		methods:    make(map[string]*bucket),
+		principals: make(map[string]*bucket),
+		lastSweep:  now,
+		allowed: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "fairrent_rate_limit_allowed_total",
+			Help: "Calls let through by the rate limiter, by method",
+		}, []string{"method"}),
+		limited: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "fairrent_rate_limit_limited_total",
+			Help: "Calls rejected by the rate limiter, by method and the scope whose limit was hit",
+		}, []string{"method", "scope"}),
+		tokens: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "fairrent_rate_limit_tokens",
+			Help: "Tokens left in the global bucket and in each method's bucket",
+		}, []string{"bucket"}),
+		principalTokens: factory.NewHistogram(prometheus.HistogramOpts{
+			Name:    "fairrent_rate_limit_principal_tokens_ratio",
+			Help:    "Tokens left in a caller's bucket after an allowed call, as a share of its burst size",
+			Buckets: prometheus.LinearBuckets(0, 0.1, 11),
+		}),
+		principalCount: factory.NewGauge(prometheus.GaugeOpts{
+			Name: "fairrent_rate_limit_principals",
+			Help: "Callers with a bucket; idle callers are dropped once their bucket is full again",
+		}),
+	}
+	if !cfg.Limit.Unlimited() {
+		l.global = newBucket(cfg.Limit, now)
+	}
+	return l
+}
+
+// Allow takes a token for a call to method by the caller with the given key
+// and roles. If a limit is exhausted, it returns how long to wait and the
+// scope of the limit; no tokens are taken then.
+func (l *Limiter) Allow(method, caller string, roles []auth.Role) (time.Duration, string) {
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	type scoped struct {
+		scope  string
+		bucket *bucket
+	}
+	var buckets []scoped
+	if l.global != nil {
+		buckets = append(buckets, scoped{ScopeGlobal, l.global})
+	}
+	if b := l.methodBucket(method, now); b != nil {
+		buckets = append(buckets, scoped{ScopeMethod, b})
+	}
+	principal := l.principalBucket(caller, roles, now)
+	if principal != nil {
+		buckets = append(buckets, scoped{ScopePrincipal, principal})
+	}
+
+	// Only take tokens once every bucket has one, so that a call rejected by
+	// one limit does not use up the others
+	var wait time.Duration
+	scope := ""
+	for _, s := range buckets {
+		if w := s.bucket.wait(now); w > wait {
+			wait, scope = w, s.scope
+		}
+	}
+	if wait > 0 {
+		l.limited.WithLabelValues(method, scope).Inc()
+		return wait, scope
+	}
+	for _, s := range buckets {
+		s.bucket.take()
+	}
+
+	l.allowed.WithLabelValues(method).Inc()
+	if l.global != nil {
+		l.tokens.WithLabelValues(ScopeGlobal).Set(l.global.tokens)
+	}
+	if b := l.methods[method]; b != nil {
+		l.tokens.WithLabelValues(method).Set(b.tokens)
+	}
+	if principal != nil {
+		l.principalTokens.Observe(principal.tokens / principal.limit.burst())
+	}
+	return 0, ""
+}
+
+// methodBucket returns the bucket of a method, nil if it is unlimited
+func (l *Limiter) methodBucket(method string, now time.Time) *bucket {
+	if b, exists := l.methods[method]; exists {
+		return b
+	}
+
+	limit, ok := l.config.Methods[method]
+	if !ok {
+		limit, ok = l.config.Methods[method[strings.LastIndex(method, "/")+1:]]
+	}
+	var b *bucket
+	if ok && !limit.Unlimited() {
+		b = newBucket(limit, now)
+	}
+	l.methods[method] = b
+	return b
+}
+
+// principalBucket returns the bucket of a caller, nil if callers are
+// unlimited
+func (l *Limiter) principalBucket(caller string, roles []auth.Role, now time.Time) *bucket {
+	if caller == "" {
+		return nil
+	}
+	if b, exists := l.principals[caller]; exists {
+		return b
+	}
+
+	limit := l.config.PerPrincipal
+	var best *Limit
+	for _, role := range roles {
+		override, ok := l.config.Roles[role]
+		if ok && (best == nil || override.Unlimited() || (!best.Unlimited() && override.RequestsPerSecond > best.RequestsPerSecond)) {
+			best = &override
+		}
+	}
+	if best != nil {
+		limit = *best
+	}
+	if limit.Unlimited() {
+		return nil
+	}
+
+	b := newBucket(limit, now)
+	l.principals[caller] = b
+	l.principalCount.Set(float64(len(l.principals)))
+	return b
+}
+
+// sweep drops the buckets of callers that have been idle long enough for
+// their bucket to refill
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for caller, b := range l.principals {
+		if b.full(now) {
+			delete(l.principals, caller)
+		}
+	}
+	l.principalCount.Set(float64(len(l.principals)))
+}
+
+// caller identifies the caller of a call for per-principal limits
+func caller(ctx context.Context) (string, []auth.Role) {
+	if p, ok := auth.FromContext(ctx); ok {
+		return "sub:" + p.Subject, p.Roles
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		host, _, err := net.SplitHostPort(p.Addr.String())
+		if err != nil {
+			host = p.Addr.String()
+		}
+		return "peer:" + host, nil
+	}
+	return "", nil
+}
+
+// check limits a call, setting the retry-after metadata with setHeader when
+// it is rejected
+func (l *Limiter) check(ctx context.Context, method string, setHeader func(metadata.MD) error) error {
+	for _, public := range auth.DefaultPublicMethods {
+		if method == public {
+			return nil
+		}
+	}
+
+	key, roles := caller(ctx)
+	wait, scope := l.Allow(method, key, roles)
+	if wait == 0 {
+		return nil
+	}
+
+	seconds := int(math.Ceil(wait.Seconds()))
+	if err := setHeader(metadata.Pairs(RetryAfterKey, strconv.Itoa(seconds))); err != nil {
+		l.logger.Debug("Failed to set retry-after header", zap.Error(err))
+	}
+	l.logger.Debug("Call rate limited",
+		zap.String("method", method),
+		zap.String("scope", scope),
+		zap.Duration("retry_after", wait),
+	)
+	return status.Errorf(codes.ResourceExhausted, "%s rate limit exceeded, retry after %ds", scope, seconds)
+}
+
+// UnaryServerInterceptor limits unary calls. It must run after the
+// authentication interceptor to limit principals rather than addresses.
+func (l *Limiter) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		setHeader := func(md metadata.MD) error { return grpc.SetHeader(ctx, md) }
+		if err := l.check(ctx, info.FullMethod, setHeader); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor limits the opening of streams
+func (l *Limiter) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := l.check(ss.Context(), info.FullMethod, ss.SetHeader); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
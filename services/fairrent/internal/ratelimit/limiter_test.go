@@ -0,0 +1,113 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/auth"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const peek = "/wohnfair.fairrent.v1.FairRentService/PeekPosition"
+
+// newTestLimiter returns a limiter on a fake clock
+func newTestLimiter(cfg Config) (*Limiter, *time.Time) {
+	l := NewLimiter(cfg, nil, zap.NewNop())
+	now := time.Date(2024, 1, 15, 9, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+	l.lastSweep = now
+	if l.global != nil {
+		l.global.last = now
+	}
+	return l, &now
+}
+
+func TestLimiter_Scopes(t *testing.T) {
+	cfg := Config{
+		Limit:        Limit{RequestsPerSecond: 100, BurstSize: 4},
+		Methods:      map[string]Limit{"PeekPosition": {RequestsPerSecond: 1, BurstSize: 3}},
+		PerPrincipal: Limit{RequestsPerSecond: 1, BurstSize: 2},
+		Roles:        map[auth.Role]Limit{auth.RoleService: {}},
+	}
+	l, now := newTestLimiter(cfg)
+
+	// Per principal: a burst of two
+	for i := 0; i < 2; i++ {
+		wait, _ := l.Allow(peek, "sub:a", nil)
+		require.Zero(t, wait)
+	}
+	wait, scope := l.Allow(peek, "sub:a", nil)
+	assert.Equal(t, ScopePrincipal, scope)
+	assert.Equal(t, time.Second, wait)
+
+	// Per method: one token left for other callers
+	wait, _ = l.Allow(peek, "sub:b", nil)
+	require.Zero(t, wait)
+	_, scope = l.Allow(peek, "sub:c", nil)
+	assert.Equal(t, ScopeMethod, scope)
+
+	// Services are not limited per principal, but globally
+	wait, _ = l.Allow("/x/Other", "sub:svc", []auth.Role{auth.RoleService})
+	require.Zero(t, wait)
+	_, scope = l.Allow("/x/Other", "sub:svc", []auth.Role{auth.RoleService})
+	assert.Equal(t, ScopeGlobal, scope)
+
+	// Rejected calls take no tokens, and buckets refill over time
+	*now = now.Add(time.Second)
+	wait, _ = l.Allow(peek, "sub:a", nil)
+	assert.Zero(t, wait)
+}
+
+func TestLimiter_SweepsIdleCallers(t *testing.T) {
+	l, now := newTestLimiter(Config{PerPrincipal: Limit{RequestsPerSecond: 1, BurstSize: 5}})
+
+	l.Allow(peek, "sub:a", nil)
+	l.Allow(peek, "sub:b", nil)
+	*now = now.Add(2 * time.Second)
+	for i := 0; i < 5; i++ {
+		l.Allow(peek, "sub:b", nil)
+	}
+
+	*now = now.Add(sweepInterval)
+	l.Allow(peek, "sub:c", nil)
+	assert.NotContains(t, l.principals, "sub:a")
+	assert.Contains(t, l.principals, "sub:c")
+}
+
+// headerStream records the headers set on a stream
+type headerStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	header metadata.MD
+}
+
+func (s *headerStream) Context() context.Context { return s.ctx }
+func (s *headerStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func TestLimiter_StreamInterceptor(t *testing.T) {
+	l, _ := newTestLimiter(Config{PerPrincipal: Limit{RequestsPerSecond: 0.5, BurstSize: 1}})
+	interceptor := l.StreamServerInterceptor()
+	ctx := auth.NewContext(context.Background(), &auth.Principal{Subject: "bot"})
+	handler := func(srv interface{}, stream grpc.ServerStream) error { return nil }
+	info := &grpc.StreamServerInfo{FullMethod: "/wohnfair.fairrent.v1.FairRentService/WatchPosition"}
+
+	stream := &headerStream{ctx: ctx}
+	require.NoError(t, interceptor(nil, stream, info, handler))
+
+	err := interceptor(nil, stream, info, handler)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, []string{"2"}, stream.header.Get(RetryAfterKey))
+
+	// Health checks are never limited
+	require.NoError(t, interceptor(nil, stream, &grpc.StreamServerInfo{FullMethod: "/grpc.health.v1.Health/Watch"}, handler))
+}
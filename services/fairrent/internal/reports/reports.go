@@ -0,0 +1,277 @@
+package reports
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/audit"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/fairrent/v1"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// SignatureAlgorithm identifies the signature scheme used for reports
+const SignatureAlgorithm = "ed25519"
+
+// AuditRecordType is the audit record type that anchors a published report
+const AuditRecordType = "fairness_report"
+
+// signingDomain separates report signatures from any other use of the key
+const signingDomain = "wohnfair.fairrent.fairness-report.v1"
+
+// ErrInvalidSignature is returned when a report does not verify
+var ErrInvalidSignature = errors.New("invalid fairness report signature")
+
+// Report is a signed snapshot of the scheduler's fairness metrics, bound to
+// the audit log head at the time it was taken
+type Report struct {
+	ID          string    `json:"id"`
+	GeneratedAt time.Time `json:"generated_at"`
+
+	// MetricsBytes is the exact FairnessMetrics serialisation that was signed
+	MetricsBytes []byte `json:"metrics_bytes"`
+
+	// Audit log head the snapshot is bound to
+	AuditSequence uint64 `json:"audit_sequence"`
+	AuditHeadHash string `json:"audit_head_hash"`
+
+	// Audit record that anchors this report in the trail
+	AnchorSequence uint64 `json:"anchor_sequence"`
+
+	SignatureAlgorithm string `json:"signature_algorithm"`
+	Signature          []byte `json:"signature"`
+	KeyID              string `json:"key_id"`
+	PublicKey          []byte `json:"public_key"`
+}
+
+// Metrics decodes the signed metrics snapshot
+func (r *Report) Metrics() (*fairrentv1.FairnessMetrics, error) {
+	metrics := &fairrentv1.FairnessMetrics{}
+	if err := proto.Unmarshal(r.MetricsBytes, metrics); err != nil {
+		return nil, fmt.Errorf("failed to decode report metrics: %w", err)
+	}
+	return metrics, nil
+}
+
+// Proto converts the report to protobuf format
+func (r *Report) Proto() (*fairrentv1.FairnessReport, error) {
+	metrics, err := r.Metrics()
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256(r.MetricsBytes)
+	return &fairrentv1.FairnessReport{
+		ReportId:           r.ID,
+		GeneratedAt:        timestamppb.New(r.GeneratedAt),
+		Metrics:            metrics,
+		MetricsBytes:       r.MetricsBytes,
+		AuditSequence:      r.AuditSequence,
+		AuditHeadHash:      r.AuditHeadHash,
+		AnchorSequence:     r.AnchorSequence,
+		SignatureAlgorithm: r.SignatureAlgorithm,
+		Signature:          r.Signature,
+		KeyId:              r.KeyID,
+		PublicKey:          r.PublicKey,
+		MetricsDigest:      digest[:],
+	}, nil
+}
+
+// SigningPayload returns the bytes covered by the report signature:
+// the domain, report ID, generation time, audit head and metrics digest,
+// one per line
+func (r *Report) SigningPayload() []byte {
+	digest := sha256.Sum256(r.MetricsBytes)
+	payload := signingDomain + "\n" +
+		r.ID + "\n" +
+		strconv.FormatInt(r.GeneratedAt.UnixNano(), 10) + "\n" +
+		strconv.FormatUint(r.AuditSequence, 10) + "\n" +
+		r.AuditHeadHash + "\n" +
+		hex.EncodeToString(digest[:])
+	return []byte(payload)
+}
+
+// Verify checks the report signature against the given public key. A nil
+// key verifies against the key embedded in the report.
+func Verify(report *Report, publicKey ed25519.PublicKey) error {
+	if publicKey == nil {
+		publicKey = ed25519.PublicKey(report.PublicKey)
+	}
+	if report.SignatureAlgorithm != SignatureAlgorithm || len(publicKey) != ed25519.PublicKeySize {
+		return ErrInvalidSignature
+	}
+	if !ed25519.Verify(publicKey, report.SigningPayload(), report.Signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// anchorRecord is the audit payload anchoring a report
+type anchorRecord struct {
+	ReportID      string `json:"report_id"`
+	MetricsSHA256 string `json:"metrics_sha256"`
+	AuditSequence uint64 `json:"audit_sequence"`
+	AuditHeadHash string `json:"audit_head_hash"`
+	KeyID         string `json:"key_id"`
+	Signature     []byte `json:"signature"`
+}
+
+// newAnchorRecord describes a report for its anchor
+func newAnchorRecord(report *Report) anchorRecord {
+	digest := sha256.Sum256(report.MetricsBytes)
+	return anchorRecord{
+		ReportID:      report.ID,
+		MetricsSHA256: hex.EncodeToString(digest[:]),
+		AuditSequence: report.AuditSequence,
+		AuditHeadHash: report.AuditHeadHash,
+		KeyID:         report.KeyID,
+		Signature:     report.Signature,
+	}
+}
+
+// VerifyAnchor checks that the report is recorded in the audit log at its
+// anchor sequence, with its ID, metrics digest, audit head, key and
+// signature, and that the bound head precedes the anchor
+func VerifyAnchor(report *Report, log *audit.Log) error {
+	record, err := log.Get(report.AnchorSequence)
+	if err != nil {
+		return fmt.Errorf("report anchor: %w", err)
+	}
+	if record.Type != AuditRecordType {
+		return fmt.Errorf("audit record %d is not a fairness report", record.Sequence)
+	}
+	var anchored anchorRecord
+	if err := json.Unmarshal(record.Payload, &anchored); err != nil {
+		return fmt.Errorf("failed to parse report anchor %d: %w", record.Sequence, err)
+	}
+	expected := newAnchorRecord(report)
+	if anchored.ReportID != expected.ReportID ||
+		anchored.MetricsSHA256 != expected.MetricsSHA256 ||
+		anchored.AuditSequence != expected.AuditSequence ||
+		anchored.AuditHeadHash != expected.AuditHeadHash ||
+		anchored.KeyID != expected.KeyID ||
+		!bytes.Equal(anchored.Signature, expected.Signature) {
+		return fmt.Errorf("audit record %d anchors a different report", record.Sequence)
+	}
+	if report.AuditSequence >= report.AnchorSequence {
+		return fmt.Errorf("report is bound to audit record %d after its anchor %d", report.AuditSequence, report.AnchorSequence)
+	}
+
+	if report.AuditSequence == 0 {
+		if report.AuditHeadHash != audit.GenesisHash {
+			return fmt.Errorf("report audit head does not match the genesis hash")
+		}
+	} else {
+		bound, err := log.Get(report.AuditSequence)
+		if err != nil {
+			return fmt.Errorf("report audit head: %w", err)
+		}
+		if bound.Hash != report.AuditHeadHash {
+			return fmt.Errorf("report audit head does not match audit record %d", bound.Sequence)
+		}
+	}
+
+	return nil
+}
+
+// SnapshotFunc returns the current fairness metrics
+type SnapshotFunc func(ctx context.Context) (*fairrentv1.FairnessMetrics, error)
+
+// Publisher periodically snapshots, signs, anchors and stores fairness reports
+type Publisher struct {
+	snapshot SnapshotFunc
+	signer   *Signer
+	audit    *audit.Log
+	store    *Store
+	logger   *zap.Logger
+}
+
+// NewPublisher creates a new report publisher
+func NewPublisher(snapshot SnapshotFunc, signer *Signer, auditLog *audit.Log, store *Store, logger *zap.Logger) *Publisher {
+	return &Publisher{
+		snapshot: snapshot,
+		signer:   signer,
+		audit:    auditLog,
+		store:    store,
+		logger:   logger,
+	}
+}
+
+// Store returns the store that published reports are written to
+func (p *Publisher) Store() *Store {
+	return p.store
+}
+
+// Run publishes a report every interval until the context is cancelled
+func (p *Publisher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := p.Publish(ctx); err != nil {
+				p.logger.Error("Failed to publish fairness report", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Publish takes a metrics snapshot, binds it to the current audit log head,
+// signs it, records it in the audit log and stores it
+func (p *Publisher) Publish(ctx context.Context) (*Report, error) {
+	metrics, err := p.snapshot(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to snapshot metrics: %w", err)
+	}
+
+	metricsBytes, err := proto.MarshalOptions{Deterministic: true}.Marshal(metrics)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode metrics: %w", err)
+	}
+
+	generatedAt := time.Now().UTC()
+	auditSequence, auditHead := p.audit.Head()
+
+	report := &Report{
+		ID:                 fmt.Sprintf("RPT_%d", generatedAt.UnixNano()),
+		GeneratedAt:        generatedAt,
+		MetricsBytes:       metricsBytes,
+		AuditSequence:      auditSequence,
+		AuditHeadHash:      auditHead,
+		SignatureAlgorithm: SignatureAlgorithm,
+		KeyID:              p.signer.KeyID(),
+		PublicKey:          p.signer.PublicKey(),
+	}
+	report.Signature = p.signer.Sign(report.SigningPayload())
+
+	anchor, err := p.audit.Append(AuditRecordType, "fairrentd", newAnchorRecord(report))
+	if err != nil {
+		return nil, fmt.Errorf("failed to anchor report: %w", err)
+	}
+	report.AnchorSequence = anchor.Sequence
+
+	if err := p.store.Save(report); err != nil {
+		return nil, err
+	}
+
+	p.logger.Info("Fairness report published",
+		zap.String("report_id", report.ID),
+		zap.Uint64("audit_sequence", report.AuditSequence),
+		zap.Uint64("anchor_sequence", report.AnchorSequence),
+		zap.Float64("gini_coefficient", metrics.GiniCoefficient),
+	)
+
+	return report, nil
+}
@@ -0,0 +1,111 @@
+package reports
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/audit"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/fairrent/v1"
+	"go.uber.org/zap"
+)
+
+func TestPublisher_PublishAndVerify(t *testing.T) {
+	dir := t.TempDir()
+
+	signer, err := LoadOrCreateSigner(filepath.Join(dir, "keys", "report.key"))
+	require.NoError(t, err)
+	store, err := NewStore(filepath.Join(dir, "reports"), 0)
+	require.NoError(t, err)
+
+	auditLog := audit.NewLog()
+	_, err = auditLog.Append("ticket_allocated", "", nil)
+	require.NoError(t, err)
+	headSeq, headHash := auditLog.Head()
+
+	snapshot := func(ctx context.Context) (*fairrentv1.FairnessMetrics, error) {
+		return &fairrentv1.FairnessMetrics{Alpha: 2.0, GiniCoefficient: 0.25}, nil
+	}
+	publisher := NewPublisher(snapshot, signer, auditLog, store, zap.NewNop())
+
+	report, err := publisher.Publish(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, headSeq, report.AuditSequence)
+	assert.Equal(t, headHash, report.AuditHeadHash)
+	assert.Equal(t, headSeq+1, report.AnchorSequence)
+	require.NoError(t, Verify(report, signer.PublicKey()))
+	require.NoError(t, VerifyAnchor(report, auditLog))
+
+	metrics, err := report.Metrics()
+	require.NoError(t, err)
+	assert.Equal(t, 0.25, metrics.GiniCoefficient)
+
+	// The digest lets callers without the metrics bytes verify the signature
+	pb, err := report.Proto()
+	require.NoError(t, err)
+	digest := sha256.Sum256(report.MetricsBytes)
+	assert.Equal(t, digest[:], pb.MetricsDigest)
+
+	// Reports survive a restart of the store
+	reloaded, err := NewStore(filepath.Join(dir, "reports"), 0)
+	require.NoError(t, err)
+	stored, err := reloaded.Get("")
+	require.NoError(t, err)
+	require.NoError(t, Verify(stored, signer.PublicKey()))
+
+	// The same key is loaded again from disk
+	again, err := LoadOrCreateSigner(filepath.Join(dir, "keys", "report.key"))
+	require.NoError(t, err)
+	assert.Equal(t, signer.KeyID(), again.KeyID())
+
+	// Any change to the signed content invalidates the report
+	stored.AuditSequence++
+	assert.ErrorIs(t, Verify(stored, signer.PublicKey()), ErrInvalidSignature)
+
+	// A report cannot borrow the anchor of another one
+	other, err := publisher.Publish(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, VerifyAnchor(other, auditLog))
+	forged := *other
+	forged.AnchorSequence = report.AnchorSequence
+	forged.AuditSequence, forged.AuditHeadHash = report.AuditSequence, report.AuditHeadHash
+	assert.ErrorContains(t, VerifyAnchor(&forged, auditLog), "anchors a different report")
+}
+
+func TestStore_RetentionAndLimit(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir, 24*time.Hour)
+	require.NoError(t, err)
+
+	start := time.Now().Add(-48 * time.Hour)
+	for i := 0; i <= MaxListLimit; i++ {
+		report := &Report{ID: fmt.Sprintf("r%d", i), GeneratedAt: start.Add(time.Duration(i) * time.Minute)}
+		require.NoError(t, store.Save(report))
+	}
+
+	// Only a zero limit gets the default page and large limits are capped
+	assert.Len(t, store.List(time.Time{}, time.Time{}, 0), DefaultListLimit)
+	assert.Len(t, store.List(time.Time{}, time.Time{}, MaxListLimit+10), MaxListLimit)
+	assert.Equal(t, fmt.Sprintf("r%d", MaxListLimit), store.List(time.Time{}, time.Time{}, 1)[0].ID)
+
+	// Reports older than the retention period are dropped with their files
+	require.NoError(t, store.Save(&Report{ID: "latest", GeneratedAt: start.Add(30 * time.Hour)}))
+	_, err = store.Get("r0")
+	assert.ErrorIs(t, err, ErrReportNotFound)
+	_, err = os.Stat(filepath.Join(dir, "r0.json"))
+	assert.True(t, os.IsNotExist(err))
+
+	reloaded, err := NewStore(dir, 24*time.Hour)
+	require.NoError(t, err)
+	_, err = reloaded.Get("latest")
+	assert.NoError(t, err)
+	_, err = reloaded.Get("r0")
+	assert.ErrorIs(t, err, ErrReportNotFound)
+}
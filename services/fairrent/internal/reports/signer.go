@@ -0,0 +1,102 @@
+package reports
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Signer signs fairness reports with an Ed25519 key
+type Signer struct {
+	key   ed25519.PrivateKey
+	keyID string
+}
+
+// NewSigner wraps an existing Ed25519 private key
+func NewSigner(key ed25519.PrivateKey) *Signer {
+	digest := sha256.Sum256(key.Public().(ed25519.PublicKey))
+	return &Signer{
+		key:   key,
+		keyID: hex.EncodeToString(digest[:8]),
+	}
+}
+
+// GenerateSigner creates a signer with a fresh, unpersisted key
+func GenerateSigner() (*Signer, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+	return NewSigner(key), nil
+}
+
+// LoadOrCreateSigner reads a PKCS#8 PEM Ed25519 key from path, generating
+// and writing a new one if the file does not exist
+func LoadOrCreateSigner(path string) (*Signer, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		signer, err := GenerateSigner()
+		if err != nil {
+			return nil, err
+		}
+		if err := signer.writeKey(path); err != nil {
+			return nil, err
+		}
+		return signer, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("signing key %s is not a PEM private key", path)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key: %w", err)
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("signing key %s is not an Ed25519 key", path)
+	}
+
+	return NewSigner(key), nil
+}
+
+// Sign signs the payload
+func (s *Signer) Sign(payload []byte) []byte {
+	return ed25519.Sign(s.key, payload)
+}
+
+// PublicKey returns the verification key
+func (s *Signer) PublicKey() ed25519.PublicKey {
+	return s.key.Public().(ed25519.PublicKey)
+}
+
+// KeyID returns a short identifier derived from the public key
+func (s *Signer) KeyID() string {
+	return s.keyID
+}
+
+// writeKey persists the private key as PKCS#8 PEM, readable only by the owner
+func (s *Signer) writeKey(path string) error {
+	der, err := x509.MarshalPKCS8PrivateKey(s.key)
+	if err != nil {
+		return fmt.Errorf("failed to encode signing key: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create key directory: %w", err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write signing key: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package reports
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrReportNotFound is returned when no report matches a lookup
+var ErrReportNotFound = errors.New("fairness report not found")
+
+// DefaultListLimit is how many reports List returns when no limit is given
+const DefaultListLimit = 100
+
+// MaxListLimit bounds the reports List returns at once
+const MaxListLimit = 1000
+
+// Store keeps published reports for the retention period in memory,
+// optionally mirrored to a directory with one JSON file per report
+type Store struct {
+	mu sync.RWMutex
+
+	reports   []*Report // ordered by generation time
+	byID      map[string]*Report
+	retention time.Duration
+
+	dir string
+}
+
+// NewStore creates a report store keeping reports for retention, or forever
+// if it is zero. With a non-empty dir, retained reports already in the
+// directory are loaded and new reports are written to it.
+func NewStore(dir string, retention time.Duration) (*Store, error) {
+	s := &Store{
+		byID:      make(map[string]*Report),
+		retention: retention,
+		dir:       dir,
+	}
+	if dir == "" {
+		return s, nil
+	}
+
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create report directory: %w", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report directory: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read report %s: %w", entry.Name(), err)
+		}
+		report := &Report{}
+		if err := json.Unmarshal(data, report); err != nil {
+			return nil, fmt.Errorf("failed to parse report %s: %w", entry.Name(), err)
+		}
+		s.reports = append(s.reports, report)
+		s.byID[report.ID] = report
+	}
+	sort.Slice(s.reports, func(i, j int) bool {
+		return s.reports[i].GeneratedAt.Before(s.reports[j].GeneratedAt)
+	})
+	if err := s.prune(time.Now()); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Save stores a report and drops reports older than the retention period
+func (s *Store) Save(report *Report) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.dir != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode report: %w", err)
+		}
+		path := filepath.Join(s.dir, report.ID+".json")
+		if err := os.WriteFile(path, data, 0o640); err != nil {
+			return fmt.Errorf("failed to write report: %w", err)
+		}
+	}
+
+	s.reports = append(s.reports, report)
+	s.byID[report.ID] = report
+	return s.prune(report.GeneratedAt)
+}
+
+// prune drops reports generated before the retention period, with their
+// files. Must be called with the write lock held.
+func (s *Store) prune(now time.Time) error {
+	if s.retention <= 0 {
+		return nil
+	}
+
+	cutoff := now.Add(-s.retention)
+	drop := sort.Search(len(s.reports), func(i int) bool {
+		return !s.reports[i].GeneratedAt.Before(cutoff)
+	})
+	for _, report := range s.reports[:drop] {
+		delete(s.byID, report.ID)
+		if s.dir == "" {
+			continue
+		}
+		path := filepath.Join(s.dir, report.ID+".json")
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove expired report: %w", err)
+		}
+	}
+	if drop > 0 {
+		s.reports = append([]*Report(nil), s.reports[drop:]...)
+	}
+	return nil
+}
+
+// Get returns the report with the given ID, or the latest report if id is empty
+func (s *Store) Get(id string) (*Report, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if id == "" {
+		if len(s.reports) == 0 {
+			return nil, ErrReportNotFound
+		}
+		return s.reports[len(s.reports)-1], nil
+	}
+
+	report, exists := s.byID[id]
+	if !exists {
+		return nil, ErrReportNotFound
+	}
+	return report, nil
+}
+
+// List returns reports generated in [start, end], newest first, up to limit.
+// Zero times leave the range open; a zero limit is DefaultListLimit and
+// larger limits are capped at MaxListLimit.
+func (s *Store) List(start, end time.Time, limit int) []*Report {
+	if limit <= 0 {
+		limit = DefaultListLimit
+	}
+	if limit > MaxListLimit {
+		limit = MaxListLimit
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var reports []*Report
+	for i := len(s.reports) - 1; i >= 0; i-- {
+		report := s.reports[i]
+		if !start.IsZero() && report.GeneratedAt.Before(start) {
+			continue
+		}
+		if !end.IsZero() && report.GeneratedAt.After(end) {
+			continue
+		}
+		reports = append(reports, report)
+		if len(reports) == limit {
+			break
+		}
+	}
+	return reports
+}
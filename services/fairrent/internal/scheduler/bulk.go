@@ -0,0 +1,246 @@
+package scheduler
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/events"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/common/v1"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/fairrent/v1"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+)
+
+// Audit record types of bulk operations
+const (
+	QueueImportRecordType = "queue_import"
+	QueueExportRecordType = "queue_export"
+)
+
+// Errors for tickets that cannot be imported
+var (
+	// ErrTicketExists is returned for imported tickets whose ID is already
+	// queued or allocated
+	ErrTicketExists = errors.New("ticket already exists")
+
+	// ErrEnqueueTimeInFuture is returned for imported tickets enqueued after
+	// the time of the import
+	ErrEnqueueTimeInFuture = errors.New("enqueue time is in the future")
+)
+
+// ImportedTicket is a ticket carried over from another waitlist
+type ImportedTicket struct {
+	TicketID    string // generated if empty
+	Request     *fairrentv1.EnqueueRequest
+	EnqueueTime time.Time
+}
+
+// ImportOutcome is the result of importing one ticket: its ID, or why it was
+// not imported
+type ImportOutcome struct {
+	TicketID string
+	Err      error
+}
+
+// ExportedTicket is a snapshot of a queued or allocated ticket
+type ExportedTicket struct {
+	ID            string
+	UserID        string
+	EnqueueTime   time.Time
+	PriorityScore float64
+	PolicyVersion int // policy that produced the score
+	Request       *fairrentv1.EnqueueRequest
+	Preferences   map[string]string
+	Status        commonv1.AllocationStatus
+	QueuePosition int       // 1-based, for queued tickets
+	AllocatedAt   time.Time // for allocated tickets
+}
+
+// Import adds tickets with their original enqueue times, e.g. when migrating
+// from another waitlist. Requests are expected to be validated. Tickets whose
+// ID is taken, whose enqueue time lies in the future or that do not fit into
+// the queue are skipped; the others are scored and queued as if they had been
+// enqueued at their enqueue time. With dryRun set the queue is left
+// unchanged. While the queue drains only dry runs are accepted.
+func (fr *FairRent) Import(ctx context.Context, actor string, tickets []ImportedTicket, dryRun bool) ([]ImportOutcome, error) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	fr.activateDuePolicies(time.Now())
+
+	if fr.mode == ModeDraining && !dryRun {
+		return nil, ErrDraining
+	}
+
+	now := time.Now()
+	outcomes := make([]ImportOutcome, len(tickets))
+	taken := make(map[string]bool)
+	var imported []*Ticket
+	for i, t := range tickets {
+		ticketID := t.TicketID
+		if ticketID == "" {
+			ticketID = generateTicketID()
+			for fr.ticketExists(ticketID) || taken[ticketID] {
+				ticketID = generateTicketID()
+			}
+		}
+		outcomes[i].TicketID = ticketID
+
+		switch {
+		case fr.ticketExists(ticketID) || taken[ticketID]:
+			outcomes[i].Err = fmt.Errorf("%w: %s", ErrTicketExists, ticketID)
+			continue
+		case t.EnqueueTime.After(now):
+			outcomes[i].Err = fmt.Errorf("%w: %s", ErrEnqueueTimeInFuture, t.EnqueueTime.UTC().Format(time.RFC3339))
+			continue
+		case fr.queueFull(len(taken) - len(imported)):
+			outcomes[i].Err = ErrQueueFull
+			continue
+		}
+		taken[ticketID] = true
+		if dryRun {
+			continue
+		}
+
+		factors := fr.scoreFactors(t.Request)
+		ticket := &Ticket{
+			ID:            ticketID,
+			UserID:        t.Request.UserId.Value,
+			UserGroup:     t.Request.UserGroup.String(),
+			Urgency:       int(t.Request.Urgency),
+			EnqueueTime:   t.EnqueueTime,
+			PriorityScore: factors.Score(),
+			Factors:       factors,
+			Constraints:   t.Request,
+		}
+		heap.Push(fr.queue, ticket)
+		fr.ticketMap[ticketID] = ticket
+		fr.metrics.RecordRequestEnqueued(fr.requestLabels(ticket), fr.isQualified(ticket))
+		imported = append(imported, ticket)
+	}
+
+	rejected := len(tickets) - len(taken)
+	if !dryRun && len(imported) > 0 {
+		// Imports are not arrivals, so they do not inform wait estimates
+		fr.metrics.RecordExtendedFairness(fr.calculateExtendedMetrics())
+
+		// Positions come from one sort of the queue rather than a scan per
+		// imported ticket
+		positions := make(map[string]int, fr.queue.Len())
+		for i, ticket := range fr.orderedTickets() {
+			positions[ticket.ID] = i + 1
+		}
+		for _, ticket := range imported {
+			position := positions[ticket.ID]
+			fr.publishEvent(ticketEvent(events.TypeImported, ticket, func(e *events.Event) {
+				e.QueuePosition = position
+			}))
+		}
+		fr.notifyWatchers(now)
+	}
+	if !dryRun {
+		if _, err := fr.audit.Append(QueueImportRecordType, actor, map[string]interface{}{
+			"imported": len(imported),
+			"rejected": rejected,
+		}); err != nil {
+			fr.logger.Error("Failed to audit queue import", zap.Error(err))
+		}
+	}
+
+	fr.logger.Info("Queue import processed",
+		zap.String("actor", actor),
+		zap.Int("accepted", len(taken)),
+		zap.Int("rejected", rejected),
+		zap.Bool("dry_run", dryRun),
+		zap.Int("queue_length", fr.queue.Len()),
+	)
+	return outcomes, nil
+}
+
+// Export returns the queued tickets in the order they will be served and,
+// with includeAllocated set, the tickets allocated since startup in the order
+// they were allocated
+func (fr *FairRent) Export(ctx context.Context, actor string, includeAllocated bool) []ExportedTicket {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	ordered := fr.orderedTickets()
+	exported := make([]ExportedTicket, 0, len(ordered))
+	for i, ticket := range ordered {
+		t := exportTicket(ticket, commonv1.AllocationStatus_ALLOCATION_STATUS_QUEUED)
+		t.QueuePosition = i + 1
+		exported = append(exported, t)
+	}
+
+	allocated := 0
+	if includeAllocated {
+		now := time.Now()
+		for _, ticketID := range fr.decisionOrder {
+			decision, exists := fr.decision(ticketID, now)
+			if !exists {
+				continue
+			}
+			t := exportTicket(decision.Ticket, commonv1.AllocationStatus_ALLOCATION_STATUS_ALLOCATED)
+			t.AllocatedAt = decision.AllocatedAt
+			exported = append(exported, t)
+			allocated++
+		}
+	}
+
+	// Exports carry personal data, so every one is audited
+	if _, err := fr.audit.Append(QueueExportRecordType, actor, map[string]interface{}{
+		"queued":    len(ordered),
+		"allocated": allocated,
+	}); err != nil {
+		fr.logger.Error("Failed to audit queue export", zap.Error(err))
+	}
+
+	fr.logger.Info("Queue exported",
+		zap.String("actor", actor),
+		zap.Int("queued", len(ordered)),
+		zap.Int("allocated", allocated),
+	)
+	return exported
+}
+
+// exportTicket snapshots a ticket. Allocated tickets keep only their user,
+// group and urgency of the request. Must be called with the lock held.
+func exportTicket(ticket *Ticket, status commonv1.AllocationStatus) ExportedTicket {
+	t := ExportedTicket{
+		ID:            ticket.ID,
+		UserID:        ticket.UserID,
+		EnqueueTime:   ticket.EnqueueTime,
+		PriorityScore: ticket.PriorityScore,
+		PolicyVersion: ticket.Factors.PolicyVersion,
+		Status:        status,
+	}
+	if req, ok := ticket.Constraints.(*fairrentv1.EnqueueRequest); ok {
+		t.Request = proto.Clone(req).(*fairrentv1.EnqueueRequest)
+	} else {
+		t.Request = &fairrentv1.EnqueueRequest{
+			UserId:    &commonv1.UserID{Value: ticket.UserID},
+			UserGroup: commonv1.UserGroup(commonv1.UserGroup_value[ticket.UserGroup]),
+			Urgency:   commonv1.UrgencyLevel(ticket.Urgency),
+		}
+	}
+	if len(ticket.Preferences) > 0 {
+		t.Preferences = make(map[string]string, len(ticket.Preferences))
+		for key, value := range ticket.Preferences {
+			t.Preferences[key] = value
+		}
+	}
+	return t
+}
+
+// ticketExists reports whether a ticket ID is queued or allocated. Must be
+// called with the lock held.
+func (fr *FairRent) ticketExists(ticketID string) bool {
+	if _, queued := fr.ticketMap[ticketID]; queued {
+		return true
+	}
+	_, allocated := fr.decisions[ticketID]
+	return allocated
+}
@@ -0,0 +1,80 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/events"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/common/v1"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/fairrent/v1"
+	"go.uber.org/zap"
+)
+
+func TestFairRent_ImportExport(t *testing.T) {
+	fr := NewFairRent(nil, zap.NewNop())
+	ctx := context.Background()
+
+	resp, err := fr.Enqueue(ctx, &fairrentv1.EnqueueRequest{
+		UserId:    &commonv1.UserID{Value: "user1"},
+		UserGroup: commonv1.UserGroup_USER_GROUP_STUDENT,
+		Urgency:   commonv1.UrgencyLevel_URGENCY_LEVEL_MEDIUM,
+	})
+	require.NoError(t, err)
+	existing := resp.TicketId.Value
+
+	imported := func(ticketID, user string, enqueued time.Time) ImportedTicket {
+		return ImportedTicket{
+			TicketID: ticketID,
+			Request: &fairrentv1.EnqueueRequest{
+				UserId:    &commonv1.UserID{Value: user},
+				UserGroup: commonv1.UserGroup_USER_GROUP_STUDENT,
+				Urgency:   commonv1.UrgencyLevel_URGENCY_LEVEL_MEDIUM,
+			},
+			EnqueueTime: enqueued,
+		}
+	}
+	longAgo := time.Now().AddDate(-2, 0, 0)
+	tickets := []ImportedTicket{
+		imported("legacy_1", "user2", longAgo),
+		imported(existing, "user3", longAgo),
+		imported("legacy_1", "user4", longAgo),
+		imported("legacy_2", "user5", time.Now().Add(time.Hour)),
+		imported("", "user6", longAgo.Add(time.Hour)),
+	}
+
+	// A dry run reports the same outcomes without changing the queue
+	outcomes, err := fr.Import(ctx, "admin", tickets, true)
+	require.NoError(t, err)
+	require.Len(t, outcomes, 5)
+	assert.Equal(t, 1, fr.queue.Len())
+
+	outcomes, err = fr.Import(ctx, "admin", tickets, false)
+	require.NoError(t, err)
+	assert.NoError(t, outcomes[0].Err)
+	assert.ErrorIs(t, outcomes[1].Err, ErrTicketExists)
+	assert.ErrorIs(t, outcomes[2].Err, ErrTicketExists)
+	assert.ErrorIs(t, outcomes[3].Err, ErrEnqueueTimeInFuture)
+	assert.NoError(t, outcomes[4].Err)
+	assert.NotEmpty(t, outcomes[4].TicketID)
+	assert.Equal(t, 3, fr.queue.Len())
+
+	feed, err := fr.Events().Since(0, 0)
+	require.NoError(t, err)
+	require.Len(t, feed, 3)
+	assert.Equal(t, events.TypeImported, feed[1].Type)
+	assert.Equal(t, "legacy_1", feed[1].TicketID)
+
+	// Imported tickets keep their wait, so they are served before the
+	// ticket enqueued today
+	exported := fr.Export(ctx, "admin", false)
+	require.Len(t, exported, 3)
+	assert.Equal(t, "legacy_1", exported[0].ID)
+	assert.Equal(t, 1, exported[0].QueuePosition)
+	assert.True(t, exported[0].EnqueueTime.Equal(longAgo))
+	assert.Equal(t, outcomes[4].TicketID, exported[1].ID)
+	assert.Equal(t, existing, exported[2].ID)
+	assert.Equal(t, commonv1.AllocationStatus_ALLOCATION_STATUS_QUEUED, exported[2].Status)
+}
@@ -0,0 +1,275 @@
+package scheduler
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/fairrent/v1"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// WaitEstimateConfidence is the coverage of the reported wait time interval
+const WaitEstimateConfidence = 0.8
+
+// DefaultEstimationWindow is how far back arrivals and allocations are used
+// to estimate rates
+const DefaultEstimationWindow = 7 * 24 * time.Hour
+
+// minEstimateAllocations is how many allocations inside the estimation
+// window are needed before wait times are estimated at all
+const minEstimateAllocations = 5
+
+// maxEstimatorEvents bounds the arrivals and allocations kept in memory
+const maxEstimatorEvents = 100000
+
+// z-score of the 90th percentile of the standard normal distribution, so that
+// [P10, P90] covers WaitEstimateConfidence
+const z90 = 1.2815515655446004
+
+// WaitEstimate is a queueing-model estimate of how long a ticket will wait
+// from now on.
+//
+// Allocations are modelled as a Poisson process with rate μ, and arrivals
+// that outrank the ticket as a Poisson process with rate λ, the sum over
+// groups of the group's arrival rate times the share of its arrivals scoring
+// above the ticket. The ticket is allocated once allocations exceed the
+// tickets ahead of it plus those overtaking it, so its wait is the first
+// passage of a random walk with drift μ - λ to the ticket's position. That
+// has mean k/(μ-λ) and variance k(μ+λ)/(μ-λ)³; quantiles come from a gamma
+// distribution with the same moments.
+type WaitEstimate struct {
+	Median time.Duration
+	Lower  time.Duration // P10
+	Upper  time.Duration // P90
+
+	// Bounded is false if outranking arrivals keep pace with allocations, so
+	// the ticket may never be reached at current rates. Only Lower, the wait
+	// without any overtaking, is set then.
+	Bounded bool
+
+	Ahead           int
+	AllocationRate  float64            // μ, per hour
+	OvertakingRate  float64            // λ, per hour
+	GroupArrivals   map[string]float64 // arrivals per hour
+	GroupOvertaking map[string]float64 // outranking arrivals per hour
+}
+
+// Proto converts the estimate to protobuf format
+func (e WaitEstimate) Proto() *fairrentv1.WaitTimeEstimate {
+	pb := &fairrentv1.WaitTimeEstimate{
+		Lower:          durationpb.New(e.Lower),
+		Confidence:     WaitEstimateConfidence,
+		Bounded:        e.Bounded,
+		AllocationRate: e.AllocationRate,
+		OvertakingRate: e.OvertakingRate,
+	}
+	if e.Bounded {
+		pb.Median = durationpb.New(e.Median)
+		pb.Upper = durationpb.New(e.Upper)
+	}
+	return pb
+}
+
+// arrival is an enqueued request as seen by the estimator
+type arrival struct {
+	at    time.Time
+	group string
+	score float64
+}
+
+// waitEstimator keeps the recent arrivals and allocations that rates are
+// estimated from, and the estimates issued at enqueue for later validation
+type waitEstimator struct {
+	window  time.Duration
+	started time.Time
+
+	arrivals    []arrival   // ordered by time
+	allocations []time.Time // ordered by time
+
+	issued map[string]WaitEstimate // by ticket ID, until the ticket leaves the queue
+}
+
+// newWaitEstimator creates an estimator over the given window
+func newWaitEstimator(window time.Duration, now time.Time) *waitEstimator {
+	if window <= 0 {
+		window = DefaultEstimationWindow
+	}
+	return &waitEstimator{
+		window:  window,
+		started: now,
+		issued:  make(map[string]WaitEstimate),
+	}
+}
+
+// recordArrival records an enqueued ticket
+func (e *waitEstimator) recordArrival(now time.Time, group string, score float64) {
+	e.arrivals = append(e.arrivals, arrival{at: now, group: group, score: score})
+	e.prune(now)
+}
+
+// recordAllocation records an allocation
+func (e *waitEstimator) recordAllocation(now time.Time) {
+	e.allocations = append(e.allocations, now)
+	e.prune(now)
+}
+
+// issue remembers the estimate given to a ticket at enqueue
+func (e *waitEstimator) issue(ticketID string, estimate WaitEstimate) {
+	e.issued[ticketID] = estimate
+}
+
+// resolve returns and forgets the estimate given to a ticket at enqueue
+func (e *waitEstimator) resolve(ticketID string) (WaitEstimate, bool) {
+	estimate, ok := e.issued[ticketID]
+	delete(e.issued, ticketID)
+	return estimate, ok
+}
+
+// prune drops events that left the window
+func (e *waitEstimator) prune(now time.Time) {
+	cutoff := now.Add(-e.window)
+
+	drop := 0
+	for drop < len(e.arrivals) && (e.arrivals[drop].at.Before(cutoff) || len(e.arrivals)-drop > maxEstimatorEvents) {
+		drop++
+	}
+	e.arrivals = e.arrivals[drop:]
+
+	drop = 0
+	for drop < len(e.allocations) && (e.allocations[drop].Before(cutoff) || len(e.allocations)-drop > maxEstimatorEvents) {
+		drop++
+	}
+	e.allocations = e.allocations[drop:]
+}
+
+// estimate estimates the wait of a ticket with the given score and number of
+// tickets ahead of it. It returns false until enough allocations have been
+// observed.
+func (e *waitEstimator) estimate(now time.Time, ahead int, score float64) (WaitEstimate, bool) {
+	estimates, ok := e.estimates(now, []float64{score}, []int{ahead})
+	if !ok {
+		return WaitEstimate{}, false
+	}
+	return estimates[0], true
+}
+
+// estimates estimates the waits of several tickets, given their scores in
+// ascending order and the number of tickets ahead of each, with one pass
+// over the arrivals. It returns false until enough allocations have been
+// observed.
+func (e *waitEstimator) estimates(now time.Time, scores []float64, ahead []int) ([]WaitEstimate, bool) {
+	from := now.Add(-e.window)
+	if e.started.After(from) {
+		from = e.started
+	}
+	span := now.Sub(from).Hours()
+	if span <= 0 {
+		return nil, false
+	}
+
+	allocations := 0
+	for _, at := range e.allocations {
+		if !at.Before(from) {
+			allocations++
+		}
+	}
+	if allocations < minEstimateAllocations {
+		return nil, false
+	}
+
+	// Per-group arrival rates and the share of each group's arrivals that
+	// would outrank each ticket; later arrivals with an equal score queue
+	// behind it. An arrival outranks the tickets scoring below it, so it is
+	// counted at the highest of those and summed down below.
+	arrivals := make(map[string]int)
+	outranking := make(map[string][]int)
+	for _, a := range e.arrivals {
+		if a.at.Before(from) {
+			continue
+		}
+		arrivals[a.group]++
+		if below := sort.SearchFloat64s(scores, a.score); below > 0 {
+			counts := outranking[a.group]
+			if counts == nil {
+				counts = make([]int, len(scores))
+				outranking[a.group] = counts
+			}
+			counts[below-1]++
+		}
+	}
+	for _, counts := range outranking {
+		for i := len(counts) - 2; i >= 0; i-- {
+			counts[i] += counts[i+1]
+		}
+	}
+
+	estimates := make([]WaitEstimate, len(scores))
+	for i := range scores {
+		estimate := WaitEstimate{
+			Ahead:           ahead[i],
+			AllocationRate:  float64(allocations) / span,
+			GroupArrivals:   make(map[string]float64, len(arrivals)),
+			GroupOvertaking: make(map[string]float64, len(arrivals)),
+		}
+		for group, n := range arrivals {
+			rate := float64(n) / span
+			share := 0.0
+			if counts := outranking[group]; counts != nil {
+				share = float64(counts[i]) / float64(n)
+			}
+			estimate.GroupArrivals[group] = rate
+			estimate.GroupOvertaking[group] = rate * share
+			estimate.OvertakingRate += rate * share
+		}
+		estimates[i] = estimate.withQuantiles()
+	}
+	return estimates, true
+}
+
+// withQuantiles fills in the wait quantiles from the rates
+func (e WaitEstimate) withQuantiles() WaitEstimate {
+	mu := e.AllocationRate
+	lambda := e.OvertakingRate
+	k := float64(e.Ahead + 1)
+
+	// Without overtaking the wait is Erlang(k, μ), a lower bound either way
+	e.Lower = hours(gammaQuantile(k/mu, k/(mu*mu), -z90))
+
+	drift := mu - lambda
+	if drift <= 0 {
+		return e
+	}
+
+	mean := k / drift
+	variance := k * (mu + lambda) / (drift * drift * drift)
+	e.Bounded = true
+	e.Median = hours(gammaQuantile(mean, variance, 0))
+	e.Lower = hours(gammaQuantile(mean, variance, -z90))
+	e.Upper = hours(gammaQuantile(mean, variance, z90))
+	return e
+}
+
+// gammaQuantile approximates the quantile at standard normal score z of the
+// gamma distribution with the given mean and variance (Wilson–Hilferty)
+func gammaQuantile(mean, variance, z float64) float64 {
+	if mean <= 0 || variance <= 0 {
+		return mean
+	}
+	shape := mean * mean / variance
+	scale := variance / mean
+
+	c := 1 - 1/(9*shape) + z/(3*math.Sqrt(shape))
+	if c <= 0 {
+		return 0
+	}
+	return shape * scale * c * c * c
+}
+
+// hours converts fractional hours to a duration, saturating on overflow
+func hours(h float64) time.Duration {
+	if h >= float64(math.MaxInt64)/float64(time.Hour) {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(h * float64(time.Hour))
+}
@@ -0,0 +1,107 @@
+package scheduler
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWaitEstimator_OvertakingRates(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	e := newWaitEstimator(24*time.Hour, now.Add(-10*time.Hour))
+
+	// 20 allocations over 10 hours: μ = 2/h
+	for i := 0; i < 20; i++ {
+		e.recordAllocation(now.Add(-time.Duration(i) * 30 * time.Minute))
+	}
+	// Refugees arrive at 1/h, all outranking the ticket; students at 0.5/h,
+	// one in five outranking it
+	for i := 0; i < 10; i++ {
+		e.recordArrival(now.Add(-time.Duration(i)*time.Hour), "USER_GROUP_REFUGEE", 2.0)
+	}
+	for i := 0; i < 5; i++ {
+		score := 0.5
+		if i == 0 {
+			score = 1.5
+		}
+		e.recordArrival(now.Add(-time.Duration(i)*2*time.Hour), "USER_GROUP_STUDENT", score)
+	}
+
+	estimate, ok := e.estimate(now, 3, 1.0)
+	require.True(t, ok)
+	assert.InDelta(t, 2.0, estimate.AllocationRate, 1e-9)
+	assert.InDelta(t, 1.0, estimate.GroupOvertaking["USER_GROUP_REFUGEE"], 1e-9)
+	assert.InDelta(t, 0.1, estimate.GroupOvertaking["USER_GROUP_STUDENT"], 1e-9)
+	assert.InDelta(t, 1.1, estimate.OvertakingRate, 1e-9)
+	assert.True(t, estimate.Bounded)
+
+	// Mean first passage to position 4 with drift 0.9/h is 4.4h; the wait is
+	// right-skewed, so the median is lower
+	assert.InDelta(t, 3.3, estimate.Median.Hours(), 0.1)
+	assert.True(t, estimate.Lower < estimate.Median && estimate.Median < estimate.Upper)
+
+	// Once outranking arrivals outpace allocations the wait is unbounded
+	for i := 0; i < 10; i++ {
+		e.recordArrival(now.Add(-time.Duration(i)*time.Hour-30*time.Minute), "USER_GROUP_REFUGEE", 2.0)
+	}
+	estimate, ok = e.estimate(now, 3, 1.0)
+	require.True(t, ok)
+	assert.InDelta(t, 2.1, estimate.OvertakingRate, 1e-9)
+	assert.False(t, estimate.Bounded)
+	assert.Positive(t, estimate.Lower)
+	assert.Zero(t, estimate.Median)
+}
+
+func TestWaitEstimate_IntervalCoverage(t *testing.T) {
+	// Simulate the modelled process and check that [P10, P90] covers about
+	// 80% of the waits
+	const mu, lambda, ahead = 2.0, 0.5, 4
+	k := float64(ahead + 1)
+	drift := mu - lambda
+	mean := k / drift
+	variance := k * (mu + lambda) / (drift * drift * drift)
+	lower := gammaQuantile(mean, variance, -z90)
+	upper := gammaQuantile(mean, variance, z90)
+
+	rng := rand.New(rand.NewSource(1))
+	const runs = 5000
+	inside := 0
+	for run := 0; run < runs; run++ {
+		var elapsed float64
+		remaining := ahead + 1
+		for remaining > 0 {
+			elapsed += rng.ExpFloat64() / (mu + lambda)
+			if rng.Float64() < mu/(mu+lambda) {
+				remaining--
+			} else {
+				remaining++
+			}
+		}
+		if elapsed >= lower && elapsed <= upper {
+			inside++
+		}
+	}
+
+	assert.InDelta(t, WaitEstimateConfidence, float64(inside)/runs, 0.05)
+}
+
+func TestMetrics_WaitEstimateAccuracy(t *testing.T) {
+	m := NewMetrics(nil, nil)
+	estimate := WaitEstimate{Median: 2 * time.Hour, Lower: time.Hour, Upper: 4 * time.Hour, Bounded: true}
+
+	m.RecordWaitEstimateOutcome(estimate, 2*time.Hour)
+	m.RecordWaitEstimateOutcome(estimate, 3*time.Hour)
+	m.RecordWaitEstimateOutcome(estimate, 6*time.Hour)
+	// Estimates without a median are not evaluated
+	m.RecordWaitEstimateOutcome(WaitEstimate{Lower: time.Hour}, 6*time.Hour)
+
+	accuracy := m.GetMetrics().WaitEstimateAccuracy
+	assert.Equal(t, int64(3), accuracy.Evaluated)
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.WaitEstimateOutcomes.WithLabelValues("above")))
+	assert.InDelta(t, 2.0/3, accuracy.IntervalCoverage, 1e-9)
+	assert.InDelta(t, 0.5, accuracy.MedianRelativeError, 0.01)
+}
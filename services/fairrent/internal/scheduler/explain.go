@@ -0,0 +1,279 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/common/v1"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/fairrent/v1"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// DefaultDecisionRetention is how long allocation decisions are kept for
+// explanations by default
+const DefaultDecisionRetention = 30 * 24 * time.Hour
+
+// DefaultMaxDecisions is the default number of allocation decisions kept
+const DefaultMaxDecisions = 100000
+
+// DefaultMaxRankedAbove is how many higher-ranked tickets ExplainDecision
+// lists unless asked for a number
+const DefaultMaxRankedAbove = 20
+
+// maxRankedAbove bounds the higher-ranked tickets ExplainDecision lists
+const maxRankedAbove = 100
+
+// Decision records how a ticket was selected by ScheduleNext. Tickets are
+// kept as snapshots of their score, without the request's preferences and
+// financial constraints.
+type Decision struct {
+	Ticket            *Ticket
+	AllocatedAt       time.Time
+	CompetingRequests int
+	RunnerUp          *Ticket // snapshot of the next ticket in line, nil if none
+}
+
+// ExplainDecision returns the factors behind a ticket's score and, for
+// queued tickets, the tickets currently ranked above it
+func (fr *FairRent) ExplainDecision(ctx context.Context, req *fairrentv1.ExplainDecisionRequest) (*fairrentv1.ExplainDecisionResponse, error) {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	ticketID := req.TicketId.Value
+	now := time.Now()
+
+	if ticket, exists := fr.ticketMap[ticketID]; exists {
+		above := fr.rankedAbove(ticket)
+
+		resp := &fairrentv1.ExplainDecisionResponse{
+			TicketId:        req.TicketId,
+			Status:          commonv1.AllocationStatus_ALLOCATION_STATUS_QUEUED,
+			Factors:         scoreFactorsProto(ticket, now),
+			CurrentPosition: int32(len(above) + 1),
+			ExplainedAt:     timestamppb.New(now),
+		}
+
+		limit := DefaultMaxRankedAbove
+		if req.MaxRankedAbove > 0 {
+			limit = int(req.MaxRankedAbove)
+		}
+		if limit > maxRankedAbove {
+			limit = maxRankedAbove
+		}
+		if limit > len(above) {
+			limit = len(above)
+		}
+		for i, other := range above[:limit] {
+			resp.RankedAbove = append(resp.RankedAbove, rankedTicketProto(other, i+1, rankReasons(other, ticket), now))
+		}
+
+		fr.logger.Debug("Decision explained",
+			zap.String("ticket_id", ticketID),
+			zap.Int("position", len(above)+1),
+		)
+
+		return resp, nil
+	}
+
+	if decision, exists := fr.decision(ticketID, now); exists {
+		return &fairrentv1.ExplainDecisionResponse{
+			TicketId:    req.TicketId,
+			Status:      commonv1.AllocationStatus_ALLOCATION_STATUS_ALLOCATED,
+			Factors:     scoreFactorsProto(decision.Ticket, decision.AllocatedAt),
+			Decision:    decisionProto(decision),
+			ExplainedAt: timestamppb.New(now),
+		}, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrTicketNotFound, ticketID)
+}
+
+// recordDecision remembers why a ticket popped from the queue was selected
+// and forgets decisions past their retention. Must be called with the write
+// lock held, after the ticket has been popped.
+func (fr *FairRent) recordDecision(ticket *Ticket) {
+	now := time.Now()
+	decision := &Decision{
+		Ticket:            decisionSnapshot(ticket),
+		AllocatedAt:       now,
+		CompetingRequests: fr.queue.Len() + 1,
+	}
+	if next := fr.queue.Peek(); next != nil {
+		decision.RunnerUp = decisionSnapshot(next)
+		decision.RunnerUp.UserID = ""
+	}
+	fr.decisions[ticket.ID] = decision
+	fr.decisionOrder = append(fr.decisionOrder, ticket.ID)
+	fr.pruneDecisions(now)
+}
+
+// decision returns the decision that allocated a ticket, unless it is past
+// its retention. Must be called with the lock held.
+func (fr *FairRent) decision(ticketID string, now time.Time) (*Decision, bool) {
+	decision, exists := fr.decisions[ticketID]
+	if !exists || decision.AllocatedAt.Before(now.Add(-fr.decisionRetention())) {
+		return nil, false
+	}
+	return decision, true
+}
+
+// pruneDecisions drops the oldest decisions while they are past their
+// retention or more than the configured number are kept. Must be called with
+// the write lock held.
+func (fr *FairRent) pruneDecisions(now time.Time) {
+	cutoff := now.Add(-fr.decisionRetention())
+	limit := fr.config.MaxDecisions
+	if limit <= 0 {
+		limit = DefaultMaxDecisions
+	}
+
+	drop := 0
+	for drop < len(fr.decisionOrder) {
+		decision := fr.decisions[fr.decisionOrder[drop]]
+		if !decision.AllocatedAt.Before(cutoff) && len(fr.decisionOrder)-drop <= limit {
+			break
+		}
+		delete(fr.decisions, fr.decisionOrder[drop])
+		drop++
+	}
+	fr.decisionOrder = fr.decisionOrder[drop:]
+}
+
+// decisionRetention returns how long decisions are kept
+func (fr *FairRent) decisionRetention() time.Duration {
+	if fr.config.DecisionRetention > 0 {
+		return fr.config.DecisionRetention
+	}
+	return DefaultDecisionRetention
+}
+
+// decisionSnapshot copies what explaining a decision needs from a ticket:
+// its owner, group, urgency and score, but not the request
+func decisionSnapshot(ticket *Ticket) *Ticket {
+	return &Ticket{
+		ID:            ticket.ID,
+		UserID:        ticket.UserID,
+		UserGroup:     ticket.UserGroup,
+		Urgency:       ticket.Urgency,
+		EnqueueTime:   ticket.EnqueueTime,
+		PriorityScore: ticket.PriorityScore,
+		Factors:       ticket.Factors,
+	}
+}
+
+// rankedAbove returns the queued tickets that are served before the given
+// ticket, in queue order
+func (fr *FairRent) rankedAbove(ticket *Ticket) []*Ticket {
+	var above []*Ticket
+	for _, other := range fr.queue.tickets {
+		if other.ID != ticket.ID && ranksBefore(other, ticket) {
+			above = append(above, other)
+		}
+	}
+
+	sort.Slice(above, func(i, j int) bool {
+		return ranksBefore(above[i], above[j])
+	})
+	return above
+}
+
+// ranksBefore mirrors the priority queue ordering: higher score first, then
+// earlier enqueue time
+func ranksBefore(a, b *Ticket) bool {
+	if a.PriorityScore != b.PriorityScore {
+		return a.PriorityScore > b.PriorityScore
+	}
+	return a.EnqueueTime.Before(b.EnqueueTime)
+}
+
+// rankReasons lists the factors in which the higher-ranked ticket beats the other
+func rankReasons(higher, lower *Ticket) []string {
+	var reasons []string
+	hf, lf := higher.Factors, lower.Factors
+
+	if hf.UrgencyComponent > lf.UrgencyComponent {
+		reasons = append(reasons, fmt.Sprintf("higher urgency (%.2f vs %.2f)", hf.UrgencyComponent, lf.UrgencyComponent))
+	}
+	if hf.GroupWeight > lf.GroupWeight {
+		reasons = append(reasons, fmt.Sprintf("higher group weight for %s (%.2f vs %.2f)", higher.UserGroup, hf.GroupWeight, lf.GroupWeight))
+	}
+	if hf.PriorityBonus > lf.PriorityBonus {
+		reasons = append(reasons, fmt.Sprintf("higher priority bonus (%.2f vs %.2f)", hf.PriorityBonus, lf.PriorityBonus))
+	}
+
+	if higher.PriorityScore == lower.PriorityScore {
+		reasons = append(reasons, fmt.Sprintf("equal score, enqueued earlier (%s)", higher.EnqueueTime.UTC().Format(time.RFC3339)))
+	} else if len(reasons) == 0 {
+		// Individual factors are not higher, but their combination (or the α
+		// in force when the ticket was scored) yields a higher score
+		reasons = append(reasons, fmt.Sprintf("higher combined score (%.4f vs %.4f)", higher.PriorityScore, lower.PriorityScore))
+	}
+
+	return reasons
+}
+
+// scoreFactorsProto converts a ticket's score breakdown to protobuf format
+func scoreFactorsProto(ticket *Ticket, at time.Time) *fairrentv1.ScoreFactors {
+	return &fairrentv1.ScoreFactors{
+		UrgencyComponent: ticket.Factors.UrgencyComponent,
+		GroupWeight:      ticket.Factors.GroupWeight,
+		PriorityBonus:    ticket.Factors.PriorityBonus,
+		Alpha:            ticket.Factors.Alpha,
+		BasePriority:     ticket.Factors.BasePriority(),
+		PolicyVersion:    int32(ticket.Factors.PolicyVersion),
+		Score:            ticket.PriorityScore,
+		TimeInQueue:      durationpb.New(at.Sub(ticket.EnqueueTime)),
+	}
+}
+
+// rankedTicketProto converts a competing ticket to protobuf format
+func rankedTicketProto(ticket *Ticket, position int, reasons []string, at time.Time) *fairrentv1.RankedTicket {
+	return &fairrentv1.RankedTicket{
+		TicketId:  &commonv1.TicketID{Value: ticket.ID},
+		Position:  int32(position),
+		UserGroup: commonv1.UserGroup(commonv1.UserGroup_value[ticket.UserGroup]),
+		Factors:   scoreFactorsProto(ticket, at),
+		Reasons:   reasons,
+	}
+}
+
+// ExplanationSummary withholds other applicants' tickets from an
+// explanation, for callers not allowed to see them. The ticket's own factors
+// and position remain, and competing tickets are only counted.
+func ExplanationSummary(resp *fairrentv1.ExplainDecisionResponse) *fairrentv1.ExplainDecisionResponse {
+	resp.RankedAbove = nil
+	if decision := resp.Decision; decision != nil {
+		decision.RunnerUp = nil
+		decision.Summary = decisionSummary(resp.Factors.GetScore(), int(decision.CompetingRequests))
+	}
+	return resp
+}
+
+// decisionSummary describes an allocation decision without the runner-up
+func decisionSummary(score float64, competing int) string {
+	return fmt.Sprintf("selected with score %.4f, the highest among %d queued requests", score, competing)
+}
+
+// decisionProto converts a recorded allocation decision to protobuf format
+func decisionProto(decision *Decision) *fairrentv1.AllocationDecision {
+	summary := decisionSummary(decision.Ticket.PriorityScore, decision.CompetingRequests)
+
+	resp := &fairrentv1.AllocationDecision{
+		AllocationTime:    timestamppb.New(decision.AllocatedAt),
+		CompetingRequests: int32(decision.CompetingRequests),
+		PolicyVersion:     int32(decision.Ticket.Factors.PolicyVersion),
+	}
+
+	if decision.RunnerUp != nil {
+		// The runner-up is explained from the allocated ticket's point of view
+		resp.RunnerUp = rankedTicketProto(decision.RunnerUp, 2, rankReasons(decision.Ticket, decision.RunnerUp), decision.AllocatedAt)
+		summary += fmt.Sprintf("; next in line scored %.4f", decision.RunnerUp.PriorityScore)
+	}
+	resp.Summary = summary
+
+	return resp
+}
@@ -0,0 +1,183 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/common/v1"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/fairrent/v1"
+	"go.uber.org/zap"
+)
+
+func TestFairRent_ExplainDecision(t *testing.T) {
+	logger := zap.NewNop()
+	fr := NewFairRent(nil, logger)
+	ctx := context.Background()
+
+	student, err := fr.Enqueue(ctx, &fairrentv1.EnqueueRequest{
+		UserId:        &commonv1.UserID{Value: "student"},
+		UserGroup:     commonv1.UserGroup_USER_GROUP_STUDENT,
+		Urgency:       commonv1.UrgencyLevel_URGENCY_LEVEL_MEDIUM,
+		PriorityScore: 0.1,
+	})
+	require.NoError(t, err)
+
+	refugee, err := fr.Enqueue(ctx, &fairrentv1.EnqueueRequest{
+		UserId:        &commonv1.UserID{Value: "refugee"},
+		UserGroup:     commonv1.UserGroup_USER_GROUP_REFUGEE,
+		Urgency:       commonv1.UrgencyLevel_URGENCY_LEVEL_CRITICAL,
+		PriorityScore: 0.5,
+	})
+	require.NoError(t, err)
+
+	// Queued ticket: score breakdown plus the ticket ranked above it
+	resp, err := fr.ExplainDecision(ctx, &fairrentv1.ExplainDecisionRequest{TicketId: student.TicketId})
+	require.NoError(t, err)
+
+	assert.Equal(t, commonv1.AllocationStatus_ALLOCATION_STATUS_QUEUED, resp.Status)
+	assert.Equal(t, int32(2), resp.CurrentPosition)
+	assert.InDelta(t, 0.4, resp.Factors.UrgencyComponent, 1e-9)
+	assert.Equal(t, 1.0, resp.Factors.GroupWeight)
+	assert.Equal(t, 0.1, resp.Factors.PriorityBonus)
+	assert.Equal(t, 2.0, resp.Factors.Alpha)
+	assert.InDelta(t, 0.25, resp.Factors.Score, 1e-9) // (0.4*1.0 + 0.1)^2
+
+	require.Len(t, resp.RankedAbove, 1)
+	above := resp.RankedAbove[0]
+	assert.Equal(t, refugee.TicketId.Value, above.TicketId.Value)
+	assert.Equal(t, int32(1), above.Position)
+	assert.Equal(t, commonv1.UserGroup_USER_GROUP_REFUGEE, above.UserGroup)
+	assert.Len(t, above.Reasons, 3) // urgency, group weight, priority bonus
+
+	// Allocated ticket: the decision that was made
+	_, err = fr.ScheduleNext(ctx, &fairrentv1.ScheduleNextRequest{})
+	require.NoError(t, err)
+
+	resp, err = fr.ExplainDecision(ctx, &fairrentv1.ExplainDecisionRequest{TicketId: refugee.TicketId})
+	require.NoError(t, err)
+
+	assert.Equal(t, commonv1.AllocationStatus_ALLOCATION_STATUS_ALLOCATED, resp.Status)
+	require.NotNil(t, resp.Decision)
+	assert.Equal(t, int32(2), resp.Decision.CompetingRequests)
+	require.NotNil(t, resp.Decision.RunnerUp)
+	assert.Equal(t, student.TicketId.Value, resp.Decision.RunnerUp.TicketId.Value)
+	assert.NotEmpty(t, resp.Decision.Summary)
+
+	// Unknown ticket
+	_, err = fr.ExplainDecision(ctx, &fairrentv1.ExplainDecisionRequest{
+		TicketId: &commonv1.TicketID{Value: "nonexistent"},
+	})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "ticket not found")
+}
+
+func TestExplanationSummary(t *testing.T) {
+	fr := NewFairRent(nil, zap.NewNop())
+	ctx := context.Background()
+
+	var tickets []*commonv1.TicketID
+	for _, group := range []commonv1.UserGroup{commonv1.UserGroup_USER_GROUP_REFUGEE, commonv1.UserGroup_USER_GROUP_STUDENT, commonv1.UserGroup_USER_GROUP_FAMILY} {
+		resp, err := fr.Enqueue(ctx, &fairrentv1.EnqueueRequest{
+			UserId:    &commonv1.UserID{Value: group.String()},
+			UserGroup: group,
+			Urgency:   commonv1.UrgencyLevel_URGENCY_LEVEL_MEDIUM,
+		})
+		require.NoError(t, err)
+		tickets = append(tickets, resp.TicketId)
+	}
+
+	// Queued: own factors and position, no other tickets
+	resp, err := fr.ExplainDecision(ctx, &fairrentv1.ExplainDecisionRequest{TicketId: tickets[2]})
+	require.NoError(t, err)
+	require.NotEmpty(t, resp.RankedAbove)
+	resp = ExplanationSummary(resp)
+	assert.Empty(t, resp.RankedAbove)
+	assert.Equal(t, int32(3), resp.CurrentPosition)
+	assert.Equal(t, 1.0, resp.Factors.GroupWeight)
+
+	// Allocated: the decision without the runner-up
+	_, err = fr.ScheduleNext(ctx, &fairrentv1.ScheduleNextRequest{})
+	require.NoError(t, err)
+	resp, err = fr.ExplainDecision(ctx, &fairrentv1.ExplainDecisionRequest{TicketId: tickets[0]})
+	require.NoError(t, err)
+	require.NotNil(t, resp.Decision.RunnerUp)
+	resp = ExplanationSummary(resp)
+	assert.Nil(t, resp.Decision.RunnerUp)
+	assert.Equal(t, int32(3), resp.Decision.CompetingRequests)
+	assert.NotContains(t, resp.Decision.Summary, "next in line")
+}
+
+func TestRankReasons_Tie(t *testing.T) {
+	first := &Ticket{ID: "1", PriorityScore: 1.0, Factors: ScoreFactors{GroupWeight: 1.0}}
+	second := &Ticket{ID: "2", PriorityScore: 1.0, Factors: ScoreFactors{GroupWeight: 1.0}}
+	second.EnqueueTime = first.EnqueueTime.Add(1)
+
+	reasons := rankReasons(first, second)
+	require.Len(t, reasons, 1)
+	assert.Contains(t, reasons[0], "enqueued earlier")
+}
+
+func TestFairRent_DecisionRetention(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxDecisions = 1
+	fr := NewFairRent(config, zap.NewNop())
+	ctx := context.Background()
+
+	var tickets []*commonv1.TicketID
+	for _, user := range []string{"first", "second"} {
+		resp, err := fr.Enqueue(ctx, &fairrentv1.EnqueueRequest{
+			UserId:          &commonv1.UserID{Value: user},
+			UserGroup:       commonv1.UserGroup_USER_GROUP_STUDENT,
+			Urgency:         commonv1.UrgencyLevel_URGENCY_LEVEL_MEDIUM,
+			PreferredCities: []string{"Berlin"},
+		})
+		require.NoError(t, err)
+		tickets = append(tickets, resp.TicketId)
+	}
+	for range tickets {
+		_, err := fr.ScheduleNext(ctx, &fairrentv1.ScheduleNextRequest{})
+		require.NoError(t, err)
+	}
+
+	// Only the latest decision is kept, without the request
+	_, err := fr.ExplainDecision(ctx, &fairrentv1.ExplainDecisionRequest{TicketId: tickets[0]})
+	assert.ErrorIs(t, err, ErrTicketNotFound)
+
+	decision, exists := fr.decisions[tickets[1].Value]
+	require.True(t, exists)
+	assert.Nil(t, decision.Ticket.Constraints)
+	owner, ok := fr.TicketOwner(tickets[1].Value)
+	assert.True(t, ok)
+	assert.Equal(t, "second", owner)
+}
+
+func TestFairRent_ExplainDecisionRankedAboveCap(t *testing.T) {
+	fr := NewFairRent(nil, zap.NewNop())
+	ctx := context.Background()
+
+	for i := 0; i < DefaultMaxRankedAbove+5; i++ {
+		_, err := fr.Enqueue(ctx, &fairrentv1.EnqueueRequest{
+			UserId:    &commonv1.UserID{Value: "user"},
+			UserGroup: commonv1.UserGroup_USER_GROUP_STUDENT,
+			Urgency:   commonv1.UrgencyLevel_URGENCY_LEVEL_MEDIUM,
+		})
+		require.NoError(t, err)
+	}
+	last, err := fr.Enqueue(ctx, &fairrentv1.EnqueueRequest{
+		UserId:    &commonv1.UserID{Value: "last"},
+		UserGroup: commonv1.UserGroup_USER_GROUP_STUDENT,
+		Urgency:   commonv1.UrgencyLevel_URGENCY_LEVEL_MEDIUM,
+	})
+	require.NoError(t, err)
+
+	resp, err := fr.ExplainDecision(ctx, &fairrentv1.ExplainDecisionRequest{TicketId: last.TicketId})
+	require.NoError(t, err)
+	assert.Equal(t, int32(DefaultMaxRankedAbove+6), resp.CurrentPosition)
+	assert.Len(t, resp.RankedAbove, DefaultMaxRankedAbove)
+
+	resp, err = fr.ExplainDecision(ctx, &fairrentv1.ExplainDecisionRequest{TicketId: last.TicketId, MaxRankedAbove: 3})
+	require.NoError(t, err)
+	assert.Len(t, resp.RankedAbove, 3)
+}
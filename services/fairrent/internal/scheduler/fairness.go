@@ -0,0 +1,220 @@
+package scheduler
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/common/v1"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/fairrent/v1"
+)
+
+// welfareRateFloor keeps the α-fair utility finite for groups that have not
+// received any allocation yet
+const welfareRateFloor = 1e-6
+
+// envyTolerance ignores differences in entitlement-adjusted rates below this value
+const envyTolerance = 1e-9
+
+// JainIndex computes Jain's fairness index (Σx)² / (n·Σx²), which is 1 when
+// all values are equal and 1/n when a single value takes everything
+func JainIndex(values []float64) float64 {
+	if len(values) == 0 {
+		return 1
+	}
+
+	sum, sumSquares := 0.0, 0.0
+	for _, v := range values {
+		sum += v
+		sumSquares += v * v
+	}
+	if sumSquares == 0 {
+		return 1
+	}
+	return sum * sum / (float64(len(values)) * sumSquares)
+}
+
+// AtkinsonIndex computes the Atkinson inequality index with aversion ε ≥ 0:
+// 1 - EDE/mean, where EDE is the equally distributed equivalent
+// (mean of x^(1-ε))^(1/(1-ε)), or the geometric mean for ε = 1
+func AtkinsonIndex(values []float64, epsilon float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	mean := 0.0
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	if mean == 0 {
+		return 0
+	}
+
+	n := float64(len(values))
+	var ede float64
+	if epsilon == 1 {
+		logSum := 0.0
+		for _, v := range values {
+			if v <= 0 {
+				return 1
+			}
+			logSum += math.Log(v)
+		}
+		ede = math.Exp(logSum / n)
+	} else {
+		powSum := 0.0
+		for _, v := range values {
+			if v <= 0 && epsilon > 1 {
+				return 1
+			}
+			powSum += math.Pow(v, 1-epsilon)
+		}
+		ede = math.Pow(powSum/n, 1/(1-epsilon))
+	}
+
+	return 1 - ede/mean
+}
+
+// AlphaFairUtility is the α-fair utility x^(1-α)/(1-α), or ln x for α = 1
+func AlphaFairUtility(x, alpha float64) float64 {
+	if x < welfareRateFloor {
+		x = welfareRateFloor
+	}
+	if alpha == 1 {
+		return math.Log(x)
+	}
+	return math.Pow(x, 1-alpha) / (1 - alpha)
+}
+
+// GroupOutcome holds the per-group counts the extended fairness metrics are derived from
+type GroupOutcome struct {
+	Group                string
+	Weight               float64
+	Requests             int
+	Allocations          int
+	QualifiedRequests    int // requests at or above the qualifying urgency
+	QualifiedAllocations int
+}
+
+// SelectionRate is the fraction of the group's requests that were allocated
+func (g GroupOutcome) SelectionRate() float64 {
+	if g.Requests == 0 {
+		return 0
+	}
+	return float64(g.Allocations) / float64(g.Requests)
+}
+
+// QualifiedSelectionRate is the selection rate among qualified requests
+func (g GroupOutcome) QualifiedSelectionRate() float64 {
+	if g.QualifiedRequests == 0 {
+		return 0
+	}
+	return float64(g.QualifiedAllocations) / float64(g.QualifiedRequests)
+}
+
+// entitledRate is the selection rate relative to the group's weight
+func (g GroupOutcome) entitledRate() float64 {
+	if g.Weight <= 0 {
+		return 0
+	}
+	return g.SelectionRate() / g.Weight
+}
+
+// EnviedGroups returns, for each group, the groups it envies: those whose
+// selection rate relative to their weight exceeds its own
+func EnviedGroups(outcomes []GroupOutcome) map[string][]string {
+	envied := make(map[string][]string, len(outcomes))
+	for _, a := range outcomes {
+		for _, b := range outcomes {
+			if a.Group != b.Group && b.entitledRate() > a.entitledRate()+envyTolerance {
+				envied[a.Group] = append(envied[a.Group], b.Group)
+			}
+		}
+	}
+	return envied
+}
+
+// ExtendedFairness computes the extended metric suite from group outcomes
+func ExtendedFairness(outcomes []GroupOutcome, alpha float64, atkinsonEpsilons []float64) *fairrentv1.ExtendedFairnessMetrics {
+	sorted := make([]GroupOutcome, len(outcomes))
+	copy(sorted, outcomes)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Group < sorted[j].Group })
+
+	totals := GroupOutcome{}
+	for _, g := range sorted {
+		totals.Requests += g.Requests
+		totals.Allocations += g.Allocations
+		totals.QualifiedRequests += g.QualifiedRequests
+		totals.QualifiedAllocations += g.QualifiedAllocations
+	}
+	overallRate := totals.SelectionRate()
+	overallQualifiedRate := totals.QualifiedSelectionRate()
+
+	// Inequality is measured on selection rates relative to group weights,
+	// so that intended priorities do not count as unfairness
+	entitled := make([]float64, len(sorted))
+	welfare := 0.0
+	for i, g := range sorted {
+		entitled[i] = g.entitledRate()
+		welfare += g.Weight * AlphaFairUtility(g.SelectionRate(), alpha)
+	}
+
+	metrics := &fairrentv1.ExtendedFairnessMetrics{
+		JainIndex:                     JainIndex(entitled),
+		AtkinsonIndex:                 make(map[string]float64, len(atkinsonEpsilons)),
+		AlphaFairWelfare:              welfare,
+		OverallSelectionRate:          overallRate,
+		OverallQualifiedSelectionRate: overallQualifiedRate,
+	}
+	for _, epsilon := range atkinsonEpsilons {
+		metrics.AtkinsonIndex[formatEpsilon(epsilon)] = AtkinsonIndex(entitled, epsilon)
+	}
+
+	envied := EnviedGroups(sorted)
+	for _, g := range sorted {
+		groupMetrics := &fairrentv1.GroupExtendedFairnessMetrics{
+			UserGroup:              commonv1.UserGroup(commonv1.UserGroup_value[g.Group]),
+			SelectionRate:          g.SelectionRate(),
+			QualifiedSelectionRate: g.QualifiedSelectionRate(),
+			DemographicParityGap:   g.SelectionRate() - overallRate,
+			Envies:                 int32(len(envied[g.Group])),
+		}
+		if g.QualifiedRequests > 0 {
+			groupMetrics.EqualOpportunityGap = g.QualifiedSelectionRate() - overallQualifiedRate
+		}
+		for _, other := range envied[g.Group] {
+			groupMetrics.EnviedGroups = append(groupMetrics.EnviedGroups, commonv1.UserGroup(commonv1.UserGroup_value[other]))
+		}
+
+		metrics.EnvyPairs += groupMetrics.Envies
+		metrics.GroupMetrics = append(metrics.GroupMetrics, groupMetrics)
+	}
+
+	return metrics
+}
+
+// calculateExtendedMetrics computes the extended fairness suite from the
+// recorded requests and allocations
+func (fr *FairRent) calculateExtendedMetrics() *fairrentv1.ExtendedFairnessMetrics {
+	groupStats := fr.metrics.GetGroupStats()
+
+	outcomes := make([]GroupOutcome, 0, len(groupStats))
+	for group, stats := range groupStats {
+		outcomes = append(outcomes, GroupOutcome{
+			Group:                group,
+			Weight:               fr.groupWeight(group),
+			Requests:             stats.Count,
+			Allocations:          stats.Allocations,
+			QualifiedRequests:    stats.QualifiedCount,
+			QualifiedAllocations: stats.QualifiedAllocations,
+		})
+	}
+
+	return ExtendedFairness(outcomes, fr.alpha, fr.config.AtkinsonEpsilons)
+}
+
+// formatEpsilon renders an Atkinson ε as a map key and metric label
+func formatEpsilon(epsilon float64) string {
+	return strconv.FormatFloat(epsilon, 'f', -1, 64)
+}
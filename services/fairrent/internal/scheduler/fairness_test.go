@@ -0,0 +1,57 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/common/v1"
+)
+
+func TestJainAndAtkinsonIndex(t *testing.T) {
+	equal := []float64{0.5, 0.5, 0.5, 0.5}
+	assert.InDelta(t, 1.0, JainIndex(equal), 1e-9)
+	assert.InDelta(t, 0.0, AtkinsonIndex(equal, 0.5), 1e-9)
+	assert.InDelta(t, 0.0, AtkinsonIndex(equal, 1), 1e-9)
+
+	// One value takes everything
+	single := []float64{1, 0, 0, 0}
+	assert.InDelta(t, 0.25, JainIndex(single), 1e-9)
+	assert.InDelta(t, 1.0, AtkinsonIndex(single, 1), 1e-9)
+	assert.InDelta(t, 1.0, AtkinsonIndex(single, 2), 1e-9)
+
+	// Higher ε weighs inequality more heavily
+	uneven := []float64{0.2, 0.4, 0.8}
+	assert.Less(t, AtkinsonIndex(uneven, 0.5), AtkinsonIndex(uneven, 1))
+	assert.Less(t, AtkinsonIndex(uneven, 1), AtkinsonIndex(uneven, 2))
+}
+
+func TestExtendedFairness(t *testing.T) {
+	outcomes := []GroupOutcome{
+		{Group: "USER_GROUP_STUDENT", Weight: 1.0, Requests: 10, Allocations: 2, QualifiedRequests: 4, QualifiedAllocations: 2},
+		{Group: "USER_GROUP_REFUGEE", Weight: 1.5, Requests: 10, Allocations: 6, QualifiedRequests: 6, QualifiedAllocations: 6},
+	}
+
+	metrics := ExtendedFairness(outcomes, 2.0, []float64{0.5, 1})
+
+	assert.InDelta(t, 0.4, metrics.OverallSelectionRate, 1e-9)
+	assert.InDelta(t, 0.8, metrics.OverallQualifiedSelectionRate, 1e-9)
+	assert.Contains(t, metrics.AtkinsonIndex, "0.5")
+	assert.Contains(t, metrics.AtkinsonIndex, "1")
+	assert.Less(t, metrics.JainIndex, 1.0)
+
+	// Students (0.2 / 1.0) envy refugees (0.6 / 1.5), not the other way round
+	assert.Equal(t, int32(1), metrics.EnvyPairs)
+	require.Len(t, metrics.GroupMetrics, 2)
+
+	refugee, student := metrics.GroupMetrics[0], metrics.GroupMetrics[1]
+	assert.Equal(t, commonv1.UserGroup_USER_GROUP_REFUGEE, refugee.UserGroup)
+	assert.InDelta(t, 0.2, refugee.DemographicParityGap, 1e-9)
+	assert.InDelta(t, 0.2, refugee.EqualOpportunityGap, 1e-9)
+	assert.Empty(t, refugee.EnviedGroups)
+
+	assert.Equal(t, commonv1.UserGroup_USER_GROUP_STUDENT, student.UserGroup)
+	assert.InDelta(t, -0.2, student.DemographicParityGap, 1e-9)
+	assert.InDelta(t, -0.3, student.EqualOpportunityGap, 1e-9)
+	assert.Equal(t, []commonv1.UserGroup{commonv1.UserGroup_USER_GROUP_REFUGEE}, student.EnviedGroups)
+}
@@ -3,18 +3,48 @@ package scheduler
 import (
 	"container/heap"
 	"context"
+	"errors"
 	"fmt"
-	"math"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/audit"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/commitment"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/events"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/telemetry"
 	"github.com/wohnfair/wohnfair/services/gen/wohnfair/common/v1"
 	"github.com/wohnfair/wohnfair/services/gen/wohnfair/fairrent/v1"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+// Errors for requests the scheduler cannot serve in its current state
+var (
+	// ErrTicketNotFound is returned for tickets that are neither queued nor
+	// allocated
+	ErrTicketNotFound = errors.New("ticket not found")
+
+	// ErrTicketNotQueued is returned for queue operations on tickets that have
+	// already been allocated
+	ErrTicketNotQueued = errors.New("ticket is no longer queued")
+
+	// ErrQueueEmpty is returned by ScheduleNext when no ticket is queued
+	ErrQueueEmpty = errors.New("queue is empty")
+
+	// ErrQueueFull is returned for new tickets once MaxQueueSize are queued
+	ErrQueueFull = errors.New("queue is full")
+
+	// ErrInvalidPolicy is returned for simulated or configured policies
+	// with invalid parameters
+	ErrInvalidPolicy = errors.New("invalid policy")
+)
+
 // FairRent implements α-fair scheduling for housing allocation
 type FairRent struct {
 	mu sync.RWMutex
@@ -23,16 +53,54 @@ type FairRent struct {
 	queue     *PriorityQueue
 	ticketMap map[string]*Ticket
 
-	// Fairness parameters
-	alpha        float64
-	groupWeights map[string]float64
+	// Allocation decisions, kept for explanations, and their ticket IDs in
+	// the order they were made
+	decisions     map[string]*Decision
+	decisionOrder []string
+
+	// Latest published commitment over the queue order
+	commitment       *commitment.Commitment
+	commitmentAnchor uint64
+
+	// Fairness parameters of the policy version in effect
+	alpha         float64
+	groupWeights  map[string]float64
+	policyVersion int
+
+	// Every policy version in creation order, and the timer putting the
+	// next scheduled one into force
+	policies    []*PolicyVersion
+	policyTimer *time.Timer
 
 	// Metrics
 	metrics *Metrics
 
+	// Arrival and allocation rates behind wait time estimates
+	estimator *waitEstimator
+
+	// WatchPosition streams by ticket ID, and their number by caller
+	watchers    map[string]map[*watcher]struct{}
+	watchCounts map[string]int
+
 	// Configuration
 	config *Config
 
+	// City labels of request metrics by normalised city name
+	metricCities map[string]string
+
+	// Audit trail
+	audit *audit.Log
+
+	// Feed of ticket events for downstream consumers
+	events *events.Feed
+
+	// Reason the scheduler is not ready, empty while ready
+	notReady string
+
+	// Operating mode, set by admins, and since when it applies
+	mode      Mode
+	modeSince time.Time
+
 	logger *zap.Logger
 }
 
@@ -42,6 +110,47 @@ type Config struct {
 	GroupWeights map[string]float64 `yaml:"group_weights"`
 	MaxWaitTime  time.Duration      `yaml:"max_wait_time"`
 	LogLevel     string             `yaml:"log_level"`
+
+	// Extended fairness metrics
+	AtkinsonEpsilons []float64 `yaml:"atkinson_epsilons"`
+	QualifiedUrgency int       `yaml:"qualified_urgency"` // minimum urgency counted for equal opportunity
+
+	// Sliding windows for wait-time quantiles
+	WaitTimeWindows []time.Duration `yaml:"wait_time_windows"`
+
+	// How far back arrivals and allocations inform wait time estimates
+	EstimationWindow time.Duration `yaml:"estimation_window"`
+
+	// Minimum time between two updates on a WatchPosition stream
+	WatchInterval time.Duration `yaml:"watch_interval"`
+
+	// Most WatchPosition streams one caller may hold open at once; zero is
+	// DefaultMaxWatchesPerCaller and negative is unlimited
+	MaxWatchesPerCaller int `yaml:"max_watches_per_caller"`
+
+	// How long allocation decisions are kept for ExplainDecision, ticket
+	// ownership and exports, and the most kept at once; zero is
+	// DefaultDecisionRetention and DefaultMaxDecisions
+	DecisionRetention time.Duration `yaml:"decision_retention"`
+	MaxDecisions      int           `yaml:"max_decisions"`
+
+	// Cities labelled by name on request metrics. Cities are matched
+	// ignoring case; others are labelled other, keeping the number of
+	// series bounded.
+	MetricCities []string `yaml:"metric_cities"`
+
+	// Most tickets queued at once, set from queue.max_size; zero is unlimited
+	MaxQueueSize int `yaml:"-"`
+
+	// AuditLog receives audit records; an in-memory log is used if nil
+	AuditLog *audit.Log `yaml:"-"`
+
+	// EventFeed receives ticket events; an in-memory feed is used if nil
+	EventFeed *events.Feed `yaml:"-"`
+
+	// Registerer receives the scheduler's Prometheus metrics; they are left
+	// unregistered if nil
+	Registerer prometheus.Registerer `yaml:"-"`
 }
 
 // DefaultConfig returns default configuration
@@ -59,9 +168,61 @@ func DefaultConfig() *Config {
 			"USER_GROUP_MIDDLE_INCOME": 0.8, // Lower for middle income
 			"USER_GROUP_HIGH_INCOME": 0.7,   // Lower for high income
 		},
-		MaxWaitTime: 24 * time.Hour, // Maximum wait time before starvation protection
-		LogLevel:    "info",
+		MaxWaitTime:         24 * time.Hour, // Maximum wait time before starvation protection
+		LogLevel:            "info",
+		AtkinsonEpsilons:    []float64{0.5, 1, 2},
+		QualifiedUrgency:    int(commonv1.UrgencyLevel_URGENCY_LEVEL_HIGH),
+		WaitTimeWindows:     append([]time.Duration(nil), DefaultWaitTimeWindows...),
+		EstimationWindow:    DefaultEstimationWindow,
+		WatchInterval:       DefaultWatchInterval,
+		MaxWatchesPerCaller: DefaultMaxWatchesPerCaller,
+		DecisionRetention:   DefaultDecisionRetention,
+		MaxDecisions:        DefaultMaxDecisions,
+		MetricCities:        append([]string(nil), DefaultMetricCities...),
+	}
+}
+
+// Validate checks the fairness policy and the other settings
+func (c *Config) Validate() error {
+	if err := ValidatePolicy(c.Alpha, c.GroupWeights); err != nil {
+		return err
+	}
+	if c.MaxWaitTime < 0 {
+		return fmt.Errorf("max_wait_time must not be negative")
+	}
+	for _, epsilon := range c.AtkinsonEpsilons {
+		if epsilon < 0 {
+			return fmt.Errorf("atkinson_epsilons must not be negative")
+		}
+	}
+	if c.QualifiedUrgency != 0 {
+		if _, ok := commonv1.UrgencyLevel_name[int32(c.QualifiedUrgency)]; !ok {
+			return fmt.Errorf("qualified_urgency %d is not an urgency level", c.QualifiedUrgency)
+		}
+	}
+	for _, window := range c.WaitTimeWindows {
+		if window <= 0 {
+			return fmt.Errorf("wait_time_windows must be positive")
+		}
+	}
+	if c.EstimationWindow < 0 || c.WatchInterval < 0 {
+		return fmt.Errorf("estimation_window and watch_interval must not be negative")
+	}
+	if c.MaxQueueSize < 0 {
+		return fmt.Errorf("max queue size must not be negative")
+	}
+	if c.DecisionRetention < 0 || c.MaxDecisions < 0 {
+		return fmt.Errorf("decision_retention and max_decisions must not be negative")
+	}
+	if len(c.MetricCities) > maxMetricCities {
+		return fmt.Errorf("metric_cities must not list more than %d cities", maxMetricCities)
+	}
+	for _, city := range c.MetricCities {
+		if strings.TrimSpace(city) == "" {
+			return fmt.Errorf("metric_cities must not be empty")
+		}
 	}
+	return nil
 }
 
 // NewFairRent creates a new scheduler instance
@@ -69,37 +230,73 @@ func NewFairRent(config *Config, logger *zap.Logger) *FairRent {
 	if config == nil {
 		config = DefaultConfig()
 	}
+	auditLog := config.AuditLog
+	if auditLog == nil {
+		auditLog = audit.NewLog()
+	}
+	eventFeed := config.EventFeed
+	if eventFeed == nil {
+		eventFeed = events.NewFeed(events.DefaultCapacity)
+	}
 
 	fr := &FairRent{
 		queue:        &PriorityQueue{},
 		ticketMap:    make(map[string]*Ticket),
+		decisions:    make(map[string]*Decision),
 		alpha:        config.Alpha,
 		groupWeights: config.GroupWeights,
-		metrics:      NewMetrics(),
+		metrics:      NewMetrics(config.Registerer, config.WaitTimeWindows),
+		estimator:    newWaitEstimator(config.EstimationWindow, time.Now()),
+		watchers:     make(map[string]map[*watcher]struct{}),
+		watchCounts:  make(map[string]int),
 		config:       config,
+		metricCities: metricCityLabels(config.MetricCities),
+		audit:        auditLog,
+		events:       eventFeed,
+		mode:         ModeRunning,
+		modeSince:    time.Now(),
 		logger:       logger,
 	}
 
 	heap.Init(fr.queue)
+	fr.restorePolicies(time.Now())
 	return fr
 }
 
 // Enqueue adds a new housing request to the queue
 func (fr *FairRent) Enqueue(ctx context.Context, req *fairrentv1.EnqueueRequest) (*fairrentv1.EnqueueResponse, error) {
+	ctx, span := telemetry.StartSpan(ctx, "scheduler.Enqueue")
+	defer span.End()
+
 	fr.mu.Lock()
 	defer fr.mu.Unlock()
 
+	// Scores use the policy in effect, even if its timer has not fired yet
+	fr.activateDuePolicies(time.Now())
+
+	if fr.mode == ModeDraining {
+		telemetry.RecordError(ctx, ErrDraining)
+		return nil, ErrDraining
+	}
+	if fr.queueFull(0) {
+		telemetry.RecordError(ctx, ErrQueueFull)
+		return nil, ErrQueueFull
+	}
+
 	// Generate ticket ID
 	ticketID := generateTicketID()
+	now := time.Now()
 
 	// Create ticket
+	factors := fr.scoreFactors(req)
 	ticket := &Ticket{
 		ID:           ticketID,
 		UserID:       req.UserId.Value,
 		UserGroup:    req.UserGroup.String(),
 		Urgency:      int(req.Urgency),
-		EnqueueTime:  time.Now(),
-		PriorityScore: fr.calculatePriorityScore(req),
+		EnqueueTime:  now,
+		PriorityScore: factors.Score(),
+		Factors:       factors,
 		Constraints:   req,
 	}
 
@@ -108,64 +305,331 @@ func (fr *FairRent) Enqueue(ctx context.Context, req *fairrentv1.EnqueueRequest)
 	fr.ticketMap[ticketID] = ticket
 
 	// Update metrics
-	fr.metrics.RequestsEnqueued.Inc()
-	fr.metrics.QueueLength.Set(float64(fr.queue.Len()))
+	fr.metrics.RecordRequestEnqueued(fr.requestLabels(ticket), fr.isQualified(ticket))
+	fr.metrics.RecordExtendedFairness(fr.calculateExtendedMetrics())
+	fr.estimator.recordArrival(now, ticket.UserGroup, ticket.PriorityScore)
+
+	// Estimate the wait; bounded estimates are validated on allocation
+	estimate, estimated := fr.estimateWaitTime(ticket, now)
+	if estimated && estimate.Bounded {
+		fr.estimator.issue(ticketID, estimate)
+	}
+	fr.notifyWatchers(now)
 
 	fr.logger.Info("Request enqueued",
 		zap.String("ticket_id", ticketID),
 		zap.String("user_group", req.UserGroup.String()),
 		zap.Int("urgency", int(req.Urgency)),
 		zap.Float64("priority_score", ticket.PriorityScore),
+		zap.Duration("estimated_wait", estimate.Median),
 	)
 
-	return &fairrentv1.EnqueueResponse{
+	position := fr.calculatePosition(ticket)
+	fr.publishEvent(ticketEvent(events.TypeEnqueued, ticket, func(e *events.Event) {
+		e.QueuePosition = position
+	}))
+	telemetry.SetSpanAttributes(ctx, telemetry.TicketAttributes(ticketID, ticket.UserGroup, ticket.PriorityScore)...)
+	telemetry.SetSpanAttributes(ctx,
+		telemetry.AttrUrgency.Int(ticket.Urgency),
+		telemetry.AttrQueuePosition.Int(position),
+		telemetry.AttrQueueLength.Int(fr.queue.Len()),
+	)
+
+	resp := &fairrentv1.EnqueueResponse{
 		TicketId: &commonv1.TicketID{Value: ticketID},
 		Status:   commonv1.AllocationStatus_ALLOCATION_STATUS_QUEUED,
-		QueuePosition: int32(fr.queue.Len()),
-		EstimatedAllocationTime: &timestamppb.Timestamp{
-			Seconds: time.Now().Add(fr.estimateWaitTime(ticket)).Unix(),
-		},
+		QueuePosition: int32(position),
 		Metadata: &commonv1.Metadata{
-			CreatedAt: &timestamppb.Timestamp{Seconds: time.Now().Unix()},
+			CreatedAt: &timestamppb.Timestamp{Seconds: now.Unix()},
 		},
-	}, nil
+	}
+	if estimated {
+		resp.WaitTimeEstimate = estimate.Proto()
+		if estimate.Bounded {
+			resp.EstimatedAllocationTime = timestamppb.New(now.Add(estimate.Median))
+		}
+	}
+
+	return resp, nil
 }
 
 // ScheduleNext processes the next allocation from the queue
 func (fr *FairRent) ScheduleNext(ctx context.Context, req *fairrentv1.ScheduleNextRequest) (*fairrentv1.ScheduleNextResponse, error) {
+	ctx, span := telemetry.StartSpan(ctx, "scheduler.ScheduleNext")
+	defer span.End()
+
 	fr.mu.Lock()
 	defer fr.mu.Unlock()
 
+	fr.activateDuePolicies(time.Now())
+
+	if fr.mode == ModePaused {
+		telemetry.RecordError(ctx, ErrSchedulingPaused)
+		return nil, ErrSchedulingPaused
+	}
 	if fr.queue.Len() == 0 {
-		return nil, fmt.Errorf("queue is empty")
+		err := ErrQueueEmpty
+		telemetry.RecordError(ctx, err)
+		return nil, err
 	}
 
 	// Get next ticket with highest priority
-	ticket := heap.Pop(fr.queue).(*Ticket)
+	ticket := fr.match(ctx, req)
 	delete(fr.ticketMap, ticket.ID)
+	fr.recordDecision(ticket)
 
 	// Update metrics
-	fr.metrics.RequestsProcessed.Inc()
-	fr.metrics.QueueLength.Set(float64(fr.queue.Len()))
+	now := time.Now()
+	waitTime := now.Sub(ticket.EnqueueTime)
+	fr.metrics.RecordRequestProcessed(fr.requestLabels(ticket), fr.isQualified(ticket), waitTime, ticket.PriorityScore)
+	fr.metrics.RecordExtendedFairness(fr.calculateExtendedMetrics())
+	fr.estimator.recordAllocation(now)
+	if estimate, issued := fr.estimator.resolve(ticket.ID); issued {
+		fr.metrics.RecordWaitEstimateOutcome(estimate, waitTime)
+	}
+
+	// The property is offered and, with no acceptance step yet, allocated at
+	// once; a watcher that has not caught up sees only the allocation
+	var property *commonv1.PropertyID
+	if len(req.AvailableProperties) > 0 {
+		property = req.AvailableProperties[0]
+		fr.publishEvent(ticketEvent(events.TypeOffered, ticket, func(e *events.Event) {
+			e.PropertyID = property.Value
+		}))
+		fr.notifyTicket(ticket.ID, &fairrentv1.PositionUpdate{
+			TicketId:        &commonv1.TicketID{Value: ticket.ID},
+			Event:           fairrentv1.PositionEvent_POSITION_EVENT_OFFERED,
+			Status:          commonv1.AllocationStatus_ALLOCATION_STATUS_SCHEDULED,
+			OfferedProperty: property,
+			UpdatedAt:       timestamppb.New(now),
+		})
+	}
+	fr.publishEvent(ticketEvent(events.TypeAllocated, ticket, func(e *events.Event) {
+		e.PropertyID = property.GetValue()
+		e.WaitTime = waitTime
+	}))
+	fr.notifyTicket(ticket.ID, &fairrentv1.PositionUpdate{
+		TicketId:        &commonv1.TicketID{Value: ticket.ID},
+		Event:           fairrentv1.PositionEvent_POSITION_EVENT_ALLOCATED,
+		Status:          commonv1.AllocationStatus_ALLOCATION_STATUS_ALLOCATED,
+		OfferedProperty: property,
+		UpdatedAt:       timestamppb.New(now),
+	})
+	fr.notifyWatchers(now)
 
 	fr.logger.Info("Request scheduled",
 		zap.String("ticket_id", ticket.ID),
 		zap.String("user_group", ticket.UserGroup),
 		zap.Float64("priority_score", ticket.PriorityScore),
-		zap.Duration("wait_time", time.Since(ticket.EnqueueTime)),
+		zap.Int("policy_version", ticket.Factors.PolicyVersion),
+		zap.Duration("wait_time", waitTime),
 	)
+	telemetry.SetSpanAttributes(ctx, telemetry.TicketAttributes(ticket.ID, ticket.UserGroup, ticket.PriorityScore)...)
 
 	return &fairrentv1.ScheduleNextResponse{
 		TicketId: &commonv1.TicketID{Value: ticket.ID},
+		AllocatedProperty: property,
 		UserId:   &commonv1.UserID{Value: ticket.UserID},
 		AllocationTime: &timestamppb.Timestamp{Seconds: time.Now().Unix()},
 		FairnessScore: ticket.PriorityScore,
+		PolicyVersion: int32(ticket.Factors.PolicyVersion),
 		Metadata: &commonv1.Metadata{
 			CreatedAt: &timestamppb.Timestamp{Seconds: time.Now().Unix()},
 		},
 	}, nil
 }
 
+// match takes the ticket to allocate next off the queue, in a child span
+// recording the candidates and the selected ticket
+func (fr *FairRent) match(ctx context.Context, req *fairrentv1.ScheduleNextRequest) *Ticket {
+	_, span := telemetry.StartSpan(ctx, "scheduler.match", trace.WithAttributes(
+		telemetry.AttrQueueLength.Int(fr.queue.Len()),
+		telemetry.AttrProperties.Int(len(req.AvailableProperties)),
+	))
+	defer span.End()
+
+	ticket := heap.Pop(fr.queue).(*Ticket)
+	span.SetAttributes(telemetry.TicketAttributes(ticket.ID, ticket.UserGroup, ticket.PriorityScore)...)
+	return ticket
+}
+
+// UpdateRequest changes the urgency, locations, financial constraints or
+// preferences of a queued ticket and rescores it
+func (fr *FairRent) UpdateRequest(ctx context.Context, req *fairrentv1.UpdateRequestRequest) (*fairrentv1.UpdateRequestResponse, error) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	fr.activateDuePolicies(time.Now())
+
+	ticketID := req.TicketId.Value
+	ticket, exists := fr.ticketMap[ticketID]
+	if !exists {
+		return nil, fr.unqueuedTicketError(ticketID)
+	}
+
+	constraints := &fairrentv1.EnqueueRequest{}
+	if original, ok := ticket.Constraints.(*fairrentv1.EnqueueRequest); ok {
+		constraints = proto.Clone(original).(*fairrentv1.EnqueueRequest)
+	}
+	if req.NewUrgency != commonv1.UrgencyLevel_URGENCY_LEVEL_UNSPECIFIED {
+		constraints.Urgency = req.NewUrgency
+	}
+	if len(req.NewPreferredLocations) > 0 {
+		constraints.PreferredLocations = req.NewPreferredLocations
+	}
+	if req.NewFinancialConstraints != nil {
+		constraints.FinancialConstraints = req.NewFinancialConstraints
+	}
+	if len(req.NewAdditionalPreferences) > 0 {
+		ticket.Preferences = req.NewAdditionalPreferences
+	}
+
+	// Rescore; the enqueue time, which breaks ties, is kept
+	oldLabels := fr.requestLabels(ticket)
+	factors := fr.scoreFactors(constraints)
+	ticket.Urgency = int(constraints.Urgency)
+	ticket.Factors = factors
+	ticket.Constraints = constraints
+	fr.queue.UpdatePriority(ticketID, factors.Score())
+	fr.metrics.RecordRequestUpdated(oldLabels, fr.requestLabels(ticket))
+
+	// The estimate given at enqueue no longer applies
+	fr.estimator.resolve(ticketID)
+
+	now := time.Now()
+	position := fr.calculatePosition(ticket)
+	estimate, estimated := fr.estimator.estimate(now, position-1, ticket.PriorityScore)
+
+	fr.logger.Info("Request updated",
+		zap.String("ticket_id", ticketID),
+		zap.Int("urgency", ticket.Urgency),
+		zap.Float64("priority_score", ticket.PriorityScore),
+		zap.Int("position", position),
+	)
+
+	fr.publishEvent(ticketEvent(events.TypeUpdated, ticket, func(e *events.Event) {
+		e.QueuePosition = position
+	}))
+	fr.notifyWatchers(now)
+
+	resp := &fairrentv1.UpdateRequestResponse{
+		TicketId:         req.TicketId,
+		Updated:          true,
+		NewQueuePosition: int32(position),
+		Metadata: &commonv1.Metadata{
+			UpdatedAt: timestamppb.New(now),
+		},
+	}
+	if estimated && estimate.Bounded {
+		resp.NewEstimatedAllocationTime = timestamppb.New(now.Add(estimate.Median))
+	}
+	return resp, nil
+}
+
+// CancelRequest removes a queued ticket
+func (fr *FairRent) CancelRequest(ctx context.Context, req *fairrentv1.CancelRequestRequest) (*fairrentv1.CancelRequestResponse, error) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	ticketID := req.TicketId.Value
+	ticket, exists := fr.ticketMap[ticketID]
+	if !exists {
+		return nil, fr.unqueuedTicketError(ticketID)
+	}
+
+	fr.queue.RemoveByID(ticketID)
+	delete(fr.ticketMap, ticketID)
+
+	now := time.Now()
+	waitTime := now.Sub(ticket.EnqueueTime)
+	fr.metrics.RecordRequestCancelled(fr.requestLabels(ticket), waitTime)
+	fr.estimator.resolve(ticketID)
+
+	fr.logger.Info("Request cancelled",
+		zap.String("ticket_id", ticketID),
+		zap.String("user_group", ticket.UserGroup),
+		zap.Duration("wait_time", waitTime),
+		zap.String("reason", req.Reason),
+	)
+
+	fr.publishEvent(ticketEvent(events.TypeCancelled, ticket, func(e *events.Event) {
+		e.WaitTime = waitTime
+		e.Reason = req.Reason
+	}))
+	fr.notifyTicket(ticketID, &fairrentv1.PositionUpdate{
+		TicketId:  req.TicketId,
+		Event:     fairrentv1.PositionEvent_POSITION_EVENT_CANCELLED,
+		Status:    commonv1.AllocationStatus_ALLOCATION_STATUS_CANCELLED,
+		Reason:    req.Reason,
+		UpdatedAt: timestamppb.New(now),
+	})
+	fr.notifyWatchers(now)
+
+	return &fairrentv1.CancelRequestResponse{
+		TicketId:         req.TicketId,
+		Cancelled:        true,
+		CancellationTime: timestamppb.New(now),
+		Metadata: &commonv1.Metadata{
+			UpdatedAt: timestamppb.New(now),
+		},
+	}, nil
+}
+
+// Events returns the feed of ticket events
+func (fr *FairRent) Events() *events.Feed {
+	return fr.events
+}
+
+// unqueuedTicketError explains why a ticket is not in the queue. Must be
+// called with the lock held.
+func (fr *FairRent) unqueuedTicketError(ticketID string) error {
+	if _, allocated := fr.decision(ticketID, time.Now()); allocated {
+		return fmt.Errorf("%w: %s has been allocated", ErrTicketNotQueued, ticketID)
+	}
+	return fmt.Errorf("%w: %s", ErrTicketNotFound, ticketID)
+}
+
+// TicketOwner returns the user ID of a queued or allocated ticket
+func (fr *FairRent) TicketOwner(ticketID string) (string, bool) {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	if ticket, exists := fr.ticketMap[ticketID]; exists {
+		return ticket.UserID, true
+	}
+	if decision, exists := fr.decision(ticketID, time.Now()); exists {
+		return decision.Ticket.UserID, true
+	}
+	return "", false
+}
+
+// publishEvent appends an event to the feed. Callers hold fr.mu, so events
+// are sequenced in the order the scheduler applied them.
+func (fr *FairRent) publishEvent(event events.Event) {
+	if _, err := fr.events.Publish(event); err != nil {
+		fr.logger.Error("Failed to publish event",
+			zap.Error(err),
+			zap.String("type", string(event.Type)),
+			zap.String("ticket_id", event.TicketID),
+		)
+	}
+}
+
+// ticketEvent describes a ticket, with set filling in type-specific fields
+func ticketEvent(eventType events.Type, ticket *Ticket, set func(*events.Event)) events.Event {
+	event := events.Event{
+		Type:          eventType,
+		TicketID:      ticket.ID,
+		UserID:        ticket.UserID,
+		UserGroup:     ticket.UserGroup,
+		Urgency:       ticket.Urgency,
+		PriorityScore: ticket.PriorityScore,
+		PolicyVersion: ticket.Factors.PolicyVersion,
+	}
+	set(&event)
+	return event
+}
+
 // PeekPosition returns the current position and estimated wait time
 func (fr *FairRent) PeekPosition(ctx context.Context, req *fairrentv1.PeekPositionRequest) (*fairrentv1.PeekPositionResponse, error) {
 	fr.mu.RLock()
@@ -174,34 +638,39 @@ func (fr *FairRent) PeekPosition(ctx context.Context, req *fairrentv1.PeekPositi
 	ticketID := req.TicketId.Value
 	ticket, exists := fr.ticketMap[ticketID]
 	if !exists {
-		return nil, fmt.Errorf("ticket not found: %s", ticketID)
+		return nil, fr.unqueuedTicketError(ticketID)
 	}
 
 	// Calculate position (this is simplified - in practice would need more sophisticated tracking)
 	position := fr.calculatePosition(ticket)
 
 	// Estimate wait time
-	estimatedWait := fr.estimateWaitTime(ticket)
+	now := time.Now()
+	estimate, estimated := fr.estimateWaitTime(ticket, now)
 
 	fr.logger.Debug("Position peeked",
 		zap.String("ticket_id", ticketID),
 		zap.Int("position", position),
-		zap.Duration("estimated_wait", estimatedWait),
+		zap.Duration("estimated_wait", estimate.Median),
+		zap.Bool("estimate_bounded", estimate.Bounded),
 	)
 
-	return &fairrentv1.PeekPositionResponse{
+	resp := &fairrentv1.PeekPositionResponse{
 		TicketId: req.TicketId,
 		CurrentPosition: int32(position),
 		TotalInQueue: int32(fr.queue.Len()),
-		EstimatedWaitTime: &durationpb.Duration{
-			Seconds: int64(estimatedWait.Seconds()),
-		},
-		EstimatedAllocationTime: &timestamppb.Timestamp{
-			Seconds: time.Now().Add(estimatedWait).Unix(),
-		},
 		FairnessScore: ticket.PriorityScore,
 		Status:        commonv1.AllocationStatus_ALLOCATION_STATUS_QUEUED,
-	}, nil
+	}
+	if estimated {
+		resp.WaitTimeEstimate = estimate.Proto()
+		if estimate.Bounded {
+			resp.EstimatedWaitTime = durationpb.New(estimate.Median)
+			resp.EstimatedAllocationTime = timestamppb.New(now.Add(estimate.Median))
+		}
+	}
+
+	return resp, nil
 }
 
 // GetMetrics returns fairness and performance metrics
@@ -239,98 +708,272 @@ func (fr *FairRent) GetMetrics(ctx context.Context) (*fairrentv1.FairnessMetrics
 		AllocationRate: metrics.AllocationRate,
 		QueueTurnoverRate: metrics.QueueTurnoverRate,
 		CalculatedAt: &timestamppb.Timestamp{Seconds: time.Now().Unix()},
+		Extended: fr.calculateExtendedMetrics(),
+		WaitTimeWindows: waitTimeWindowsProto(metrics.WaitTimeWindows),
+		OldestTicketAge: durationpb.New(fr.oldestTicketAge()),
+		WaitEstimateAccuracy: &fairrentv1.WaitEstimateAccuracy{
+			Evaluated:           metrics.WaitEstimateAccuracy.Evaluated,
+			IntervalCoverage:    metrics.WaitEstimateAccuracy.IntervalCoverage,
+			MedianRelativeError: metrics.WaitEstimateAccuracy.MedianRelativeError,
+		},
 	}, nil
 }
 
-// calculatePriorityScore computes the α-fair priority score
-func (fr *FairRent) calculatePriorityScore(req *fairrentv1.EnqueueRequest) float64 {
-	// Base priority from urgency
-	urgencyScore := float64(req.Urgency) / 5.0
+// GetQueueStatus counts the queued tickets by group and urgency and reports
+// the scheduler mode
+func (fr *FairRent) GetQueueStatus(ctx context.Context) (*fairrentv1.QueueStatus, error) {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	metrics := fr.metrics.GetMetrics()
+	now := time.Now()
+	status := &fairrentv1.QueueStatus{
+		TotalRequests:     int32(metrics.TotalRequests),
+		PendingRequests:   int32(fr.queue.Len()),
+		CompletedRequests: int32(metrics.TotalAllocations), // offers are allocated at once, so none are processing
+		GroupCounts:       make(map[string]int32),
+		UrgencyCounts:     make(map[string]int32),
+		StatusAt:          timestamppb.New(now),
+		Mode:              fr.mode.Proto(),
+	}
+	for _, ticket := range fr.queue.tickets {
+		status.GroupCounts[ticket.UserGroup]++
+		status.UrgencyCounts[commonv1.UrgencyLevel(ticket.Urgency).String()]++
+	}
+	if metrics.TotalRequests > 0 {
+		status.QueueEfficiency = float64(metrics.TotalAllocations) / float64(metrics.TotalRequests)
+	}
+
+	// Time to allocate every queued ticket at the recent allocation rate,
+	// not counting new arrivals, as while the queue drains
+	if estimate, ok := fr.estimator.estimate(now, 0, 0); ok && estimate.AllocationRate > 0 {
+		status.EstimatedCompletionTime = durationpb.New(hours(float64(fr.queue.Len()) / estimate.AllocationRate))
+	}
+	return status, nil
+}
 
-	// Group weight adjustment
-	groupWeight := 1.0
-	if weight, exists := fr.groupWeights[req.UserGroup.String()]; exists {
-		groupWeight = weight
+// oldestTicketAge returns how long the longest-waiting queued ticket has waited
+func (fr *FairRent) oldestTicketAge() time.Duration {
+	var oldest time.Duration
+	now := time.Now()
+	for _, ticket := range fr.queue.tickets {
+		if age := now.Sub(ticket.EnqueueTime); age > oldest {
+			oldest = age
+		}
 	}
+	return oldest
+}
 
-	// Additional priority factors
-	priorityBonus := req.PriorityScore
+// waitTimeWindowsProto converts sliding-window wait-time quantiles to protobuf format
+func waitTimeWindowsProto(stats []WaitTimeWindowStats) []*fairrentv1.WaitTimeWindow {
+	windows := make([]*fairrentv1.WaitTimeWindow, 0, len(stats))
+	for _, s := range stats {
+		window := &fairrentv1.WaitTimeWindow{
+			Window: durationpb.New(s.Window),
+			Count:  s.Count,
+			Mean:   durationpb.New(s.Mean),
+			P50:    durationpb.New(s.P50),
+			P95:    durationpb.New(s.P95),
+			P99:    durationpb.New(s.P99),
+		}
+		if s.UserGroup != allGroups {
+			window.UserGroup = commonv1.UserGroup(commonv1.UserGroup_value[s.UserGroup])
+		}
+		windows = append(windows, window)
+	}
+	return windows
+}
 
+// queueFull reports whether the queue, with pending more tickets about to be
+// added, has reached MaxQueueSize. Must be called with the lock held.
+func (fr *FairRent) queueFull(pending int) bool {
+	return fr.config.MaxQueueSize > 0 && fr.queue.Len()+pending >= fr.config.MaxQueueSize
+}
+
+// Ready returns nil when the scheduler can take requests, or why it cannot
+func (fr *FairRent) Ready(ctx context.Context) error {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	if fr.notReady != "" {
+		return fmt.Errorf("scheduler not ready: %s", fr.notReady)
+	}
+	return nil
+}
+
+// MarkNotReady makes readiness checks fail, e.g. while shutting down
+func (fr *FairRent) MarkNotReady(reason string) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	fr.notReady = reason
+	fr.logger.Info("Scheduler marked not ready", zap.String("reason", reason))
+}
+
+// calculatePriorityScore computes the α-fair priority score
+func (fr *FairRent) calculatePriorityScore(req *fairrentv1.EnqueueRequest) float64 {
 	// α-fair formula: priority = (urgency * group_weight + priority_bonus)^α
-	basePriority := urgencyScore*groupWeight + priorityBonus
-	return math.Pow(basePriority, fr.alpha)
+	return fr.scoreFactors(req).Score()
 }
 
-// estimateWaitTime estimates how long a ticket will wait
-func (fr *FairRent) estimateWaitTime(ticket *Ticket) time.Duration {
-	// Simple estimation based on queue position and historical processing rate
-	position := fr.calculatePosition(ticket)
-	avgProcessingTime := fr.metrics.GetAverageProcessingTime()
-	
-	estimatedWait := time.Duration(position) * avgProcessingTime
-	
-	// Apply starvation protection
-	if estimatedWait > fr.config.MaxWaitTime {
-		estimatedWait = fr.config.MaxWaitTime
+// scoreFactors collects the inputs of the α-fair priority score
+func (fr *FairRent) scoreFactors(req *fairrentv1.EnqueueRequest) ScoreFactors {
+	return ScoreFactors{
+		UrgencyComponent: float64(req.Urgency) / 5.0,             // Base priority from urgency
+		GroupWeight:      fr.groupWeight(req.UserGroup.String()), // Group weight adjustment
+		PriorityBonus:    req.PriorityScore,                      // Additional priority factors
+		Alpha:            fr.alpha,
+		PolicyVersion:    fr.policyVersion,
 	}
-	
-	return estimatedWait
 }
 
-// calculatePosition estimates the ticket's position in the queue
+// estimateWaitTime estimates how much longer a queued ticket will wait. It
+// returns false until enough allocations have been observed.
+func (fr *FairRent) estimateWaitTime(ticket *Ticket, now time.Time) (WaitEstimate, bool) {
+	ahead := fr.calculatePosition(ticket) - 1
+	return fr.estimator.estimate(now, ahead, ticket.PriorityScore)
+}
+
+// calculatePosition returns the ticket's position in the queue, in the
+// order tickets are served and committed to: higher scores first, then
+// earlier enqueue times
 func (fr *FairRent) calculatePosition(ticket *Ticket) int {
-	// This is a simplified calculation
-	// In practice, would need more sophisticated position tracking
 	position := 1
 	for _, queuedTicket := range fr.queue.tickets {
-		if queuedTicket.PriorityScore > ticket.PriorityScore {
+		if ranksBefore(queuedTicket, ticket) {
 			position++
 		}
 	}
 	return position
 }
 
-// calculateGroupMetrics computes fairness metrics per user group
+// calculateGroupMetrics computes fairness metrics per user group from the
+// recorded requests and allocations. A group's target share of allocations is
+// its weighted share of demand: w_g * requests_g / Σ w_h * requests_h.
 func (fr *FairRent) calculateGroupMetrics() []*fairrentv1.GroupFairnessMetrics {
-	groupStats := make(map[string]*GroupStats)
+	groupStats := fr.metrics.GetGroupStats()
 	
-	// Collect statistics
-	for _, ticket := range fr.ticketMap {
-		if stats, exists := groupStats[ticket.UserGroup]; exists {
-			stats.Count++
-			stats.TotalWaitTime += time.Since(ticket.EnqueueTime)
-		} else {
-			groupStats[ticket.UserGroup] = &GroupStats{
-				Group: ticket.UserGroup,
-				Count: 1,
-				TotalWaitTime: time.Since(ticket.EnqueueTime),
-			}
-		}
+	// Weighted demand and total allocations
+	totalWeightedDemand := 0.0
+	totalAllocations := 0
+	for _, stats := range groupStats {
+		totalWeightedDemand += fr.groupWeight(stats.Group) * float64(stats.Count)
+		totalAllocations += stats.Allocations
+	}
+	
+	groups := make([]string, 0, len(groupStats))
+	for group := range groupStats {
+		groups = append(groups, group)
 	}
+	sort.Strings(groups)
 	
 	// Convert to protobuf format
 	var metrics []*fairrentv1.GroupFairnessMetrics
-	for _, stats := range groupStats {
-		avgWaitTime := stats.TotalWaitTime / time.Duration(stats.Count)
-		targetRate := 1.0 / float64(len(groupStats)) // Equal distribution target
+	for _, group := range groups {
+		stats := groupStats[group]
+		
+		var avgWaitTime time.Duration
+		if stats.WaitSamples > 0 {
+			avgWaitTime = stats.TotalWaitTime / time.Duration(stats.WaitSamples)
+		}
+		
+		targetRate := 0.0
+		if totalWeightedDemand > 0 {
+			targetRate = fr.groupWeight(group) * float64(stats.Count) / totalWeightedDemand
+		}
+		
+		allocationRate := 0.0
+		if totalAllocations > 0 {
+			allocationRate = float64(stats.Allocations) / float64(totalAllocations)
+		}
+		
+		ratio := 0.0
+		if targetRate > 0 {
+			ratio = allocationRate / targetRate
+		}
 		
 		metrics = append(metrics, &fairrentv1.GroupFairnessMetrics{
-			UserGroup: commonv1.UserGroup(commonv1.UserGroup_value[stats.Group]),
+			UserGroup: commonv1.UserGroup(commonv1.UserGroup_value[group]),
 			RequestsCount: int32(stats.Count),
-			AllocationsCount: 0, // Would track actual allocations
-			AllocationRate: float64(stats.Count) / float64(fr.queue.Len()),
+			AllocationsCount: int32(stats.Allocations),
+			AllocationRate: allocationRate,
 			AverageWaitTime: &durationpb.Duration{
 				Seconds: int64(avgWaitTime.Seconds()),
 			},
-			FairnessScore: 1.0, // Would calculate actual fairness score
+			FairnessScore: groupFairnessScore(ratio, totalAllocations),
 			TargetAllocationRate: targetRate,
-			ActualVsTargetRatio: float64(stats.Count) / float64(fr.queue.Len()) / targetRate,
+			ActualVsTargetRatio: ratio,
 		})
 	}
 	
 	return metrics
 }
 
+// groupWeight returns the configured weight of a group, 1.0 if unset
+func (fr *FairRent) groupWeight(group string) float64 {
+	if weight, exists := fr.groupWeights[group]; exists {
+		return weight
+	}
+	return 1.0
+}
+
+// isQualified reports whether a ticket counts as qualified for equal opportunity
+func (fr *FairRent) isQualified(ticket *Ticket) bool {
+	threshold := fr.config.QualifiedUrgency
+	if threshold == 0 {
+		threshold = int(commonv1.UrgencyLevel_URGENCY_LEVEL_HIGH)
+	}
+	return ticket.Urgency >= threshold
+}
+
+// requestLabels describes a ticket for metric labels. The city is the
+// request's first preferred city if it is one of the metric cities, since
+// labels taken from free text would create a series per distinct string.
+func (fr *FairRent) requestLabels(ticket *Ticket) RequestLabels {
+	labels := RequestLabels{
+		UserGroup: ticket.UserGroup,
+		Urgency:   commonv1.UrgencyLevel(ticket.Urgency).String(),
+		City:      UnknownCity,
+	}
+	if req, ok := ticket.Constraints.(*fairrentv1.EnqueueRequest); ok && len(req.PreferredCities) > 0 {
+		labels.City = OtherCity
+		if city, ok := fr.metricCities[normalizeCity(req.PreferredCities[0])]; ok {
+			labels.City = city
+		}
+	}
+	return labels
+}
+
+// metricCityLabels indexes the metric cities by normalised name
+func metricCityLabels(cities []string) map[string]string {
+	labels := make(map[string]string, len(cities))
+	for _, city := range cities {
+		labels[normalizeCity(city)] = strings.TrimSpace(city)
+	}
+	return labels
+}
+
+// normalizeCity folds case and surrounding whitespace of a city name
+func normalizeCity(city string) string {
+	return strings.ToLower(strings.TrimSpace(city))
+}
+
+// groupFairnessScore maps an actual-vs-target ratio to [0, 1], where 1 means
+// the group receives exactly its target share. Before any allocation has been
+// made there is nothing to judge, so every group scores 1.
+func groupFairnessScore(ratio float64, totalAllocations int) float64 {
+	if totalAllocations == 0 {
+		return 1.0
+	}
+	if ratio <= 0 {
+		return 0
+	}
+	if ratio > 1 {
+		return 1 / ratio
+	}
+	return ratio
+}
+
 // generateTicketID creates a unique ticket identifier
 func generateTicketID() string {
 	return fmt.Sprintf("TKT_%d", time.Now().UnixNano())
@@ -339,6 +982,11 @@ func generateTicketID() string {
 // GroupStats holds per-group statistics
 type GroupStats struct {
 	Group         string
-	Count         int
-	TotalWaitTime time.Duration
+	Count         int // requests enqueued
+	Allocations   int
+	TotalWaitTime time.Duration // over all allocations
+	WaitSamples   int
+
+	QualifiedCount       int
+	QualifiedAllocations int
 }
@@ -10,6 +10,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/events"
 	"github.com/wohnfair/wohnfair/services/gen/wohnfair/common/v1"
 	"github.com/wohnfair/wohnfair/services/gen/wohnfair/fairrent/v1"
 	"go.uber.org/zap"
@@ -169,6 +170,15 @@ func TestFairRent_PeekPosition(t *testing.T) {
 	_, err = fr.PeekPosition(ctx, nonExistentReq)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "ticket not found")
+	assert.ErrorIs(t, err, ErrTicketNotFound)
+
+	// Allocated tickets exist but are no longer queued
+	_, err = fr.ScheduleNext(ctx, &fairrentv1.ScheduleNextRequest{})
+	require.NoError(t, err)
+	_, err = fr.PeekPosition(ctx, peekReq)
+	assert.ErrorIs(t, err, ErrTicketNotQueued)
+	_, err = fr.ScheduleNext(ctx, &fairrentv1.ScheduleNextRequest{})
+	assert.ErrorIs(t, err, ErrQueueEmpty)
 }
 
 func TestFairRent_GetMetrics(t *testing.T) {
@@ -212,18 +222,38 @@ func TestFairRent_GetMetrics(t *testing.T) {
 	// Verify group metrics
 	assert.Len(t, metrics.GroupMetrics, 2)
 	
+	// Allocate the refugee request (highest priority)
+	_, err = fr.ScheduleNext(ctx, &fairrentv1.ScheduleNextRequest{})
+	require.NoError(t, err)
+	
+	metrics, err = fr.GetMetrics(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), metrics.TotalAllocations)
+	assert.Len(t, metrics.GroupMetrics, 2)
+	
 	// Find refugee group metrics
-	var refugeeMetrics *fairrentv1.GroupFairnessMetrics
+	var refugeeMetrics, studentMetrics *fairrentv1.GroupFairnessMetrics
 	for _, gm := range metrics.GroupMetrics {
-		if gm.UserGroup == commonv1.UserGroup_USER_GROUP_REFUGEE {
+		switch gm.UserGroup {
+		case commonv1.UserGroup_USER_GROUP_REFUGEE:
 			refugeeMetrics = gm
-			break
+		case commonv1.UserGroup_USER_GROUP_STUDENT:
+			studentMetrics = gm
 		}
 	}
 	
 	require.NotNil(t, refugeeMetrics)
 	assert.Equal(t, int32(1), refugeeMetrics.RequestsCount)
-	assert.Equal(t, float64(0.5), refugeeMetrics.AllocationRate) // 1 out of 2 total
+	assert.Equal(t, int32(1), refugeeMetrics.AllocationsCount)
+	assert.Equal(t, float64(1.0), refugeeMetrics.AllocationRate) // 1 out of 1 allocation
+	assert.InDelta(t, 0.6, refugeeMetrics.TargetAllocationRate, 1e-9) // 1.5 / (1.5 + 1.0)
+	assert.InDelta(t, 1.0/0.6, refugeeMetrics.ActualVsTargetRatio, 1e-9)
+	assert.InDelta(t, 0.6, refugeeMetrics.FairnessScore, 1e-9)
+	
+	require.NotNil(t, studentMetrics)
+	assert.Equal(t, int32(0), studentMetrics.AllocationsCount)
+	assert.Equal(t, float64(0), studentMetrics.AllocationRate)
+	assert.Equal(t, float64(0), studentMetrics.FairnessScore)
 }
 
 func TestFairRent_CalculatePriorityScore(t *testing.T) {
@@ -286,14 +316,22 @@ func TestFairRent_EstimateWaitTime(t *testing.T) {
 		PriorityScore: 1.0,
 	}
 	
-	// Test wait time estimation
-	waitTime := fr.estimateWaitTime(ticket)
+	// No estimate before any allocation has been observed
+	now := time.Now()
+	_, estimated := fr.estimateWaitTime(ticket, now)
+	assert.False(t, estimated)
 	
-	// Should be positive
-	assert.True(t, waitTime > 0)
+	// Ten allocations and no competing arrivals over ten hours
+	fr.estimator.started = now.Add(-10 * time.Hour)
+	for i := 0; i < 10; i++ {
+		fr.estimator.recordAllocation(now.Add(-time.Duration(i) * time.Hour))
+	}
 	
-	// Should not exceed max wait time
-	assert.True(t, waitTime <= fr.config.MaxWaitTime)
+	estimate, estimated := fr.estimateWaitTime(ticket, now)
+	require.True(t, estimated)
+	assert.True(t, estimate.Bounded)
+	assert.True(t, estimate.Lower < estimate.Median && estimate.Median < estimate.Upper)
+	assert.InDelta(t, time.Hour.Hours(), estimate.Median.Hours(), 0.5)
 }
 
 func TestFairRent_CalculatePosition(t *testing.T) {
@@ -324,36 +362,39 @@ func TestFairRent_CalculateGroupMetrics(t *testing.T) {
 	logger := zap.NewNop()
 	fr := NewFairRent(nil, logger)
 	
-	// Add tickets for different groups
+	// Record requests for different groups
 	groups := []string{"USER_GROUP_STUDENT", "USER_GROUP_REFUGEE", "USER_GROUP_SENIOR"}
 	
-	for i, group := range groups {
-		ticket := &Ticket{
-			ID:           fmt.Sprintf("ticket_%d", i),
-			UserID:       fmt.Sprintf("user_%d", i),
-			UserGroup:    group,
-			Urgency:      3,
-			EnqueueTime:  time.Now().Add(-time.Duration(i) * time.Hour),
-			PriorityScore: float64(i + 1),
-		}
-		fr.ticketMap[ticket.ID] = ticket
+	for _, group := range groups {
+		fr.metrics.RecordRequestEnqueued(RequestLabels{UserGroup: group}, false)
 	}
 	
+	// Allocate the student and the senior
+	fr.metrics.RecordRequestProcessed(RequestLabels{UserGroup: "USER_GROUP_STUDENT"}, false, 2*time.Hour, 1.0)
+	fr.metrics.RecordRequestProcessed(RequestLabels{UserGroup: "USER_GROUP_SENIOR"}, false, 4*time.Hour, 1.0)
+	
 	// Calculate group metrics
 	metrics := fr.calculateGroupMetrics()
 	
 	// Should have metrics for all groups
 	assert.Len(t, metrics, 3)
 	
-	// Verify each group has metrics
-	groupNames := make(map[string]bool)
+	// Verify each group has metrics derived from allocations
+	byGroup := make(map[string]*fairrentv1.GroupFairnessMetrics)
 	for _, gm := range metrics {
-		groupNames[gm.UserGroup.String()] = true
+		byGroup[gm.UserGroup.String()] = gm
 	}
 	
 	for _, group := range groups {
-		assert.True(t, groupNames[group], "Missing metrics for group: %s", group)
+		assert.Contains(t, byGroup, group, "Missing metrics for group: %s", group)
 	}
+	
+	assert.Equal(t, int32(1), byGroup["USER_GROUP_STUDENT"].AllocationsCount)
+	assert.Equal(t, 0.5, byGroup["USER_GROUP_STUDENT"].AllocationRate)
+	assert.Equal(t, int64(7200), byGroup["USER_GROUP_STUDENT"].AverageWaitTime.Seconds)
+	assert.Equal(t, int32(0), byGroup["USER_GROUP_REFUGEE"].AllocationsCount)
+	assert.InDelta(t, 1.5/3.7, byGroup["USER_GROUP_REFUGEE"].TargetAllocationRate, 1e-9) // 1.5 / (1.0 + 1.5 + 1.2)
+	assert.Equal(t, float64(0), byGroup["USER_GROUP_REFUGEE"].ActualVsTargetRatio)
 }
 
 func TestFairRent_Concurrency(t *testing.T) {
@@ -518,3 +559,58 @@ func BenchmarkFairRent_ScheduleNext(b *testing.B) {
 		}
 	}
 }
+
+func TestFairRent_PublishesEvents(t *testing.T) {
+	fr := NewFairRent(nil, zap.NewNop())
+	ctx := context.Background()
+
+	enqueue := func(user string) string {
+		resp, err := fr.Enqueue(ctx, &fairrentv1.EnqueueRequest{
+			UserId:    &commonv1.UserID{Value: user},
+			UserGroup: commonv1.UserGroup_USER_GROUP_STUDENT,
+			Urgency:   commonv1.UrgencyLevel_URGENCY_LEVEL_MEDIUM,
+		})
+		require.NoError(t, err)
+		return resp.TicketId.Value
+	}
+	first := enqueue("user1")
+	second := enqueue("user2")
+
+	updated, err := fr.UpdateRequest(ctx, &fairrentv1.UpdateRequestRequest{
+		TicketId:   &commonv1.TicketID{Value: second},
+		NewUrgency: commonv1.UrgencyLevel_URGENCY_LEVEL_CRITICAL,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), updated.NewQueuePosition)
+
+	_, err = fr.CancelRequest(ctx, &fairrentv1.CancelRequestRequest{
+		TicketId: &commonv1.TicketID{Value: first},
+		Reason:   "moved away",
+	})
+	require.NoError(t, err)
+
+	_, err = fr.ScheduleNext(ctx, &fairrentv1.ScheduleNextRequest{
+		AvailableProperties: []*commonv1.PropertyID{{Value: "prop1"}},
+	})
+	require.NoError(t, err)
+
+	feed, err := fr.Events().Since(0, 0)
+	require.NoError(t, err)
+	var types []events.Type
+	for i, event := range feed {
+		assert.Equal(t, uint64(i+1), event.Sequence)
+		types = append(types, event.Type)
+	}
+	assert.Equal(t, []events.Type{
+		events.TypeEnqueued, events.TypeEnqueued, events.TypeUpdated,
+		events.TypeCancelled, events.TypeOffered, events.TypeAllocated,
+	}, types)
+	assert.Equal(t, "moved away", feed[3].Reason)
+	assert.Equal(t, second, feed[5].TicketID)
+	assert.Equal(t, "prop1", feed[5].PropertyID)
+
+	// A consumer resuming after the cancellation sees only what followed
+	resumed, err := fr.Events().Since(feed[3].Sequence, 0)
+	require.NoError(t, err)
+	assert.Len(t, resumed, 2)
+}
@@ -8,22 +8,96 @@ import (
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/sketch"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/fairrent/v1"
 )
 
+// DefaultWaitTimeWindows are the sliding windows wait-time quantiles are kept over
+var DefaultWaitTimeWindows = []time.Duration{time.Hour, 24 * time.Hour, 30 * 24 * time.Hour}
+
+// allGroups keys the wait-time windows covering every group
+const allGroups = ""
+
+// Label names used by the request metrics
+const (
+	LabelUserGroup = "user_group"
+	LabelUrgency   = "urgency"
+	LabelCity      = "city"
+	LabelOutcome   = "outcome"
+)
+
+// Outcome labels of requests leaving the queue
+const (
+	OutcomeAllocated = "allocated"
+	OutcomeCancelled = "cancelled"
+)
+
+// City labels of requests whose city is not labelled by name
+const (
+	UnknownCity = "unknown" // no preferred city
+	OtherCity   = "other"   // a city not in metric_cities
+)
+
+// DefaultMetricCities are the cities labelled by name on request metrics by
+// default: Germany's largest
+var DefaultMetricCities = []string{
+	"Berlin", "Hamburg", "München", "Köln", "Frankfurt am Main",
+	"Stuttgart", "Düsseldorf", "Leipzig", "Dortmund", "Essen",
+}
+
+// maxMetricCities bounds the metric cities, and with them the series of
+// every request metric
+const maxMetricCities = 100
+
+var requestLabelNames = []string{LabelUserGroup, LabelUrgency, LabelCity}
+
+// RequestLabels are the label values describing a request
+type RequestLabels struct {
+	UserGroup string
+	Urgency   string
+	City      string
+}
+
+// values returns the label values in requestLabelNames order
+func (l RequestLabels) values(extra ...string) []string {
+	return append([]string{l.UserGroup, l.Urgency, l.City}, extra...)
+}
+
 // Metrics collects and exposes scheduler metrics
 type Metrics struct {
 	// Prometheus metrics
-	RequestsEnqueued   prometheus.Counter
-	RequestsProcessed  prometheus.Counter
-	QueueLength        prometheus.Gauge
-	ProcessingDuration prometheus.Histogram
-	PriorityScores     prometheus.Histogram
+	RequestsEnqueued   *prometheus.CounterVec
+	RequestsProcessed  *prometheus.CounterVec
+	QueueLength        *prometheus.GaugeVec
+	ProcessingDuration *prometheus.HistogramVec
+	PriorityScores     *prometheus.HistogramVec
+	WaitTime           *prometheus.HistogramVec
+
+	// Extended fairness metrics
+	JainIndex           prometheus.Gauge
+	AtkinsonIndex       *prometheus.GaugeVec
+	AlphaFairWelfare    prometheus.Gauge
+	EnvyPairs           prometheus.Gauge
+	GroupParityGap      *prometheus.GaugeVec
+	GroupOpportunityGap *prometheus.GaugeVec
+	GroupEnvy           *prometheus.GaugeVec
+
+	// Wait estimate accuracy, judged when a ticket is allocated
+	WaitEstimateOutcomes *prometheus.CounterVec
+	WaitEstimateRatio    prometheus.Histogram
+
+	// Open WatchPosition streams
+	PositionWatchers prometheus.Gauge
 
 	// Internal metrics
 	mu sync.RWMutex
 
-	// Wait time tracking
-	waitTimes []time.Duration
+	// Wait time tracking. All-time sketches back the summary statistics;
+	// windowed sketches, per group and for allGroups, back the sliding windows.
+	waitTimes       *sketch.Sketch
+	waitTimeWindows []time.Duration
+	windowSketches  map[string][]*sketch.Window
+
 	maxWaitTime time.Duration
 	minWaitTime time.Duration
 
@@ -34,75 +108,160 @@ type Metrics struct {
 	// Request counts
 	totalRequests   int64
 	totalAllocations int64
+	queueLength     int64
 
 	// Fairness metrics
+	groupRequests    map[string]int64
 	groupAllocations map[string]int64
-	groupWaitTimes   map[string][]time.Duration
+	groupWaitTimes   map[string]*sketch.Sketch
+
+	// Requests at or above the qualifying urgency, for equal opportunity
+	groupQualifiedRequests    map[string]int64
+	groupQualifiedAllocations map[string]int64
+
+	// Relative errors of median wait estimates and how many observed waits
+	// fell inside the estimated interval
+	estimateErrors  *sketch.Sketch
+	estimatesInside int64
 }
 
-// NewMetrics creates a new metrics instance
-func NewMetrics() *Metrics {
+// NewMetrics creates a new metrics instance registered with reg. A nil
+// registerer leaves the metrics unregistered, so several schedulers can
+// coexist in one process (e.g. in tests). Wait-time quantiles are kept over
+// each of the given sliding windows, or DefaultWaitTimeWindows if empty.
+func NewMetrics(reg prometheus.Registerer, windows []time.Duration) *Metrics {
+	if len(windows) == 0 {
+		windows = DefaultWaitTimeWindows
+	}
+	factory := promauto.With(reg)
+
 	m := &Metrics{
-		RequestsEnqueued: promauto.NewCounter(prometheus.CounterOpts{
+		RequestsEnqueued: factory.NewCounterVec(prometheus.CounterOpts{
 			Name: "fairrent_requests_enqueued_total",
 			Help: "Total number of requests enqueued",
-		}),
-		RequestsProcessed: promauto.NewCounter(prometheus.CounterOpts{
+		}, requestLabelNames),
+		RequestsProcessed: factory.NewCounterVec(prometheus.CounterOpts{
 			Name: "fairrent_requests_processed_total",
-			Help: "Total number of requests processed",
-		}),
-		QueueLength: promauto.NewGauge(prometheus.GaugeOpts{
+			Help: "Total number of requests that left the queue, by outcome",
+		}, append(requestLabelNames, LabelOutcome)),
+		QueueLength: factory.NewGaugeVec(prometheus.GaugeOpts{
 			Name: "fairrent_queue_length",
 			Help: "Current number of requests in queue",
-		}),
-		ProcessingDuration: promauto.NewHistogram(prometheus.HistogramOpts{
+		}, requestLabelNames),
+		ProcessingDuration: factory.NewHistogramVec(prometheus.HistogramOpts{
 			Name:    "fairrent_processing_duration_seconds",
 			Help:    "Time taken to process requests",
 			Buckets: prometheus.DefBuckets,
-		}),
-		PriorityScores: promauto.NewHistogram(prometheus.HistogramOpts{
+		}, []string{LabelOutcome}),
+		PriorityScores: factory.NewHistogramVec(prometheus.HistogramOpts{
 			Name:    "fairrent_priority_scores",
 			Help:    "Distribution of priority scores",
 			Buckets: prometheus.LinearBuckets(0, 1, 20),
+		}, requestLabelNames),
+		WaitTime: factory.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "fairrent_wait_time_seconds",
+			Help:    "Time requests spent in the queue before leaving it",
+			Buckets: prometheus.ExponentialBuckets(60, 4, 10), // 1m to ~6 months
+		}, append(requestLabelNames, LabelOutcome)),
+		JainIndex: factory.NewGauge(prometheus.GaugeOpts{
+			Name: "fairrent_fairness_jain_index",
+			Help: "Jain's fairness index over weight-adjusted group selection rates",
+		}),
+		AtkinsonIndex: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "fairrent_fairness_atkinson_index",
+			Help: "Atkinson inequality index over weight-adjusted group selection rates",
+		}, []string{"epsilon"}),
+		AlphaFairWelfare: factory.NewGauge(prometheus.GaugeOpts{
+			Name: "fairrent_fairness_alpha_welfare",
+			Help: "Realised α-fair welfare of group selection rates",
+		}),
+		EnvyPairs: factory.NewGauge(prometheus.GaugeOpts{
+			Name: "fairrent_fairness_envy_pairs",
+			Help: "Number of ordered group pairs in which one group envies the other",
+		}),
+		GroupParityGap: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "fairrent_group_demographic_parity_gap",
+			Help: "Group selection rate minus the overall selection rate",
+		}, []string{"user_group"}),
+		GroupOpportunityGap: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "fairrent_group_equal_opportunity_gap",
+			Help: "Group selection rate among qualified requests minus the overall qualified rate",
+		}, []string{"user_group"}),
+		GroupEnvy: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "fairrent_group_envy_count",
+			Help: "Number of groups a group envies",
+		}, []string{"user_group"}),
+		WaitEstimateOutcomes: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "fairrent_wait_estimates_evaluated_total",
+			Help: "Observed waits compared with the interval estimated at enqueue, by result (below, inside, above)",
+		}, []string{"result"}),
+		WaitEstimateRatio: factory.NewHistogram(prometheus.HistogramOpts{
+			Name:    "fairrent_wait_estimate_ratio",
+			Help:    "Observed wait divided by the median wait estimated at enqueue",
+			Buckets: prometheus.ExponentialBuckets(0.125, 2, 7), // 1/8 to 8
+		}),
+		PositionWatchers: factory.NewGauge(prometheus.GaugeOpts{
+			Name: "fairrent_position_watchers",
+			Help: "Number of open WatchPosition streams",
 		}),
-		waitTimes:        make([]time.Duration, 0),
-		processingTimes:  make([]time.Duration, 0),
-		groupAllocations: make(map[string]int64),
-		groupWaitTimes:   make(map[string][]time.Duration),
+		waitTimes:                 sketch.New(sketch.DefaultRelativeAccuracy),
+		waitTimeWindows:           windows,
+		windowSketches:            make(map[string][]*sketch.Window),
+		processingTimes:           make([]time.Duration, 0),
+		groupRequests:             make(map[string]int64),
+		groupAllocations:          make(map[string]int64),
+		groupWaitTimes:            make(map[string]*sketch.Sketch),
+		groupQualifiedRequests:    make(map[string]int64),
+		groupQualifiedAllocations: make(map[string]int64),
+		estimateErrors:            sketch.New(sketch.DefaultRelativeAccuracy),
 	}
 
 	return m
 }
 
-// RecordRequestEnqueued records a new request being enqueued
-func (m *Metrics) RecordRequestEnqueued(userGroup string) {
-	m.RequestsEnqueued.Inc()
+// RecordRequestEnqueued records a new request being enqueued. Qualified
+// requests are those at or above the qualifying urgency.
+func (m *Metrics) RecordRequestEnqueued(labels RequestLabels, qualified bool) {
+	m.RequestsEnqueued.WithLabelValues(labels.values()...).Inc()
+	m.QueueLength.WithLabelValues(labels.values()...).Inc()
 	
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	
+	userGroup := labels.UserGroup
 	m.totalRequests++
+	m.queueLength++
+	m.groupRequests[userGroup]++
+	if qualified {
+		m.groupQualifiedRequests[userGroup]++
+	}
 	if _, exists := m.groupAllocations[userGroup]; !exists {
 		m.groupAllocations[userGroup] = 0
-		m.groupWaitTimes[userGroup] = make([]time.Duration, 0)
+		m.groupWaitTimes[userGroup] = sketch.New(sketch.DefaultRelativeAccuracy)
 	}
 }
 
-// RecordRequestProcessed records a request being processed
-func (m *Metrics) RecordRequestProcessed(userGroup string, waitTime time.Duration, priorityScore float64) {
-	m.RequestsProcessed.Inc()
+// RecordRequestProcessed records a request leaving the queue with an allocation
+func (m *Metrics) RecordRequestProcessed(labels RequestLabels, qualified bool, waitTime time.Duration, priorityScore float64) {
+	m.RequestsProcessed.WithLabelValues(labels.values(OutcomeAllocated)...).Inc()
+	m.QueueLength.WithLabelValues(labels.values()...).Dec()
+	m.WaitTime.WithLabelValues(labels.values(OutcomeAllocated)...).Observe(waitTime.Seconds())
 	
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	
+	userGroup := labels.UserGroup
 	m.totalAllocations++
+	m.queueLength--
 	m.groupAllocations[userGroup]++
+	if qualified {
+		m.groupQualifiedAllocations[userGroup]++
+	}
 	
 	// Record wait time
-	m.waitTimes = append(m.waitTimes, waitTime)
-	if len(m.waitTimes) > 1000 { // Keep only last 1000 for memory efficiency
-		m.waitTimes = m.waitTimes[1:]
-	}
+	now := time.Now()
+	m.waitTimes.Add(waitTime.Seconds())
+	m.addToWindows(allGroups, now, waitTime)
 	
 	// Update min/max wait times
 	if waitTime > m.maxWaitTime {
@@ -113,29 +272,79 @@ func (m *Metrics) RecordRequestProcessed(userGroup string, waitTime time.Duratio
 	}
 	
 	// Record group-specific wait time
-	if groupTimes, exists := m.groupWaitTimes[userGroup]; exists {
-		m.groupWaitTimes[userGroup] = append(groupTimes, waitTime)
-		if len(m.groupWaitTimes[userGroup]) > 100 { // Keep only last 100 per group
-			m.groupWaitTimes[userGroup] = m.groupWaitTimes[userGroup][1:]
-		}
+	if m.groupWaitTimes[userGroup] == nil {
+		m.groupWaitTimes[userGroup] = sketch.New(sketch.DefaultRelativeAccuracy)
 	}
+	m.groupWaitTimes[userGroup].Add(waitTime.Seconds())
+	m.addToWindows(userGroup, now, waitTime)
 	
 	// Record priority score
-	m.PriorityScores.Observe(priorityScore)
+	m.PriorityScores.WithLabelValues(labels.values()...).Observe(priorityScore)
 	
 	// Record processing duration
-	now := time.Now()
 	if !m.lastProcessTime.IsZero() {
 		processingTime := now.Sub(m.lastProcessTime)
 		m.processingTimes = append(m.processingTimes, processingTime)
 		if len(m.processingTimes) > 1000 {
 			m.processingTimes = m.processingTimes[1:]
 		}
-		m.ProcessingDuration.Observe(processingTime.Seconds())
+		m.ProcessingDuration.WithLabelValues(OutcomeAllocated).Observe(processingTime.Seconds())
 	}
 	m.lastProcessTime = now
 }
 
+// RecordRequestCancelled records a request leaving the queue without an
+// allocation. It still counts as demand for its group.
+func (m *Metrics) RecordRequestCancelled(labels RequestLabels, waitTime time.Duration) {
+	m.RequestsProcessed.WithLabelValues(labels.values(OutcomeCancelled)...).Inc()
+	m.QueueLength.WithLabelValues(labels.values()...).Dec()
+	m.WaitTime.WithLabelValues(labels.values(OutcomeCancelled)...).Observe(waitTime.Seconds())
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.queueLength--
+}
+
+// RecordRequestUpdated moves a queued request between label sets, e.g. after
+// its urgency changed
+func (m *Metrics) RecordRequestUpdated(old, updated RequestLabels) {
+	if old == updated {
+		return
+	}
+	m.QueueLength.WithLabelValues(old.values()...).Dec()
+	m.QueueLength.WithLabelValues(updated.values()...).Inc()
+}
+
+// RecordWaitEstimateOutcome compares an allocated ticket's observed wait with
+// the bounded estimate it was given at enqueue. Estimates without a positive
+// median are not counted, so outcomes and ratios cover the same estimates.
+func (m *Metrics) RecordWaitEstimateOutcome(estimate WaitEstimate, observed time.Duration) {
+	if estimate.Median <= 0 {
+		return
+	}
+
+	result := "inside"
+	switch {
+	case observed < estimate.Lower:
+		result = "below"
+	case observed > estimate.Upper:
+		result = "above"
+	}
+	m.WaitEstimateOutcomes.WithLabelValues(result).Inc()
+	
+	ratio := observed.Seconds() / estimate.Median.Seconds()
+	m.WaitEstimateRatio.Observe(ratio)
+	
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	m.estimateErrors.Add(math.Abs(ratio - 1))
+	if result == "inside" {
+		m.estimatesInside++
+	}
+}
+
 // GetMetrics returns computed metrics
 func (m *Metrics) GetMetrics() *SchedulerMetrics {
 	m.mu.RLock()
@@ -144,21 +353,31 @@ func (m *Metrics) GetMetrics() *SchedulerMetrics {
 	metrics := &SchedulerMetrics{
 		TotalRequests:   m.totalRequests,
 		TotalAllocations: m.totalAllocations,
-		QueueLength:     int64(m.QueueLength.(prometheus.Gauge).(prometheus.Gauge)),
+		QueueLength:     m.queueLength,
 	}
 	
 	// Calculate wait time statistics
-	if len(m.waitTimes) > 0 {
-		metrics.AverageWaitTime = m.calculateAverageWaitTime()
-		metrics.MedianWaitTime = m.calculateMedianWaitTime()
-		metrics.P95WaitTime = m.calculatePercentileWaitTime(95)
-		metrics.P99WaitTime = m.calculatePercentileWaitTime(99)
+	if m.waitTimes.Count() > 0 {
+		metrics.AverageWaitTime = seconds(m.waitTimes.Mean())
+		metrics.MedianWaitTime = seconds(m.waitTimes.Quantile(0.5))
+		metrics.P95WaitTime = seconds(m.waitTimes.Quantile(0.95))
+		metrics.P99WaitTime = seconds(m.waitTimes.Quantile(0.99))
 		metrics.MaxWaitTime = m.maxWaitTime
 		metrics.MinWaitTime = m.minWaitTime
 		
 		// Calculate fairness metrics
 		metrics.MaxWaitTimeRatio = float64(m.maxWaitTime) / float64(m.minWaitTime)
-		metrics.GiniCoefficient = m.calculateGiniCoefficient()
+		metrics.GiniCoefficient = sketchGiniCoefficient(m.waitTimes)
+	}
+	metrics.WaitTimeWindows = m.waitTimeWindowStats(time.Now())
+	
+	// Accuracy of wait estimates
+	if evaluated := m.estimateErrors.Count(); evaluated > 0 {
+		metrics.WaitEstimateAccuracy = WaitEstimateAccuracy{
+			Evaluated:           int64(evaluated),
+			IntervalCoverage:    float64(m.estimatesInside) / float64(evaluated),
+			MedianRelativeError: m.estimateErrors.Quantile(0.5),
+		}
 	}
 	
 	// Calculate processing statistics
@@ -171,58 +390,6 @@ func (m *Metrics) GetMetrics() *SchedulerMetrics {
 	return metrics
 }
 
-// calculateAverageWaitTime computes the average wait time
-func (m *Metrics) calculateAverageWaitTime() time.Duration {
-	if len(m.waitTimes) == 0 {
-		return 0
-	}
-	
-	total := time.Duration(0)
-	for _, waitTime := range m.waitTimes {
-		total += waitTime
-	}
-	return total / time.Duration(len(m.waitTimes))
-}
-
-// calculateMedianWaitTime computes the median wait time
-func (m *Metrics) calculateMedianWaitTime() time.Duration {
-	if len(m.waitTimes) == 0 {
-		return 0
-	}
-	
-	// Create a copy to avoid modifying the original slice
-	times := make([]time.Duration, len(m.waitTimes))
-	copy(times, m.waitTimes)
-	
-	sort.Slice(times, func(i, j int) bool {
-		return times[i] < times[j]
-	})
-	
-	mid := len(times) / 2
-	if len(times)%2 == 0 {
-		return (times[mid-1] + times[mid]) / 2
-	}
-	return times[mid]
-}
-
-// calculatePercentileWaitTime computes the nth percentile wait time
-func (m *Metrics) calculatePercentileWaitTime(percentile int) time.Duration {
-	if len(m.waitTimes) == 0 {
-		return 0
-	}
-	
-	// Create a copy to avoid modifying the original slice
-	times := make([]time.Duration, len(m.waitTimes))
-	copy(times, m.waitTimes)
-	
-	sort.Slice(times, func(i, j int) bool {
-		return times[i] < times[j]
-	})
-	
-	index := int(float64(percentile) / 100.0 * float64(len(times)-1))
-	return times[index]
-}
-
 // calculateAverageProcessingTime computes the average processing time
 func (m *Metrics) calculateAverageProcessingTime() time.Duration {
 	if len(m.processingTimes) == 0 {
@@ -281,23 +448,89 @@ func (m *Metrics) calculateQueueTurnoverRate() float64 {
 	return float64(recentCount) / totalTime.Hours()
 }
 
-// calculateGiniCoefficient computes wait time inequality
-func (m *Metrics) calculateGiniCoefficient() float64 {
-	if len(m.waitTimes) < 2 {
+// sketchGiniCoefficient computes the Gini coefficient of the values in a
+// sketch, treating each bucket as count copies of its representative value
+func sketchGiniCoefficient(s *sketch.Sketch) float64 {
+	if s.Count() < 2 || s.Sum() == 0 {
 		return 0
 	}
-	
-	// Create a copy and sort
-	times := make([]time.Duration, len(m.waitTimes))
-	copy(times, m.waitTimes)
-	sort.Slice(times, func(i, j int) bool {
-		return times[i] < times[j]
+
+	// Σ_i w_i x_i (2W_{<i} + w_i - N) / (N Σ_i w_i x_i) over ascending buckets
+	n := float64(s.Count())
+	below, weighted, total := 0.0, 0.0, 0.0
+	s.ForEach(func(value float64, count uint64) {
+		w := float64(count)
+		weighted += w * value * (2*below + w - n)
+		total += w * value
+		below += w
 	})
-	
-	// Convert to float64 for calculations
-	values := make([]float64, len(times))
-	for i, t := range times {
-		values[i] = float64(t.Milliseconds())
+
+	if total == 0 {
+		return 0
+	}
+	return weighted / (n * total)
+}
+
+// addToWindows records a wait time in the sliding windows of a group
+func (m *Metrics) addToWindows(group string, now time.Time, waitTime time.Duration) {
+	windows, exists := m.windowSketches[group]
+	if !exists {
+		windows = make([]*sketch.Window, len(m.waitTimeWindows))
+		for i, span := range m.waitTimeWindows {
+			windows[i] = sketch.NewWindow(span, sketch.DefaultWindowSlices, sketch.DefaultRelativeAccuracy)
+		}
+		m.windowSketches[group] = windows
+	}
+
+	for _, window := range windows {
+		window.Add(now, waitTime.Seconds())
+	}
+}
+
+// waitTimeWindowStats returns wait-time quantiles for every window, first
+// across all groups and then per group in name order
+func (m *Metrics) waitTimeWindowStats(now time.Time) []WaitTimeWindowStats {
+	groups := make([]string, 0, len(m.windowSketches))
+	for group := range m.windowSketches {
+		if group != allGroups {
+			groups = append(groups, group)
+		}
+	}
+	sort.Strings(groups)
+	groups = append([]string{allGroups}, groups...)
+
+	stats := make([]WaitTimeWindowStats, 0, len(groups)*len(m.waitTimeWindows))
+	for _, group := range groups {
+		windows, exists := m.windowSketches[group]
+		if !exists {
+			// Nothing has been allocated yet
+			continue
+		}
+		for _, window := range windows {
+			snapshot := window.Snapshot(now)
+			stats = append(stats, WaitTimeWindowStats{
+				Window:    window.Span(),
+				UserGroup: group,
+				Count:     int64(snapshot.Count()),
+				Mean:      seconds(snapshot.Mean()),
+				P50:       seconds(snapshot.Quantile(0.5)),
+				P95:       seconds(snapshot.Quantile(0.95)),
+				P99:       seconds(snapshot.Quantile(0.99)),
+			})
+		}
+	}
+	return stats
+}
+
+// seconds converts fractional seconds to a duration
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// giniCoefficient computes the Gini coefficient of values sorted in ascending order
+func giniCoefficient(values []float64) float64 {
+	if len(values) < 2 {
+		return 0
 	}
 	
 	// Calculate Gini coefficient
@@ -319,6 +552,47 @@ func (m *Metrics) calculateGiniCoefficient() float64 {
 	return sum / (n * totalSum)
 }
 
+// GetGroupStats returns per-group request and allocation counts
+func (m *Metrics) GetGroupStats() map[string]*GroupStats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	
+	stats := make(map[string]*GroupStats, len(m.groupRequests))
+	for group, requests := range m.groupRequests {
+		groupStats := &GroupStats{
+			Group:                group,
+			Count:                int(requests),
+			Allocations:          int(m.groupAllocations[group]),
+			QualifiedCount:       int(m.groupQualifiedRequests[group]),
+			QualifiedAllocations: int(m.groupQualifiedAllocations[group]),
+		}
+		if waitTimes := m.groupWaitTimes[group]; waitTimes != nil {
+			groupStats.TotalWaitTime = seconds(waitTimes.Sum())
+			groupStats.WaitSamples = int(waitTimes.Count())
+		}
+		stats[group] = groupStats
+	}
+	
+	return stats
+}
+
+// RecordExtendedFairness publishes the extended fairness suite as gauges
+func (m *Metrics) RecordExtendedFairness(ext *fairrentv1.ExtendedFairnessMetrics) {
+	m.JainIndex.Set(ext.JainIndex)
+	for epsilon, value := range ext.AtkinsonIndex {
+		m.AtkinsonIndex.WithLabelValues(epsilon).Set(value)
+	}
+	m.AlphaFairWelfare.Set(ext.AlphaFairWelfare)
+	m.EnvyPairs.Set(float64(ext.EnvyPairs))
+	
+	for _, gm := range ext.GroupMetrics {
+		group := gm.UserGroup.String()
+		m.GroupParityGap.WithLabelValues(group).Set(gm.DemographicParityGap)
+		m.GroupOpportunityGap.WithLabelValues(group).Set(gm.EqualOpportunityGap)
+		m.GroupEnvy.WithLabelValues(group).Set(float64(gm.Envies))
+	}
+}
+
 // GetAverageProcessingTime returns the average processing time
 func (m *Metrics) GetAverageProcessingTime() time.Duration {
 	m.mu.RLock()
@@ -342,6 +616,28 @@ type SchedulerMetrics struct {
 	AverageProcessingTime time.Duration
 	AllocationRate       float64
 	QueueTurnoverRate    float64
+	WaitTimeWindows      []WaitTimeWindowStats
+	WaitEstimateAccuracy WaitEstimateAccuracy
+}
+
+// WaitEstimateAccuracy summarises how well wait estimates issued at enqueue
+// matched the observed waits
+type WaitEstimateAccuracy struct {
+	Evaluated           int64
+	IntervalCoverage    float64 // share of observed waits inside the estimated interval
+	MedianRelativeError float64 // median of |observed - median estimate| / median estimate
+}
+
+// WaitTimeWindowStats holds wait-time quantiles over one sliding window,
+// for one group or, with an empty UserGroup, across all groups
+type WaitTimeWindowStats struct {
+	Window    time.Duration
+	UserGroup string
+	Count     int64
+	Mean      time.Duration
+	P50       time.Duration
+	P95       time.Duration
+	P99       time.Duration
 }
 
 // min returns the minimum of two integers
@@ -0,0 +1,105 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/common/v1"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/fairrent/v1"
+	"go.uber.org/zap"
+)
+
+func TestMetrics_LabelledVectorsPerRegistry(t *testing.T) {
+	logger := zap.NewNop()
+	ctx := context.Background()
+
+	// Two schedulers in one process, each with its own registry
+	reg := prometheus.NewRegistry()
+	config := DefaultConfig()
+	config.Registerer = reg
+	fr := NewFairRent(config, logger)
+
+	otherConfig := DefaultConfig()
+	otherConfig.Registerer = prometheus.NewRegistry()
+	require.NotPanics(t, func() { NewFairRent(otherConfig, logger) })
+
+	_, err := fr.Enqueue(ctx, &fairrentv1.EnqueueRequest{
+		UserId:          &commonv1.UserID{Value: "user1"},
+		UserGroup:       commonv1.UserGroup_USER_GROUP_REFUGEE,
+		Urgency:         commonv1.UrgencyLevel_URGENCY_LEVEL_HIGH,
+		PreferredCities: []string{"Berlin", "Potsdam"},
+	})
+	require.NoError(t, err)
+	_, err = fr.Enqueue(ctx, &fairrentv1.EnqueueRequest{
+		UserId:    &commonv1.UserID{Value: "user2"},
+		UserGroup: commonv1.UserGroup_USER_GROUP_STUDENT,
+		Urgency:   commonv1.UrgencyLevel_URGENCY_LEVEL_LOW,
+	})
+	require.NoError(t, err)
+	_, err = fr.Enqueue(ctx, &fairrentv1.EnqueueRequest{
+		UserId:          &commonv1.UserID{Value: "user3"},
+		UserGroup:       commonv1.UserGroup_USER_GROUP_STUDENT,
+		Urgency:         commonv1.UrgencyLevel_URGENCY_LEVEL_LOW,
+		PreferredCities: []string{"Kleinkleckersdorf"},
+	})
+	require.NoError(t, err)
+
+	refugee := RequestLabels{UserGroup: "USER_GROUP_REFUGEE", Urgency: "URGENCY_LEVEL_HIGH", City: "Berlin"}
+	student := RequestLabels{UserGroup: "USER_GROUP_STUDENT", Urgency: "URGENCY_LEVEL_LOW", City: UnknownCity}
+	elsewhere := RequestLabels{UserGroup: "USER_GROUP_STUDENT", Urgency: "URGENCY_LEVEL_LOW", City: OtherCity}
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(fr.metrics.RequestsEnqueued.WithLabelValues(refugee.values()...)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(fr.metrics.RequestsEnqueued.WithLabelValues(student.values()...)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(fr.metrics.RequestsEnqueued.WithLabelValues(elsewhere.values()...)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(fr.metrics.QueueLength.WithLabelValues(refugee.values()...)))
+
+	_, err = fr.ScheduleNext(ctx, &fairrentv1.ScheduleNextRequest{})
+	require.NoError(t, err)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(fr.metrics.RequestsProcessed.WithLabelValues(refugee.values(OutcomeAllocated)...)))
+	assert.Equal(t, 0.0, testutil.ToFloat64(fr.metrics.QueueLength.WithLabelValues(refugee.values()...)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(fr.metrics.QueueLength.WithLabelValues(student.values()...)))
+
+	count, err := testutil.GatherAndCount(reg, "fairrent_requests_enqueued_total")
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	// Unregistered metrics still work
+	unregistered := NewMetrics(nil, nil)
+	unregistered.RecordRequestEnqueued(student, false)
+	assert.Equal(t, int64(1), unregistered.GetMetrics().QueueLength)
+}
+
+func TestMetrics_WaitTimeWindows(t *testing.T) {
+	m := NewMetrics(nil, []time.Duration{time.Hour, 24 * time.Hour})
+	student := RequestLabels{UserGroup: "USER_GROUP_STUDENT"}
+	refugee := RequestLabels{UserGroup: "USER_GROUP_REFUGEE"}
+
+	for i := 1; i <= 100; i++ {
+		m.RecordRequestEnqueued(student, false)
+		m.RecordRequestProcessed(student, false, time.Duration(i)*time.Minute, 1.0)
+	}
+	m.RecordRequestEnqueued(refugee, false)
+	m.RecordRequestProcessed(refugee, false, 10*time.Minute, 1.0)
+
+	metrics := m.GetMetrics()
+	assert.InEpsilon(t, float64(50*time.Minute), float64(metrics.MedianWaitTime), 0.02)
+	assert.InEpsilon(t, float64(99*time.Minute), float64(metrics.P99WaitTime), 0.02)
+
+	// Global windows first, then groups in name order
+	require.Len(t, metrics.WaitTimeWindows, 6)
+	assert.Equal(t, "", metrics.WaitTimeWindows[0].UserGroup)
+	assert.Equal(t, time.Hour, metrics.WaitTimeWindows[0].Window)
+	assert.Equal(t, int64(101), metrics.WaitTimeWindows[0].Count)
+	assert.Equal(t, "USER_GROUP_REFUGEE", metrics.WaitTimeWindows[2].UserGroup)
+	assert.Equal(t, int64(1), metrics.WaitTimeWindows[2].Count)
+	assert.InEpsilon(t, float64(10*time.Minute), float64(metrics.WaitTimeWindows[2].P50), 0.02)
+	assert.Equal(t, "USER_GROUP_STUDENT", metrics.WaitTimeWindows[5].UserGroup)
+	assert.Equal(t, 24*time.Hour, metrics.WaitTimeWindows[5].Window)
+	assert.InEpsilon(t, float64(95*time.Minute), float64(metrics.WaitTimeWindows[5].P95), 0.02)
+}
@@ -0,0 +1,81 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/audit"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/common/v1"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/fairrent/v1"
+	"go.uber.org/zap"
+)
+
+func TestFairRent_Modes(t *testing.T) {
+	config := DefaultConfig()
+	config.AuditLog = audit.NewLog()
+	fr := NewFairRent(config, zap.NewNop())
+	ctx := context.Background()
+
+	enqueue := func(user string) error {
+		_, err := fr.Enqueue(ctx, &fairrentv1.EnqueueRequest{
+			UserId:    &commonv1.UserID{Value: user},
+			UserGroup: commonv1.UserGroup_USER_GROUP_STUDENT,
+			Urgency:   commonv1.UrgencyLevel_URGENCY_LEVEL_MEDIUM,
+		})
+		return err
+	}
+	schedule := func() error {
+		_, err := fr.ScheduleNext(ctx, &fairrentv1.ScheduleNextRequest{})
+		return err
+	}
+
+	mode, _ := fr.Mode()
+	assert.Equal(t, ModeRunning, mode)
+
+	// Paused: requests are accepted but not allocated
+	change, err := fr.SetMode(ctx, "admin1", ModePaused, "policy change")
+	require.NoError(t, err)
+	assert.Equal(t, ModeRunning, change.Previous)
+	require.NoError(t, enqueue("user1"))
+	require.NoError(t, enqueue("user2"))
+	assert.ErrorIs(t, schedule(), ErrSchedulingPaused)
+
+	// Draining: queued tickets are allocated, new requests are refused
+	_, err = fr.SetMode(ctx, "admin1", ModeDraining, "")
+	require.NoError(t, err)
+	assert.ErrorIs(t, enqueue("user3"), ErrDraining)
+	_, err = fr.Import(ctx, "admin1", nil, false)
+	assert.ErrorIs(t, err, ErrDraining)
+	require.NoError(t, schedule())
+
+	status, err := fr.GetQueueStatus(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, fairrentv1.SchedulerMode_SCHEDULER_MODE_DRAINING, status.Mode)
+	assert.Equal(t, int32(1), status.PendingRequests)
+	assert.Equal(t, int32(1), status.CompletedRequests)
+	assert.Equal(t, int32(1), status.GroupCounts["USER_GROUP_STUDENT"])
+
+	change, err = fr.SetMode(ctx, "admin2", ModeRunning, "incident resolved")
+	require.NoError(t, err)
+	assert.Equal(t, ModeDraining, change.Previous)
+	assert.Equal(t, 1, change.QueueLength)
+	require.NoError(t, enqueue("user3"))
+
+	// Setting the current mode again is not a change
+	_, err = fr.SetMode(ctx, "admin2", ModeRunning, "")
+	require.NoError(t, err)
+
+	records := config.AuditLog.Records(0, 0)
+	var changes []audit.Record
+	for _, record := range records {
+		if record.Type == ModeChangeRecordType {
+			changes = append(changes, record)
+		}
+	}
+	require.Len(t, changes, 3)
+	assert.Equal(t, "admin1", changes[0].Actor)
+	assert.Equal(t, "admin2", changes[2].Actor)
+	assert.JSONEq(t, `{"from":"draining","to":"running","reason":"incident resolved","queue_length":1}`, string(changes[2].Payload))
+}
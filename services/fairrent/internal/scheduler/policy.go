@@ -0,0 +1,462 @@
+package scheduler
+
+import (
+	"container/heap"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/common/v1"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/fairrent/v1"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Audit record types of the fairness policy
+const (
+	// PolicyScheduledRecordType records a new policy version and when it
+	// takes effect
+	PolicyScheduledRecordType = "policy_scheduled"
+
+	// PolicyChangeRecordType records a policy version taking effect
+	PolicyChangeRecordType = "policy_change"
+)
+
+// configPolicyActor creates the first policy version from the configuration
+const configPolicyActor = "config"
+
+// policyClockSkew is how far in the past an effective time may lie; such
+// versions take effect at once
+const policyClockSkew = time.Minute
+
+// ErrPolicyNotFound is returned for policy versions that do not exist, and
+// for times before the first version took effect
+var ErrPolicyNotFound = errors.New("policy not found")
+
+// PolicyStatus is the state of a policy version
+type PolicyStatus string
+
+// Policy version states
+const (
+	PolicyScheduled  PolicyStatus = "scheduled"  // takes effect in the future
+	PolicyActive     PolicyStatus = "active"     // in effect now
+	PolicySuperseded PolicyStatus = "superseded" // replaced by a later version
+)
+
+// protoPolicyStatuses maps policy states to their protobuf enum values
+var protoPolicyStatuses = map[PolicyStatus]fairrentv1.PolicyStatus{
+	PolicyScheduled:  fairrentv1.PolicyStatus_POLICY_STATUS_SCHEDULED,
+	PolicyActive:     fairrentv1.PolicyStatus_POLICY_STATUS_ACTIVE,
+	PolicySuperseded: fairrentv1.PolicyStatus_POLICY_STATUS_SUPERSEDED,
+}
+
+// Proto returns the protobuf enum value of the status
+func (s PolicyStatus) Proto() fairrentv1.PolicyStatus {
+	return protoPolicyStatuses[s]
+}
+
+// PolicyVersion is a version of α and the group weights. Versions are
+// numbered in creation order and apply from EffectiveFrom until a version
+// with a later EffectiveFrom takes over.
+type PolicyVersion struct {
+	Version       int
+	Alpha         float64
+	GroupWeights  map[string]float64 // omitted groups weigh 1.0
+	EffectiveFrom time.Time
+	CreatedAt     time.Time
+	CreatedBy     string
+	Reason        string
+	ActivatedAt   time.Time    // zero until it took effect
+	Status        PolicyStatus // when the version was read
+}
+
+// Proto converts the version to protobuf format
+func (p PolicyVersion) Proto() *fairrentv1.PolicyVersion {
+	version := &fairrentv1.PolicyVersion{
+		Version:       int32(p.Version),
+		Alpha:         p.Alpha,
+		GroupWeights:  p.GroupWeights,
+		EffectiveFrom: timestamppb.New(p.EffectiveFrom),
+		CreatedAt:     timestamppb.New(p.CreatedAt),
+		CreatedBy:     p.CreatedBy,
+		Reason:        p.Reason,
+		Status:        p.Status.Proto(),
+	}
+	if !p.ActivatedAt.IsZero() {
+		version.ActivatedAt = timestamppb.New(p.ActivatedAt)
+	}
+	return version
+}
+
+// policyRecord is the audit payload of a scheduled version, from which
+// versions are restored on startup
+type policyRecord struct {
+	Version       int                `json:"version"`
+	Alpha         float64            `json:"alpha"`
+	GroupWeights  map[string]float64 `json:"group_weights"`
+	EffectiveFrom time.Time          `json:"effective_from"`
+	Reason        string             `json:"reason,omitempty"`
+}
+
+// ValidatePolicy checks that α is positive and finite and that every group
+// weight is positive, finite and names a known user group
+func ValidatePolicy(alpha float64, weights map[string]float64) error {
+	if !validPolicyValue(alpha) {
+		return fmt.Errorf("%w: alpha must be positive", ErrInvalidPolicy)
+	}
+
+	groups := make([]string, 0, len(weights))
+	for group := range weights {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	for _, group := range groups {
+		if value, ok := commonv1.UserGroup_value[group]; !ok || value == int32(commonv1.UserGroup_USER_GROUP_UNSPECIFIED) {
+			return fmt.Errorf("%w: unknown user group %q in group weights", ErrInvalidPolicy, group)
+		}
+		if !validPolicyValue(weights[group]) {
+			return fmt.Errorf("%w: group weight for %s must be positive", ErrInvalidPolicy, group)
+		}
+	}
+	return nil
+}
+
+// validPolicyValue reports whether v is positive and finite, rejecting NaN
+func validPolicyValue(v float64) bool {
+	return v > 0 && !math.IsInf(v, 1)
+}
+
+// SchedulePolicy creates a policy version taking effect at effectiveFrom,
+// or at once if it is zero. Versions are audited when they are created and
+// again when they take effect, at which point queued tickets are rescored.
+func (fr *FairRent) SchedulePolicy(ctx context.Context, actor string, alpha float64, weights map[string]float64, effectiveFrom time.Time, reason string) (PolicyVersion, error) {
+	if err := ValidatePolicy(alpha, weights); err != nil {
+		return PolicyVersion{}, err
+	}
+
+	now := time.Now()
+	switch {
+	case effectiveFrom.IsZero():
+		effectiveFrom = now
+	case effectiveFrom.Before(now.Add(-policyClockSkew)):
+		return PolicyVersion{}, fmt.Errorf("%w: effective_from lies in the past", ErrInvalidPolicy)
+	case effectiveFrom.Before(now):
+		effectiveFrom = now
+	}
+
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	p, err := fr.addPolicy(actor, alpha, weights, effectiveFrom, reason, now)
+	if err != nil {
+		return PolicyVersion{}, err
+	}
+	return fr.snapshotPolicy(p, now), nil
+}
+
+// SetPolicy puts α and the group weights into force at once as a new policy
+// version, unless they already are. It reports whether a version was
+// created.
+func (fr *FairRent) SetPolicy(actor string, alpha float64, weights map[string]float64, reason string) (bool, error) {
+	if err := ValidatePolicy(alpha, weights); err != nil {
+		return false, err
+	}
+
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	if alpha == fr.alpha && equalWeights(weights, fr.groupWeights) {
+		return false, nil
+	}
+	now := time.Now()
+	if _, err := fr.addPolicy(actor, alpha, weights, now, reason, now); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// PolicyAt returns the policy version in effect at t. Scheduled versions
+// are returned for future times.
+func (fr *FairRent) PolicyAt(t time.Time) (PolicyVersion, error) {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	p := fr.policyAt(t)
+	if p == nil {
+		return PolicyVersion{}, fmt.Errorf("%w: none in effect at %s", ErrPolicyNotFound, t.UTC().Format(time.RFC3339))
+	}
+	return fr.snapshotPolicy(p, time.Now()), nil
+}
+
+// Policy returns a policy version by number
+func (fr *FairRent) Policy(version int) (PolicyVersion, error) {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	for _, p := range fr.policies {
+		if p.Version == version {
+			return fr.snapshotPolicy(p, time.Now()), nil
+		}
+	}
+	return PolicyVersion{}, fmt.Errorf("%w: version %d", ErrPolicyNotFound, version)
+}
+
+// Policies returns every policy version in creation order and the number
+// of the version in effect
+func (fr *FairRent) Policies() ([]PolicyVersion, int) {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	now := time.Now()
+	versions := make([]PolicyVersion, 0, len(fr.policies))
+	for _, p := range fr.policies {
+		versions = append(versions, fr.snapshotPolicy(p, now))
+	}
+	return versions, fr.policyVersion
+}
+
+// addPolicy audits and stores a new version, putting it into force if it
+// is due. Must be called with the write lock held.
+func (fr *FairRent) addPolicy(actor string, alpha float64, weights map[string]float64, effectiveFrom time.Time, reason string, now time.Time) (*PolicyVersion, error) {
+	p := &PolicyVersion{
+		Version:       1,
+		Alpha:         alpha,
+		GroupWeights:  copyWeights(weights),
+		EffectiveFrom: effectiveFrom,
+		CreatedAt:     now,
+		CreatedBy:     actor,
+		Reason:        reason,
+	}
+	if n := len(fr.policies); n > 0 {
+		p.Version = fr.policies[n-1].Version + 1
+	}
+
+	if _, err := fr.audit.Append(PolicyScheduledRecordType, actor, p.record()); err != nil {
+		// A policy that cannot be accounted for is not made
+		fr.logger.Error("Failed to audit policy version", zap.Error(err))
+		return nil, err
+	}
+	fr.policies = append(fr.policies, p)
+
+	fr.logger.Info("Policy version created",
+		zap.String("actor", actor),
+		zap.Int("version", p.Version),
+		zap.Float64("alpha", alpha),
+		zap.Time("effective_from", effectiveFrom),
+		zap.String("reason", reason),
+	)
+
+	fr.activateDuePolicies(now)
+	fr.armPolicyTimer(now)
+	return p, nil
+}
+
+// record returns the audit payload of a scheduled version
+func (p *PolicyVersion) record() policyRecord {
+	return policyRecord{
+		Version:       p.Version,
+		Alpha:         p.Alpha,
+		GroupWeights:  p.GroupWeights,
+		EffectiveFrom: p.EffectiveFrom,
+		Reason:        p.Reason,
+	}
+}
+
+// policyAt returns the version in effect at t: of those effective by then,
+// the one with the latest effective time, and of those the latest created
+func (fr *FairRent) policyAt(t time.Time) *PolicyVersion {
+	var found *PolicyVersion
+	for _, p := range fr.policies {
+		if p.EffectiveFrom.After(t) {
+			continue
+		}
+		if found == nil || !p.EffectiveFrom.Before(found.EffectiveFrom) {
+			found = p
+		}
+	}
+	return found
+}
+
+// snapshotPolicy copies a version with its status at now
+func (fr *FairRent) snapshotPolicy(p *PolicyVersion, now time.Time) PolicyVersion {
+	snapshot := *p
+	snapshot.GroupWeights = copyWeights(p.GroupWeights)
+	switch {
+	case p.Version == fr.policyVersion:
+		snapshot.Status = PolicyActive
+	case p.EffectiveFrom.After(now):
+		snapshot.Status = PolicyScheduled
+	default:
+		snapshot.Status = PolicySuperseded
+	}
+	return snapshot
+}
+
+// activateDuePolicies puts the version in effect at now into force and
+// rescores the queued tickets under it, keeping their enqueue times, since
+// scores under different policies cannot be compared. Must be called with
+// the write lock held.
+func (fr *FairRent) activateDuePolicies(now time.Time) {
+	due := fr.policyAt(now)
+	if due == nil || due.Version == fr.policyVersion {
+		return
+	}
+
+	previous := fr.policyVersion
+	payload := map[string]interface{}{
+		"version":                due.Version,
+		"previous_version":       previous,
+		"effective_from":         due.EffectiveFrom,
+		"previous_alpha":         fr.alpha,
+		"previous_group_weights": fr.groupWeights,
+		"alpha":                  due.Alpha,
+		"group_weights":          due.GroupWeights,
+	}
+	if _, err := fr.audit.Append(PolicyChangeRecordType, due.CreatedBy, payload); err != nil {
+		// The version was audited when it was created, so it takes effect
+		// as scheduled
+		fr.logger.Error("Failed to audit policy change", zap.Error(err))
+	}
+	due.ActivatedAt = now
+	fr.setActivePolicy(due)
+
+	for _, ticket := range fr.queue.tickets {
+		ticket.Factors.Alpha = fr.alpha
+		ticket.Factors.GroupWeight = fr.groupWeight(ticket.UserGroup)
+		ticket.Factors.PolicyVersion = fr.policyVersion
+		ticket.PriorityScore = ticket.Factors.Score()
+	}
+	heap.Init(fr.queue)
+
+	fr.metrics.RecordExtendedFairness(fr.calculateExtendedMetrics())
+	fr.notifyWatchers(now)
+
+	fr.logger.Info("Policy version in effect",
+		zap.Int("version", due.Version),
+		zap.Int("previous_version", previous),
+		zap.Float64("alpha", due.Alpha),
+		zap.Int("group_weights", len(due.GroupWeights)),
+		zap.Int("rescored_tickets", fr.queue.Len()),
+	)
+}
+
+// setActivePolicy makes a version the source of new scores
+func (fr *FairRent) setActivePolicy(p *PolicyVersion) {
+	fr.alpha = p.Alpha
+	fr.groupWeights = copyWeights(p.GroupWeights)
+	fr.policyVersion = p.Version
+}
+
+// armPolicyTimer schedules activation of the next version due after now.
+// Must be called with the write lock held.
+func (fr *FairRent) armPolicyTimer(now time.Time) {
+	if fr.policyTimer != nil {
+		fr.policyTimer.Stop()
+		fr.policyTimer = nil
+	}
+
+	var next time.Time
+	for _, p := range fr.policies {
+		if p.EffectiveFrom.After(now) && (next.IsZero() || p.EffectiveFrom.Before(next)) {
+			next = p.EffectiveFrom
+		}
+	}
+	if next.IsZero() {
+		return
+	}
+	fr.policyTimer = time.AfterFunc(next.Sub(now), func() {
+		fr.mu.Lock()
+		defer fr.mu.Unlock()
+
+		now := time.Now()
+		fr.activateDuePolicies(now)
+		fr.armPolicyTimer(now)
+	})
+}
+
+// restorePolicies rebuilds the policy versions recorded in the audit log,
+// so that version numbers stay unique across restarts, and puts the one due
+// into force. Without recorded versions the configured policy becomes
+// version 1; otherwise the recorded versions take precedence over it.
+func (fr *FairRent) restorePolicies(now time.Time) {
+	activated := make(map[int]time.Time)
+	last := 0
+	for _, record := range fr.audit.Records(0, 0) {
+		switch record.Type {
+		case PolicyScheduledRecordType:
+			var r policyRecord
+			if err := json.Unmarshal(record.Payload, &r); err != nil || r.Version <= last {
+				fr.logger.Warn("Skipping unreadable policy record", zap.Uint64("sequence", record.Sequence), zap.Error(err))
+				continue
+			}
+			fr.policies = append(fr.policies, &PolicyVersion{
+				Version:       r.Version,
+				Alpha:         r.Alpha,
+				GroupWeights:  r.GroupWeights,
+				EffectiveFrom: r.EffectiveFrom,
+				CreatedAt:     record.Timestamp,
+				CreatedBy:     record.Actor,
+				Reason:        r.Reason,
+			})
+			last = r.Version
+		case PolicyChangeRecordType:
+			var r struct {
+				Version int `json:"version"`
+			}
+			if json.Unmarshal(record.Payload, &r) == nil && r.Version > 0 {
+				activated[r.Version] = record.Timestamp
+			}
+		}
+	}
+
+	if len(fr.policies) == 0 {
+		if _, err := fr.addPolicy(configPolicyActor, fr.config.Alpha, fr.config.GroupWeights, now, "initial configuration", now); err != nil {
+			fr.logger.Error("Failed to record the initial policy", zap.Error(err))
+		}
+		return
+	}
+
+	for _, p := range fr.policies {
+		p.ActivatedAt = activated[p.Version]
+	}
+	// The version in effect before the restart stays in force unannounced
+	if current := fr.policyAt(now); current != nil && !current.ActivatedAt.IsZero() {
+		fr.setActivePolicy(current)
+	}
+	fr.activateDuePolicies(now)
+	fr.armPolicyTimer(now)
+
+	if fr.config.Alpha != fr.alpha || !equalWeights(fr.config.GroupWeights, fr.groupWeights) {
+		fr.logger.Warn("Configured fairness policy differs from the recorded one, which stays in effect",
+			zap.Int("version", fr.policyVersion),
+			zap.Float64("alpha", fr.alpha),
+			zap.Float64("configured_alpha", fr.config.Alpha),
+		)
+	}
+}
+
+// copyWeights copies a set of group weights
+func copyWeights(weights map[string]float64) map[string]float64 {
+	copied := make(map[string]float64, len(weights))
+	for group, weight := range weights {
+		copied[group] = weight
+	}
+	return copied
+}
+
+// equalWeights reports whether two sets of group weights are the same
+func equalWeights(a, b map[string]float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for group, weight := range a {
+		if other, ok := b[group]; !ok || other != weight {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,147 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/audit"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/common/v1"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/fairrent/v1"
+	"go.uber.org/zap"
+)
+
+func TestFairRent_SetPolicy(t *testing.T) {
+	config := DefaultConfig()
+	config.AuditLog = audit.NewLog()
+	fr := NewFairRent(config, zap.NewNop())
+	ctx := context.Background()
+
+	for user, group := range map[string]commonv1.UserGroup{
+		"student": commonv1.UserGroup_USER_GROUP_STUDENT,
+		"middle":  commonv1.UserGroup_USER_GROUP_MIDDLE_INCOME,
+	} {
+		_, err := fr.Enqueue(ctx, &fairrentv1.EnqueueRequest{
+			UserId:    &commonv1.UserID{Value: user},
+			UserGroup: group,
+			Urgency:   commonv1.UrgencyLevel_URGENCY_LEVEL_MEDIUM,
+		})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, "student", fr.queue.tickets[0].UserID)
+
+	_, err := fr.SetPolicy("ops", 0, nil, "")
+	assert.ErrorIs(t, err, ErrInvalidPolicy)
+	_, err = fr.SetPolicy("ops", 2.0, map[string]float64{"USER_GROUP_UNSPECIFIED": 1}, "")
+	assert.ErrorIs(t, err, ErrInvalidPolicy)
+
+	// Queued tickets are rescored under the new weights
+	weights := map[string]float64{"USER_GROUP_MIDDLE_INCOME": 2.0}
+	changed, err := fr.SetPolicy("ops", 3.0, weights, "review")
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, "middle", fr.queue.tickets[0].UserID)
+	for _, ticket := range fr.queue.tickets {
+		assert.Equal(t, 3.0, ticket.Factors.Alpha)
+		assert.Equal(t, 2, ticket.Factors.PolicyVersion)
+		assert.Equal(t, ticket.Factors.Score(), ticket.PriorityScore)
+	}
+
+	// The scheduler keeps its own copy of the weights
+	weights["USER_GROUP_MIDDLE_INCOME"] = 0.5
+	changed, err = fr.SetPolicy("ops", 3.0, map[string]float64{"USER_GROUP_MIDDLE_INCOME": 2.0}, "")
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	var changes []audit.Record
+	for _, record := range config.AuditLog.Records(0, 0) {
+		if record.Type == PolicyChangeRecordType {
+			changes = append(changes, record)
+		}
+	}
+	// The configured policy took effect as version 1
+	require.Len(t, changes, 2)
+	assert.Equal(t, configPolicyActor, changes[0].Actor)
+	assert.Equal(t, "ops", changes[1].Actor)
+	assert.Contains(t, string(changes[1].Payload), `"alpha":3`)
+	assert.Contains(t, string(changes[1].Payload), `"previous_version":1`)
+}
+
+func TestFairRent_SchedulePolicy(t *testing.T) {
+	config := DefaultConfig()
+	config.AuditLog = audit.NewLog()
+	fr := NewFairRent(config, zap.NewNop())
+	ctx := context.Background()
+	start := time.Now()
+
+	_, err := fr.SchedulePolicy(ctx, "ops", 3.0, nil, start.Add(-time.Hour), "")
+	assert.ErrorIs(t, err, ErrInvalidPolicy)
+
+	effective := start.Add(100 * time.Millisecond)
+	scheduled, err := fr.SchedulePolicy(ctx, "ops", 3.0, nil, effective, "annual review")
+	require.NoError(t, err)
+	assert.Equal(t, 2, scheduled.Version)
+	assert.Equal(t, PolicyScheduled, scheduled.Status)
+
+	_, err = fr.Enqueue(ctx, &fairrentv1.EnqueueRequest{
+		UserId:    &commonv1.UserID{Value: "student"},
+		UserGroup: commonv1.UserGroup_USER_GROUP_STUDENT,
+		Urgency:   commonv1.UrgencyLevel_URGENCY_LEVEL_MEDIUM,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, fr.queue.tickets[0].Factors.PolicyVersion)
+
+	// The version takes effect on time and rescores the queue
+	assert.Eventually(t, func() bool {
+		_, active := fr.Policies()
+		return active == 2
+	}, time.Second, 10*time.Millisecond)
+	fr.mu.RLock()
+	assert.Equal(t, 2, fr.queue.tickets[0].Factors.PolicyVersion)
+	assert.Equal(t, 3.0, fr.queue.tickets[0].Factors.Alpha)
+	fr.mu.RUnlock()
+
+	// Past policies can be looked up by time
+	past, err := fr.PolicyAt(start)
+	require.NoError(t, err)
+	assert.Equal(t, 1, past.Version)
+	assert.Equal(t, PolicySuperseded, past.Status)
+	current, err := fr.PolicyAt(time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 2, current.Version)
+	assert.Equal(t, PolicyActive, current.Status)
+	assert.False(t, current.ActivatedAt.IsZero())
+	_, err = fr.PolicyAt(start.Add(-time.Hour))
+	assert.ErrorIs(t, err, ErrPolicyNotFound)
+	_, err = fr.Policy(3)
+	assert.ErrorIs(t, err, ErrPolicyNotFound)
+}
+
+func TestFairRent_RestorePolicies(t *testing.T) {
+	config := DefaultConfig()
+	config.AuditLog = audit.NewLog()
+	fr := NewFairRent(config, zap.NewNop())
+	ctx := context.Background()
+
+	_, err := fr.SetPolicy("ops", 3.0, nil, "review")
+	require.NoError(t, err)
+	_, err = fr.SchedulePolicy(ctx, "ops", 4.0, nil, time.Now().Add(time.Hour), "next year")
+	require.NoError(t, err)
+
+	// A restart keeps the recorded versions and their numbering
+	restarted := NewFairRent(config, zap.NewNop())
+	versions, active := restarted.Policies()
+	require.Len(t, versions, 3)
+	assert.Equal(t, 2, active)
+	assert.Equal(t, 3.0, restarted.alpha)
+	assert.False(t, versions[1].ActivatedAt.IsZero())
+	assert.Equal(t, PolicyScheduled, versions[2].Status)
+	assert.Equal(t, "ops", versions[2].CreatedBy)
+
+	next, err := restarted.SchedulePolicy(ctx, "ops", 5.0, nil, time.Time{}, "")
+	require.NoError(t, err)
+	assert.Equal(t, 4, next.Version)
+	assert.Equal(t, PolicyActive, next.Status)
+}
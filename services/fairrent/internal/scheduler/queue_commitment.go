@@ -0,0 +1,126 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/commitment"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/common/v1"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/fairrent/v1"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// QueueCommitmentRecordType is the audit record type anchoring a queue commitment
+const QueueCommitmentRecordType = "queue_commitment"
+
+// ErrNoCommitment is returned before the first queue commitment is published
+var ErrNoCommitment = errors.New("no queue commitment has been published yet")
+
+// CommitQueue publishes a new Merkle commitment over the current queue order
+// and anchors its root in the audit log
+func (fr *FairRent) CommitQueue() (*commitment.Commitment, error) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	ordered := fr.orderedTickets()
+	entries := make([]commitment.Entry, len(ordered))
+	for i, ticket := range ordered {
+		entries[i] = commitment.Entry{TicketID: ticket.ID, Score: ticket.PriorityScore}
+	}
+
+	var epoch uint64 = 1
+	if fr.commitment != nil {
+		epoch = fr.commitment.Epoch + 1
+	}
+
+	c, err := commitment.Build(epoch, entries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build queue commitment: %w", err)
+	}
+
+	record, err := fr.audit.Append(QueueCommitmentRecordType, "fairrentd", map[string]interface{}{
+		"epoch": c.Epoch,
+		"root":  hex.EncodeToString(c.Root),
+		"size":  c.Size,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to anchor queue commitment: %w", err)
+	}
+
+	fr.commitment = c
+	fr.commitmentAnchor = record.Sequence
+
+	fr.logger.Info("Queue commitment published",
+		zap.Uint64("epoch", c.Epoch),
+		zap.String("root", hex.EncodeToString(c.Root)),
+		zap.Int("size", c.Size),
+		zap.Uint64("audit_sequence", record.Sequence),
+	)
+
+	return c, nil
+}
+
+// GetQueueCommitment returns the latest published queue commitment
+func (fr *FairRent) GetQueueCommitment(ctx context.Context) (*fairrentv1.QueueCommitment, error) {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	if fr.commitment == nil {
+		return nil, ErrNoCommitment
+	}
+	return fr.commitmentProto(), nil
+}
+
+// GetPositionProof proves a ticket's position under the latest commitment
+func (fr *FairRent) GetPositionProof(ctx context.Context, req *fairrentv1.GetPositionProofRequest) (*fairrentv1.PositionProof, error) {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	ticketID := req.TicketId.Value
+	if fr.commitment == nil {
+		return nil, ErrNoCommitment
+	}
+
+	proof, err := fr.commitment.Prove(ticketID)
+	if errors.Is(err, commitment.ErrTicketNotCommitted) {
+		return nil, fmt.Errorf("%w: %s is not in epoch %d, retry after the next commitment",
+			err, ticketID, fr.commitment.Epoch)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &fairrentv1.PositionProof{
+		Commitment: fr.commitmentProto(),
+		TicketId:   &commonv1.TicketID{Value: ticketID},
+		Position:   int32(proof.Position),
+		Score:      proof.Score,
+		Salt:       proof.Salt,
+		Siblings:   proof.Siblings,
+	}, nil
+}
+
+// commitmentProto converts the latest commitment to protobuf format
+func (fr *FairRent) commitmentProto() *fairrentv1.QueueCommitment {
+	return &fairrentv1.QueueCommitment{
+		Epoch:         fr.commitment.Epoch,
+		Root:          fr.commitment.Root,
+		Size:          int32(fr.commitment.Size),
+		CommittedAt:   timestamppb.New(fr.commitment.CreatedAt),
+		AuditSequence: fr.commitmentAnchor,
+	}
+}
+
+// orderedTickets returns the queued tickets in the order they will be served
+func (fr *FairRent) orderedTickets() []*Ticket {
+	ordered := make([]*Ticket, len(fr.queue.tickets))
+	copy(ordered, fr.queue.tickets)
+	sort.Slice(ordered, func(i, j int) bool {
+		return ranksBefore(ordered[i], ordered[j])
+	})
+	return ordered
+}
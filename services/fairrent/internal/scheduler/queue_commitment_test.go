@@ -0,0 +1,99 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/commitment"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/common/v1"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/fairrent/v1"
+	"go.uber.org/zap"
+)
+
+func TestFairRent_QueueCommitment(t *testing.T) {
+	fr := NewFairRent(nil, zap.NewNop())
+	ctx := context.Background()
+
+	enqueue := func(group commonv1.UserGroup, urgency commonv1.UrgencyLevel) *commonv1.TicketID {
+		resp, err := fr.Enqueue(ctx, &fairrentv1.EnqueueRequest{
+			UserId:    &commonv1.UserID{Value: "user"},
+			UserGroup: group,
+			Urgency:   urgency,
+		})
+		require.NoError(t, err)
+		return resp.TicketId
+	}
+	// The two students score the same and are ordered by enqueue time
+	tickets := []*commonv1.TicketID{
+		enqueue(commonv1.UserGroup_USER_GROUP_STUDENT, commonv1.UrgencyLevel_URGENCY_LEVEL_MEDIUM),
+		enqueue(commonv1.UserGroup_USER_GROUP_REFUGEE, commonv1.UrgencyLevel_URGENCY_LEVEL_CRITICAL),
+		enqueue(commonv1.UserGroup_USER_GROUP_STUDENT, commonv1.UrgencyLevel_URGENCY_LEVEL_MEDIUM),
+		enqueue(commonv1.UserGroup_USER_GROUP_SENIOR, commonv1.UrgencyLevel_URGENCY_LEVEL_LOW),
+	}
+
+	_, err := fr.GetQueueCommitment(ctx)
+	assert.ErrorIs(t, err, ErrNoCommitment)
+	_, err = fr.GetPositionProof(ctx, &fairrentv1.GetPositionProofRequest{TicketId: tickets[0]})
+	assert.ErrorIs(t, err, ErrNoCommitment)
+
+	c, err := fr.CommitQueue()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), c.Epoch)
+
+	// The root is anchored in the audit log
+	published, err := fr.GetQueueCommitment(ctx)
+	require.NoError(t, err)
+	record, err := fr.audit.Get(published.AuditSequence)
+	require.NoError(t, err)
+	assert.Equal(t, QueueCommitmentRecordType, record.Type)
+	var anchored struct {
+		Root string `json:"root"`
+	}
+	require.NoError(t, json.Unmarshal(record.Payload, &anchored))
+	assert.Equal(t, hex.EncodeToString(published.Root), anchored.Root)
+
+	// Every proof verifies against the anchored root at the position the
+	// ticket is reported at
+	for _, ticketID := range tickets {
+		pb, err := fr.GetPositionProof(ctx, &fairrentv1.GetPositionProofRequest{TicketId: ticketID})
+		require.NoError(t, err)
+		assert.Equal(t, anchored.Root, hex.EncodeToString(pb.Commitment.Root))
+		require.NoError(t, commitment.VerifyProof(&commitment.Proof{
+			Epoch:    pb.Commitment.Epoch,
+			Root:     pb.Commitment.Root,
+			Size:     int(pb.Commitment.Size),
+			Position: int(pb.Position),
+			TicketID: pb.TicketId.Value,
+			Score:    pb.Score,
+			Salt:     pb.Salt,
+			Siblings: pb.Siblings,
+		}))
+
+		peek, err := fr.PeekPosition(ctx, &fairrentv1.PeekPositionRequest{TicketId: ticketID})
+		require.NoError(t, err)
+		assert.Equal(t, peek.CurrentPosition, pb.Position, ticketID.Value)
+	}
+
+	// Unknown tickets have no proof
+	_, err = fr.GetPositionProof(ctx, &fairrentv1.GetPositionProofRequest{
+		TicketId: &commonv1.TicketID{Value: "nonexistent"},
+	})
+	assert.ErrorIs(t, err, commitment.ErrTicketNotCommitted)
+
+	// Tickets enqueued after the commitment are proven from the next one
+	late := enqueue(commonv1.UserGroup_USER_GROUP_FAMILY, commonv1.UrgencyLevel_URGENCY_LEVEL_HIGH)
+	_, err = fr.GetPositionProof(ctx, &fairrentv1.GetPositionProofRequest{TicketId: late})
+	assert.ErrorIs(t, err, commitment.ErrTicketNotCommitted)
+	assert.ErrorContains(t, err, "retry after the next commitment")
+
+	c, err = fr.CommitQueue()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), c.Epoch)
+	pb, err := fr.GetPositionProof(ctx, &fairrentv1.GetPositionProofRequest{TicketId: late})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), pb.Commitment.Epoch)
+}
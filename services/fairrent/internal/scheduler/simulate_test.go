@@ -0,0 +1,115 @@
+package scheduler
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/common/v1"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/fairrent/v1"
+	"go.uber.org/zap"
+)
+
+func TestFairRent_SimulatePolicy(t *testing.T) {
+	logger := zap.NewNop()
+	fr := NewFairRent(nil, logger)
+	ctx := context.Background()
+
+	student, err := fr.Enqueue(ctx, &fairrentv1.EnqueueRequest{
+		UserId:    &commonv1.UserID{Value: "student"},
+		UserGroup: commonv1.UserGroup_USER_GROUP_STUDENT,
+		Urgency:   commonv1.UrgencyLevel_URGENCY_LEVEL_HIGH,
+	})
+	require.NoError(t, err)
+
+	refugee, err := fr.Enqueue(ctx, &fairrentv1.EnqueueRequest{
+		UserId:    &commonv1.UserID{Value: "refugee"},
+		UserGroup: commonv1.UserGroup_USER_GROUP_REFUGEE,
+		Urgency:   commonv1.UrgencyLevel_URGENCY_LEVEL_LOW,
+	})
+	require.NoError(t, err)
+
+	resp, err := fr.SimulatePolicy(ctx, &fairrentv1.SimulatePolicyRequest{
+		GroupWeights: map[string]float64{"USER_GROUP_REFUGEE": 5.0},
+		Allocations:  1,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, int32(1), resp.Allocations)
+
+	// Baseline keeps the student first: (0.6*1.0)^2 > (0.2*1.5)^2
+	require.Len(t, resp.Baseline.Allocations, 1)
+	assert.Equal(t, student.TicketId.Value, resp.Baseline.Allocations[0].TicketId.Value)
+	assert.Equal(t, 1.5, resp.Baseline.GroupWeights["USER_GROUP_REFUGEE"])
+
+	// The alternative weight moves the refugee ahead: (0.2*5.0)^2 > (0.6*1.0)^2
+	require.Len(t, resp.Alternative.Allocations, 1)
+	assert.Equal(t, refugee.TicketId.Value, resp.Alternative.Allocations[0].TicketId.Value)
+	assert.Equal(t, 2.0, resp.Alternative.Alpha)
+	require.Len(t, resp.Alternative.GroupShares, 1)
+	assert.Equal(t, 1.0, resp.Alternative.GroupShares[0].Share)
+
+	// The live queue is untouched
+	assert.Equal(t, 2, fr.queue.Len())
+	assert.Equal(t, 1.5, fr.groupWeights["USER_GROUP_REFUGEE"])
+	assert.InDelta(t, 0.09, fr.ticketMap[refugee.TicketId.Value].PriorityScore, 1e-9)
+
+	_, err = fr.SimulatePolicy(ctx, &fairrentv1.SimulatePolicyRequest{
+		GroupWeights: map[string]float64{"USER_GROUP_REFUGEE": -1},
+	})
+	assert.Error(t, err)
+
+	// Alternatives are validated like policy versions
+	_, err = fr.SimulatePolicy(ctx, &fairrentv1.SimulatePolicyRequest{Alpha: math.NaN()})
+	assert.ErrorIs(t, err, ErrInvalidPolicy)
+	_, err = fr.SimulatePolicy(ctx, &fairrentv1.SimulatePolicyRequest{
+		GroupWeights: map[string]float64{"USER_GROUP_UNKNOWN": 2},
+	})
+	assert.ErrorIs(t, err, ErrInvalidPolicy)
+}
+
+func TestFairRent_SimulateScheduledPolicy(t *testing.T) {
+	fr := NewFairRent(nil, zap.NewNop())
+	ctx := context.Background()
+
+	var tickets []string
+	for _, req := range []*fairrentv1.EnqueueRequest{
+		{UserId: &commonv1.UserID{Value: "student1"}, UserGroup: commonv1.UserGroup_USER_GROUP_STUDENT, Urgency: commonv1.UrgencyLevel_URGENCY_LEVEL_HIGH},
+		{UserId: &commonv1.UserID{Value: "student2"}, UserGroup: commonv1.UserGroup_USER_GROUP_STUDENT, Urgency: commonv1.UrgencyLevel_URGENCY_LEVEL_HIGH},
+		{UserId: &commonv1.UserID{Value: "refugee"}, UserGroup: commonv1.UserGroup_USER_GROUP_REFUGEE, Urgency: commonv1.UrgencyLevel_URGENCY_LEVEL_LOW},
+	} {
+		ticket, err := fr.Enqueue(ctx, req)
+		require.NoError(t, err)
+		tickets = append(tickets, ticket.TicketId.Value)
+	}
+
+	start := time.Now()
+	_, err := fr.SchedulePolicy(ctx, "ops", 2.0, map[string]float64{"USER_GROUP_REFUGEE": 5.0}, start.Add(30*time.Minute), "")
+	require.NoError(t, err)
+
+	allocated := func(outcome *fairrentv1.SimulationOutcome) []string {
+		var ids []string
+		for _, allocation := range outcome.Allocations {
+			ids = append(ids, allocation.TicketId.Value)
+		}
+		return ids
+	}
+
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	// The version falls due before the second allocation and moves the
+	// refugee ahead of the remaining student
+	outcome := fr.simulate(fr.alpha, fr.groupWeights, false, true, 3, start, time.Hour)
+	assert.Equal(t, []string{tickets[0], tickets[2], tickets[1]}, allocated(outcome))
+
+	// Allocations before the version falls due keep the current policy
+	outcome = fr.simulate(fr.alpha, fr.groupWeights, false, true, 3, start, time.Minute)
+	assert.Equal(t, tickets, allocated(outcome))
+
+	// The live queue is untouched
+	assert.InDelta(t, 0.09, fr.ticketMap[tickets[2]].PriorityScore, 1e-9)
+}
@@ -0,0 +1,331 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/common/v1"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/fairrent/v1"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// DefaultWatchInterval is the default minimum time between two updates on a
+// WatchPosition stream
+const DefaultWatchInterval = time.Second
+
+// DefaultMaxWatchesPerCaller is the default number of WatchPosition streams
+// one caller may hold open at once
+const DefaultMaxWatchesPerCaller = 10
+
+// ErrTooManyWatches is returned when a caller already holds the most
+// WatchPosition streams allowed
+var ErrTooManyWatches = errors.New("too many position watches")
+
+// estimateChangeThreshold is the relative change of the median estimate that
+// is worth an update when the position stays the same
+const estimateChangeThreshold = 0.05
+
+// watcher is one WatchPosition stream. Publishing never blocks: an update
+// replaces the one still pending, so a slow subscriber only ever receives
+// the latest state.
+type watcher struct {
+	ticketID string
+	caller   string
+
+	mu        sync.Mutex
+	pending   *fairrentv1.PositionUpdate
+	coalesced int32
+	notify    chan struct{}
+
+	// Last published state, guarded by FairRent.mu
+	position int
+	estimate WaitEstimate
+}
+
+// newWatcher creates a watcher for a ticket
+func newWatcher(ticketID, caller string) *watcher {
+	return &watcher{ticketID: ticketID, caller: caller, notify: make(chan struct{}, 1)}
+}
+
+// offer makes update the pending one. A pending final update is never
+// replaced.
+func (w *watcher) offer(update *fairrentv1.PositionUpdate) {
+	w.mu.Lock()
+	if w.pending != nil {
+		if isFinalEvent(w.pending.Event) {
+			w.mu.Unlock()
+			return
+		}
+		w.coalesced++
+	}
+	w.pending = update
+	w.mu.Unlock()
+
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+// take returns the pending update, if any, with the number of updates it
+// replaced
+func (w *watcher) take() *fairrentv1.PositionUpdate {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	update := w.pending
+	if update != nil {
+		update.CoalescedUpdates += w.coalesced
+	}
+	w.pending = nil
+	w.coalesced = 0
+	return update
+}
+
+// isFinalEvent reports whether an event ends the stream
+func isFinalEvent(event fairrentv1.PositionEvent) bool {
+	return event == fairrentv1.PositionEvent_POSITION_EVENT_ALLOCATED ||
+		event == fairrentv1.PositionEvent_POSITION_EVENT_CANCELLED
+}
+
+// WatchPosition calls send with the ticket's current state and then with
+// every change of its position, estimated wait or status, until the ticket
+// is allocated or cancelled or ctx is done. Changes arriving within the
+// configured watch interval of the previous update are coalesced. caller
+// identifies whoever opened the stream, for the limit on streams per caller.
+func (fr *FairRent) WatchPosition(ctx context.Context, caller, ticketID string, send func(*fairrentv1.PositionUpdate) error) error {
+	w, err := fr.subscribe(caller, ticketID)
+	if err != nil {
+		return err
+	}
+	defer fr.unsubscribe(w)
+
+	interval := fr.config.WatchInterval
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			fr.logger.Debug("Position watch ended by client", zap.String("ticket_id", ticketID))
+			return nil
+		case <-w.notify:
+		}
+
+		update := w.take()
+		if update == nil {
+			continue
+		}
+		if err := send(update); err != nil {
+			return fmt.Errorf("failed to send position update: %w", err)
+		}
+		if isFinalEvent(update.Event) {
+			return nil
+		}
+
+		// Let changes accumulate before the next update
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			fr.logger.Debug("Position watch ended by client", zap.String("ticket_id", ticketID))
+			return nil
+		case <-timer.C:
+		}
+	}
+}
+
+// subscribe registers a watcher for a ticket, with the ticket's current
+// state pending
+func (fr *FairRent) subscribe(caller, ticketID string) (*watcher, error) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	limit := fr.config.MaxWatchesPerCaller
+	if limit == 0 {
+		limit = DefaultMaxWatchesPerCaller
+	}
+	if limit > 0 && fr.watchCounts[caller] >= limit {
+		return nil, fmt.Errorf("%w: at most %d at once", ErrTooManyWatches, limit)
+	}
+
+	w := newWatcher(ticketID, caller)
+	now := time.Now()
+
+	ticket, queued := fr.ticketMap[ticketID]
+	if !queued {
+		decision, decided := fr.decision(ticketID, now)
+		if !decided {
+			return nil, fmt.Errorf("%w: %s", ErrTicketNotFound, ticketID)
+		}
+		// Already allocated: the stream carries only the final update
+		w.offer(&fairrentv1.PositionUpdate{
+			TicketId:  &commonv1.TicketID{Value: ticketID},
+			Event:     fairrentv1.PositionEvent_POSITION_EVENT_ALLOCATED,
+			Status:    commonv1.AllocationStatus_ALLOCATION_STATUS_ALLOCATED,
+			UpdatedAt: timestamppb.New(decision.AllocatedAt),
+		})
+		return w, nil
+	}
+
+	w.position = fr.calculatePosition(ticket)
+	w.estimate, _ = fr.estimator.estimate(now, w.position-1, ticket.PriorityScore)
+	w.offer(fr.positionUpdate(ticket, fairrentv1.PositionEvent_POSITION_EVENT_ENQUEUED, w.position, w.estimate, now))
+
+	if fr.watchers[ticketID] == nil {
+		fr.watchers[ticketID] = make(map[*watcher]struct{})
+	}
+	fr.watchers[ticketID][w] = struct{}{}
+	fr.watchCounts[caller]++
+	fr.metrics.PositionWatchers.Inc()
+	return w, nil
+}
+
+// unsubscribe removes a watcher
+func (fr *FairRent) unsubscribe(w *watcher) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	watchers, exists := fr.watchers[w.ticketID]
+	if !exists {
+		return
+	}
+	if _, exists := watchers[w]; !exists {
+		return
+	}
+	delete(watchers, w)
+	if len(watchers) == 0 {
+		delete(fr.watchers, w.ticketID)
+	}
+	if fr.watchCounts[w.caller]--; fr.watchCounts[w.caller] <= 0 {
+		delete(fr.watchCounts, w.caller)
+	}
+	fr.metrics.PositionWatchers.Dec()
+}
+
+// notifyWatchers publishes an update to every watcher of a queued ticket
+// whose position or estimated wait changed. The watched tickets are ranked
+// and estimated together, in one pass over the queue and one over the
+// recent arrivals. Callers hold fr.mu.
+func (fr *FairRent) notifyWatchers(now time.Time) {
+	watched := make([]*Ticket, 0, len(fr.watchers))
+	for ticketID := range fr.watchers {
+		if ticket, queued := fr.ticketMap[ticketID]; queued {
+			watched = append(watched, ticket)
+		}
+	}
+	if len(watched) == 0 {
+		return
+	}
+
+	positions := fr.rankPositions(watched)
+	scores := make([]float64, len(watched))
+	ahead := make([]int, len(watched))
+	for i, ticket := range watched {
+		scores[i] = ticket.PriorityScore
+		ahead[i] = positions[i] - 1
+	}
+	estimates, ok := fr.estimator.estimates(now, scores, ahead)
+	if !ok {
+		estimates = make([]WaitEstimate, len(watched))
+	}
+
+	for i, ticket := range watched {
+		position, estimate := positions[i], estimates[i]
+		for w := range fr.watchers[ticket.ID] {
+			event := positionEvent(w, position, estimate)
+			if event == fairrentv1.PositionEvent_POSITION_EVENT_UNSPECIFIED {
+				continue
+			}
+			w.position = position
+			w.estimate = estimate
+			w.offer(fr.positionUpdate(ticket, event, position, estimate, now))
+		}
+	}
+}
+
+// rankPositions sorts tickets from the last served to the first, so by
+// ascending score, and returns their queue positions, as calculatePosition
+// would, with one pass over the queue: a queued ticket is ahead of the
+// tickets ranking below it, so it is counted at the highest of those and
+// summed down below. Callers hold fr.mu.
+func (fr *FairRent) rankPositions(tickets []*Ticket) []int {
+	sort.Slice(tickets, func(i, j int) bool {
+		return ranksBefore(tickets[j], tickets[i])
+	})
+
+	positions := make([]int, len(tickets))
+	for _, queued := range fr.queue.tickets {
+		below := sort.Search(len(tickets), func(i int) bool {
+			return !ranksBefore(queued, tickets[i])
+		})
+		if below > 0 {
+			positions[below-1]++
+		}
+	}
+	ahead := 0
+	for i := len(positions) - 1; i >= 0; i-- {
+		ahead += positions[i]
+		positions[i] = ahead + 1
+	}
+	return positions
+}
+
+// notifyTicket publishes an update to the watchers of one ticket, e.g. when
+// it leaves the queue. Callers hold fr.mu.
+func (fr *FairRent) notifyTicket(ticketID string, update *fairrentv1.PositionUpdate) {
+	for w := range fr.watchers[ticketID] {
+		w.offer(proto.Clone(update).(*fairrentv1.PositionUpdate))
+	}
+}
+
+// positionEvent classifies the change since the watcher's last update, or
+// returns POSITION_EVENT_UNSPECIFIED if nothing worth an update changed
+func positionEvent(w *watcher, position int, estimate WaitEstimate) fairrentv1.PositionEvent {
+	switch {
+	case position > w.position:
+		return fairrentv1.PositionEvent_POSITION_EVENT_OVERTAKEN
+	case position < w.position:
+		return fairrentv1.PositionEvent_POSITION_EVENT_MOVED_UP
+	case estimateChanged(w.estimate, estimate):
+		return fairrentv1.PositionEvent_POSITION_EVENT_ESTIMATE_CHANGED
+	}
+	return fairrentv1.PositionEvent_POSITION_EVENT_UNSPECIFIED
+}
+
+// estimateChanged reports whether a new estimate differs noticeably from the
+// last one sent
+func estimateChanged(last, current WaitEstimate) bool {
+	if last.Bounded != current.Bounded {
+		return true
+	}
+	if !current.Bounded || last.Median <= 0 {
+		return last.Median != current.Median
+	}
+	return math.Abs(current.Median.Seconds()/last.Median.Seconds()-1) >= estimateChangeThreshold
+}
+
+// positionUpdate describes a queued ticket
+func (fr *FairRent) positionUpdate(ticket *Ticket, event fairrentv1.PositionEvent, position int, estimate WaitEstimate, now time.Time) *fairrentv1.PositionUpdate {
+	update := &fairrentv1.PositionUpdate{
+		TicketId:        &commonv1.TicketID{Value: ticket.ID},
+		Event:           event,
+		Status:          commonv1.AllocationStatus_ALLOCATION_STATUS_QUEUED,
+		CurrentPosition: int32(position),
+		TotalInQueue:    int32(fr.queue.Len()),
+		UpdatedAt:       timestamppb.New(now),
+	}
+	// Estimates carry no allocation rate until enough allocations were seen
+	if estimate.AllocationRate > 0 {
+		update.WaitTimeEstimate = estimate.Proto()
+	}
+	return update
+}
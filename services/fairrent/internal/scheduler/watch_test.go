@@ -0,0 +1,165 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/common/v1"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/fairrent/v1"
+	"go.uber.org/zap"
+)
+
+func TestWatcher_CoalescesUpdates(t *testing.T) {
+	w := newWatcher("t1", "caller")
+	w.offer(&fairrentv1.PositionUpdate{Event: fairrentv1.PositionEvent_POSITION_EVENT_OVERTAKEN, CurrentPosition: 3})
+	w.offer(&fairrentv1.PositionUpdate{Event: fairrentv1.PositionEvent_POSITION_EVENT_OVERTAKEN, CurrentPosition: 4})
+	w.offer(&fairrentv1.PositionUpdate{Event: fairrentv1.PositionEvent_POSITION_EVENT_MOVED_UP, CurrentPosition: 2})
+
+	update := w.take()
+	require.NotNil(t, update)
+	assert.Equal(t, int32(2), update.CurrentPosition)
+	assert.Equal(t, int32(2), update.CoalescedUpdates)
+	assert.Nil(t, w.take())
+
+	// A final update is never replaced
+	w.offer(&fairrentv1.PositionUpdate{Event: fairrentv1.PositionEvent_POSITION_EVENT_CANCELLED})
+	w.offer(&fairrentv1.PositionUpdate{Event: fairrentv1.PositionEvent_POSITION_EVENT_MOVED_UP})
+	assert.Equal(t, fairrentv1.PositionEvent_POSITION_EVENT_CANCELLED, w.take().Event)
+}
+
+func TestFairRent_WatchPosition(t *testing.T) {
+	config := DefaultConfig()
+	config.WatchInterval = time.Millisecond
+	fr := NewFairRent(config, zap.NewNop())
+	ctx := context.Background()
+
+	enqueue := func(group commonv1.UserGroup, urgency commonv1.UrgencyLevel) string {
+		resp, err := fr.Enqueue(ctx, &fairrentv1.EnqueueRequest{
+			UserId:    &commonv1.UserID{Value: "user"},
+			UserGroup: group,
+			Urgency:   urgency,
+		})
+		require.NoError(t, err)
+		return resp.TicketId.Value
+	}
+	watched := enqueue(commonv1.UserGroup_USER_GROUP_STUDENT, commonv1.UrgencyLevel_URGENCY_LEVEL_MEDIUM)
+
+	updates := make(chan *fairrentv1.PositionUpdate, 16)
+	done := make(chan error, 1)
+	go func() {
+		done <- fr.WatchPosition(ctx, "caller", watched, func(update *fairrentv1.PositionUpdate) error {
+			updates <- update
+			return nil
+		})
+	}()
+	next := func() *fairrentv1.PositionUpdate {
+		select {
+		case update := <-updates:
+			return update
+		case <-time.After(time.Second):
+			t.Fatal("no position update")
+			return nil
+		}
+	}
+
+	update := next()
+	assert.Equal(t, fairrentv1.PositionEvent_POSITION_EVENT_ENQUEUED, update.Event)
+	assert.Equal(t, int32(1), update.CurrentPosition)
+
+	enqueue(commonv1.UserGroup_USER_GROUP_REFUGEE, commonv1.UrgencyLevel_URGENCY_LEVEL_CRITICAL)
+	update = next()
+	assert.Equal(t, fairrentv1.PositionEvent_POSITION_EVENT_OVERTAKEN, update.Event)
+	assert.Equal(t, int32(2), update.CurrentPosition)
+	assert.Equal(t, int32(2), update.TotalInQueue)
+
+	_, err := fr.ScheduleNext(ctx, &fairrentv1.ScheduleNextRequest{})
+	require.NoError(t, err)
+	update = next()
+	assert.Equal(t, fairrentv1.PositionEvent_POSITION_EVENT_MOVED_UP, update.Event)
+	assert.Equal(t, int32(1), update.CurrentPosition)
+
+	_, err = fr.CancelRequest(ctx, &fairrentv1.CancelRequestRequest{
+		TicketId: &commonv1.TicketID{Value: watched},
+		Reason:   "found a flat",
+	})
+	require.NoError(t, err)
+	update = next()
+	assert.Equal(t, fairrentv1.PositionEvent_POSITION_EVENT_CANCELLED, update.Event)
+	assert.Equal(t, commonv1.AllocationStatus_ALLOCATION_STATUS_CANCELLED, update.Status)
+	assert.Equal(t, "found a flat", update.Reason)
+
+	// The stream ends after the final update and the watcher is removed
+	require.NoError(t, <-done)
+	assert.Empty(t, fr.watchers)
+
+	err = fr.WatchPosition(ctx, "caller", "TKT_unknown", func(*fairrentv1.PositionUpdate) error { return nil })
+	assert.Error(t, err)
+}
+
+func TestFairRent_RankPositions(t *testing.T) {
+	fr := NewFairRent(DefaultConfig(), zap.NewNop())
+	ctx := context.Background()
+
+	urgencies := []commonv1.UrgencyLevel{
+		commonv1.UrgencyLevel_URGENCY_LEVEL_LOW,
+		commonv1.UrgencyLevel_URGENCY_LEVEL_CRITICAL,
+		commonv1.UrgencyLevel_URGENCY_LEVEL_MEDIUM,
+		commonv1.UrgencyLevel_URGENCY_LEVEL_MEDIUM,
+		commonv1.UrgencyLevel_URGENCY_LEVEL_HIGH,
+	}
+	for _, urgency := range urgencies {
+		_, err := fr.Enqueue(ctx, &fairrentv1.EnqueueRequest{
+			UserId:    &commonv1.UserID{Value: "user"},
+			UserGroup: commonv1.UserGroup_USER_GROUP_STUDENT,
+			Urgency:   urgency,
+		})
+		require.NoError(t, err)
+	}
+
+	tickets := append([]*Ticket(nil), fr.queue.tickets...)
+	positions := fr.rankPositions(tickets)
+	for i, ticket := range tickets {
+		assert.Equal(t, fr.calculatePosition(ticket), positions[i], ticket.ID)
+	}
+	// Equal scores are ranked by enqueue time, as in the served order
+	assert.ElementsMatch(t, []int{1, 2, 3, 4, 5}, positions)
+}
+
+func TestFairRent_WatchLimit(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxWatchesPerCaller = 1
+	fr := NewFairRent(config, zap.NewNop())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	resp, err := fr.Enqueue(ctx, &fairrentv1.EnqueueRequest{
+		UserId:    &commonv1.UserID{Value: "user"},
+		UserGroup: commonv1.UserGroup_USER_GROUP_STUDENT,
+		Urgency:   commonv1.UrgencyLevel_URGENCY_LEVEL_MEDIUM,
+	})
+	require.NoError(t, err)
+	ticketID := resp.TicketId.Value
+
+	started := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- fr.WatchPosition(ctx, "caller", ticketID, func(*fairrentv1.PositionUpdate) error {
+			close(started)
+			return nil
+		})
+	}()
+	<-started
+
+	err = fr.WatchPosition(ctx, "caller", ticketID, func(*fairrentv1.PositionUpdate) error { return nil })
+	assert.ErrorIs(t, err, ErrTooManyWatches)
+
+	// Closed streams no longer count
+	cancel()
+	require.NoError(t, <-done)
+	fr.mu.RLock()
+	assert.Empty(t, fr.watchCounts)
+	fr.mu.RUnlock()
+}
@@ -0,0 +1,275 @@
+// Package sketch provides mergeable quantile sketches with bounded memory.
+//
+// Sketch is a DDSketch: values are counted in logarithmically sized buckets,
+// so every quantile is returned with a bounded relative error regardless of
+// how many values were added. Two sketches with the same accuracy merge
+// exactly, which is what sliding windows are built on.
+package sketch
+
+import (
+	"errors"
+	"math"
+)
+
+// DefaultRelativeAccuracy bounds the relative error of returned quantiles to 1%
+const DefaultRelativeAccuracy = 0.01
+
+// DefaultMaxBins bounds the memory of a sketch. With 1% accuracy this covers
+// values from a microsecond to several years before the lowest buckets are
+// collapsed together.
+const DefaultMaxBins = 2048
+
+// minIndexableValue is the smallest value with its own bucket; smaller
+// values are counted as zero
+const minIndexableValue = 1e-9
+
+// ErrIncompatible is returned when merging sketches with different accuracies
+var ErrIncompatible = errors.New("sketches have different relative accuracy")
+
+// Sketch is a mergeable quantile sketch over non-negative values
+type Sketch struct {
+	relativeAccuracy float64
+	gamma            float64
+	logGamma         float64
+	maxBins          int
+
+	// bins[i] counts the values whose bucket key is offset+i
+	bins   []uint64
+	offset int
+
+	zeroCount uint64
+	count     uint64
+	sum       float64
+	min       float64
+	max       float64
+}
+
+// New creates an empty sketch with the given relative accuracy, e.g. 0.01
+func New(relativeAccuracy float64) *Sketch {
+	if relativeAccuracy <= 0 || relativeAccuracy >= 1 {
+		relativeAccuracy = DefaultRelativeAccuracy
+	}
+	gamma := (1 + relativeAccuracy) / (1 - relativeAccuracy)
+	return &Sketch{
+		relativeAccuracy: relativeAccuracy,
+		gamma:            gamma,
+		logGamma:         math.Log(gamma),
+		maxBins:          DefaultMaxBins,
+		min:              math.Inf(1),
+		max:              math.Inf(-1),
+	}
+}
+
+// Add records a value; negative values are counted as zero
+func (s *Sketch) Add(value float64) {
+	s.AddCount(value, 1)
+}
+
+// AddCount records a value n times
+func (s *Sketch) AddCount(value float64, n uint64) {
+	if n == 0 || math.IsNaN(value) {
+		return
+	}
+	if value < 0 {
+		value = 0
+	}
+
+	if value < minIndexableValue {
+		s.zeroCount += n
+	} else {
+		s.addToBin(s.key(value), n)
+	}
+
+	s.count += n
+	s.sum += value * float64(n)
+	s.min = math.Min(s.min, value)
+	s.max = math.Max(s.max, value)
+}
+
+// Merge adds all values of other into s
+func (s *Sketch) Merge(other *Sketch) error {
+	if other == nil {
+		return nil
+	}
+	if other.relativeAccuracy != s.relativeAccuracy {
+		return ErrIncompatible
+	}
+	if other.count == 0 {
+		return nil
+	}
+
+	for i, n := range other.bins {
+		if n > 0 {
+			s.addToBin(other.offset+i, n)
+		}
+	}
+	s.zeroCount += other.zeroCount
+	s.count += other.count
+	s.sum += other.sum
+	s.min = math.Min(s.min, other.min)
+	s.max = math.Max(s.max, other.max)
+	return nil
+}
+
+// Clone returns an independent copy of the sketch
+func (s *Sketch) Clone() *Sketch {
+	c := *s
+	c.bins = append([]uint64(nil), s.bins...)
+	return &c
+}
+
+// Reset removes all values
+func (s *Sketch) Reset() {
+	s.bins = s.bins[:0]
+	s.offset = 0
+	s.zeroCount = 0
+	s.count = 0
+	s.sum = 0
+	s.min = math.Inf(1)
+	s.max = math.Inf(-1)
+}
+
+// Quantile returns the value at quantile q in [0, 1], or 0 for an empty sketch
+func (s *Sketch) Quantile(q float64) float64 {
+	if s.count == 0 {
+		return 0
+	}
+	if q <= 0 {
+		return s.min
+	}
+	if q >= 1 {
+		return s.max
+	}
+
+	rank := uint64(q * float64(s.count-1))
+	if rank < s.zeroCount {
+		return 0
+	}
+
+	seen := s.zeroCount
+	for i, n := range s.bins {
+		seen += n
+		if seen > rank {
+			// Exact extremes are known, so never report beyond them
+			return math.Max(s.min, math.Min(s.max, s.value(s.offset+i)))
+		}
+	}
+	return s.max
+}
+
+// ForEach calls fn for every non-empty bucket in ascending order with the
+// bucket's representative value and count
+func (s *Sketch) ForEach(fn func(value float64, count uint64)) {
+	if s.zeroCount > 0 {
+		fn(0, s.zeroCount)
+	}
+	for i, n := range s.bins {
+		if n > 0 {
+			fn(s.value(s.offset+i), n)
+		}
+	}
+}
+
+// Count returns the number of recorded values
+func (s *Sketch) Count() uint64 { return s.count }
+
+// Sum returns the sum of recorded values
+func (s *Sketch) Sum() float64 { return s.sum }
+
+// Mean returns the mean of recorded values, or 0 for an empty sketch
+func (s *Sketch) Mean() float64 {
+	if s.count == 0 {
+		return 0
+	}
+	return s.sum / float64(s.count)
+}
+
+// Min returns the smallest recorded value, or 0 for an empty sketch
+func (s *Sketch) Min() float64 {
+	if s.count == 0 {
+		return 0
+	}
+	return s.min
+}
+
+// Max returns the largest recorded value, or 0 for an empty sketch
+func (s *Sketch) Max() float64 {
+	if s.count == 0 {
+		return 0
+	}
+	return s.max
+}
+
+// RelativeAccuracy returns the relative error bound of quantiles
+func (s *Sketch) RelativeAccuracy() float64 { return s.relativeAccuracy }
+
+// key maps a value to its logarithmic bucket
+func (s *Sketch) key(value float64) int {
+	return int(math.Ceil(math.Log(value) / s.logGamma))
+}
+
+// value returns the representative value of a bucket, which is within the
+// relative accuracy of every value in it
+func (s *Sketch) value(key int) float64 {
+	return 2 * math.Pow(s.gamma, float64(key)) / (s.gamma + 1)
+}
+
+// addToBin grows the dense bucket range to include key and counts n values.
+// When the range would exceed maxBins, the lowest buckets are collapsed,
+// which keeps the accuracy of the upper quantiles that matter for wait times.
+func (s *Sketch) addToBin(key int, n uint64) {
+	if len(s.bins) == 0 {
+		s.bins = append(s.bins, n)
+		s.offset = key
+		return
+	}
+
+	if key < s.offset {
+		if s.offset+len(s.bins)-key > s.maxBins {
+			// Too far below the range; count it in the lowest bucket
+			s.bins[0] += n
+			return
+		}
+		grown := make([]uint64, s.offset-key+len(s.bins))
+		copy(grown[s.offset-key:], s.bins)
+		s.bins = grown
+		s.offset = key
+	}
+
+	if index := key - s.offset; index >= len(s.bins) {
+		if index >= s.maxBins {
+			s.collapseBelow(key - s.maxBins + 1)
+			index = key - s.offset
+		}
+		for len(s.bins) <= index {
+			s.bins = append(s.bins, 0)
+		}
+	}
+
+	s.bins[key-s.offset] += n
+}
+
+// collapseBelow folds all buckets below key into the bucket at key
+func (s *Sketch) collapseBelow(key int) {
+	drop := key - s.offset
+	if drop <= 0 {
+		return
+	}
+
+	var collapsed uint64
+	if drop >= len(s.bins) {
+		for _, n := range s.bins {
+			collapsed += n
+		}
+		s.bins = []uint64{collapsed}
+		s.offset = key
+		return
+	}
+
+	for _, n := range s.bins[:drop] {
+		collapsed += n
+	}
+	s.bins = append([]uint64(nil), s.bins[drop:]...)
+	s.bins[0] += collapsed
+	s.offset = key
+}
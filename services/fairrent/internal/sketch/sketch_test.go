@@ -0,0 +1,82 @@
+package sketch
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSketch_QuantilesWithinRelativeAccuracy(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	s := New(DefaultRelativeAccuracy)
+
+	values := make([]float64, 100000)
+	for i := range values {
+		// Wait times between a minute and a few months, heavily skewed
+		values[i] = 60 * math.Exp(rng.Float64()*12)
+		s.Add(values[i])
+	}
+	sort.Float64s(values)
+
+	for _, q := range []float64{0.5, 0.95, 0.99} {
+		exact := values[int(q*float64(len(values)-1))]
+		assert.InEpsilon(t, exact, s.Quantile(q), DefaultRelativeAccuracy, "q=%v", q)
+	}
+	assert.Equal(t, uint64(len(values)), s.Count())
+	assert.Equal(t, values[0], s.Quantile(0))
+	assert.Equal(t, values[len(values)-1], s.Quantile(1))
+	assert.LessOrEqual(t, len(s.bins), DefaultMaxBins)
+}
+
+func TestSketch_Merge(t *testing.T) {
+	a, b, all := New(0.01), New(0.01), New(0.01)
+	for i := 1; i <= 1000; i++ {
+		v := float64(i)
+		if i%2 == 0 {
+			a.Add(v)
+		} else {
+			b.Add(v)
+		}
+		all.Add(v)
+	}
+	a.Add(0)
+	all.Add(0)
+
+	require.NoError(t, a.Merge(b))
+	assert.Equal(t, all.Count(), a.Count())
+	assert.Equal(t, all.Sum(), a.Sum())
+	for _, q := range []float64{0.1, 0.5, 0.9, 0.99} {
+		assert.Equal(t, all.Quantile(q), a.Quantile(q))
+	}
+
+	assert.ErrorIs(t, a.Merge(New(0.05)), ErrIncompatible)
+}
+
+func TestWindow_ExpiresOldValues(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	w := NewWindow(time.Hour, 60, DefaultRelativeAccuracy)
+
+	w.Add(start, 1000)
+	w.Add(start.Add(30*time.Minute), 10)
+	w.Add(start.Add(50*time.Minute), 20)
+
+	snapshot := w.Snapshot(start.Add(55 * time.Minute))
+	assert.Equal(t, uint64(3), snapshot.Count())
+	assert.Equal(t, 1000.0, snapshot.Max())
+
+	// An hour later the first value has slid out of the window
+	snapshot = w.Snapshot(start.Add(61 * time.Minute))
+	assert.Equal(t, uint64(2), snapshot.Count())
+	assert.Equal(t, 20.0, snapshot.Max())
+
+	// Writes reuse expired slots
+	w.Add(start.Add(2*time.Hour), 5)
+	snapshot = w.Snapshot(start.Add(2 * time.Hour))
+	assert.Equal(t, uint64(1), snapshot.Count())
+	assert.Equal(t, 5.0, snapshot.Quantile(0.5))
+}
@@ -0,0 +1,96 @@
+package sketch
+
+import "time"
+
+// DefaultWindowSlices is the number of sub-sketches a window is split into;
+// values expire one slice at a time, so the window edge is accurate to
+// span/DefaultWindowSlices
+const DefaultWindowSlices = 60
+
+// Window is a sketch over a sliding time window. It keeps a ring of
+// sub-sketches, one per slice of the window, and merges the live ones on
+// demand, so memory is bounded by the number of slices.
+type Window struct {
+	span     time.Duration
+	slice    time.Duration
+	accuracy float64
+
+	sketches []*Sketch
+	epochs   []int64 // slice epoch each ring slot currently holds
+}
+
+// NewWindow creates a sliding window sketch covering span, split into the
+// given number of slices
+func NewWindow(span time.Duration, slices int, relativeAccuracy float64) *Window {
+	if slices <= 0 {
+		slices = DefaultWindowSlices
+	}
+	slice := span / time.Duration(slices)
+	if slice <= 0 {
+		slice = 1
+	}
+
+	w := &Window{
+		span:     span,
+		slice:    slice,
+		accuracy: relativeAccuracy,
+		sketches: make([]*Sketch, slices),
+		epochs:   make([]int64, slices),
+	}
+	for i := range w.epochs {
+		w.epochs[i] = -1
+	}
+	return w
+}
+
+// Span returns the length of the window
+func (w *Window) Span() time.Duration { return w.span }
+
+// Add records a value observed at time t
+func (w *Window) Add(t time.Time, value float64) {
+	epoch := w.epoch(t)
+	slot := w.slot(epoch)
+
+	switch {
+	case w.epochs[slot] == epoch:
+	case w.epochs[slot] < epoch:
+		// The slot holds an expired slice; reuse it
+		if w.sketches[slot] == nil {
+			w.sketches[slot] = New(w.accuracy)
+		} else {
+			w.sketches[slot].Reset()
+		}
+		w.epochs[slot] = epoch
+	default:
+		// Older than anything the window still covers
+		return
+	}
+
+	w.sketches[slot].Add(value)
+}
+
+// Snapshot merges the slices that are still inside the window at now
+func (w *Window) Snapshot(now time.Time) *Sketch {
+	merged := New(w.accuracy)
+	current := w.epoch(now)
+	oldest := current - int64(len(w.sketches)) + 1
+
+	for slot, epoch := range w.epochs {
+		if epoch >= oldest && epoch <= current {
+			// Sketches in a window share the accuracy, so merging cannot fail
+			_ = merged.Merge(w.sketches[slot])
+		}
+	}
+	return merged
+}
+
+// epoch numbers the slice that contains t
+func (w *Window) epoch(t time.Time) int64 {
+	return t.UnixNano() / int64(w.slice)
+}
+
+// slot maps a slice epoch to its ring position
+func (w *Window) slot(epoch int64) int {
+	n := int64(len(w.sketches))
+	return int(((epoch % n) + n) % n)
+}
@@ -0,0 +1,240 @@
+// Package slo evaluates fairness service level objectives against the
+// scheduler's metrics and reports breaches.
+package slo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/audit"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/fairrent/v1"
+	"go.uber.org/zap"
+)
+
+// Objective names, used as metric labels and in audit records
+const (
+	ObjectiveGini                = "max_gini"
+	ObjectiveWaitTimeRatio       = "max_wait_time_ratio"
+	ObjectiveGroupShareDeviation = "max_group_share_deviation"
+	ObjectiveOldestTicketAge     = "max_oldest_ticket_age"
+)
+
+// Audit record types written on breach transitions
+const (
+	BreachRecordType    = "slo_breach"
+	RecoveredRecordType = "slo_recovered"
+)
+
+// Objectives are the fairness SLO thresholds. A zero threshold disables the
+// objective.
+type Objectives struct {
+	MaxGini                float64       `yaml:"max_gini"`
+	MaxWaitTimeRatio       float64       `yaml:"max_wait_time_ratio"`
+	MaxGroupShareDeviation float64       `yaml:"max_group_share_deviation"` // |actual - target| allocation share
+	MaxOldestTicketAge     time.Duration `yaml:"max_oldest_ticket_age"`
+
+	// Allocation-based objectives are only judged after this many allocations
+	MinAllocations int `yaml:"min_allocations"`
+}
+
+// Result is the evaluation of one objective
+type Result struct {
+	Objective string
+	Value     float64
+	Threshold float64
+	UserGroup string // group with the largest share deviation, if any
+	Breached  bool
+}
+
+// Evaluate checks the enabled objectives against a metrics snapshot
+func Evaluate(objectives Objectives, metrics *fairrentv1.FairnessMetrics) []Result {
+	var results []Result
+	judgeAllocations := int(metrics.TotalAllocations) >= objectives.MinAllocations
+
+	if objectives.MaxGini > 0 && judgeAllocations {
+		results = append(results, Result{
+			Objective: ObjectiveGini,
+			Value:     metrics.GiniCoefficient,
+			Threshold: objectives.MaxGini,
+			Breached:  metrics.GiniCoefficient > objectives.MaxGini,
+		})
+	}
+
+	if objectives.MaxWaitTimeRatio > 0 && judgeAllocations {
+		results = append(results, Result{
+			Objective: ObjectiveWaitTimeRatio,
+			Value:     metrics.MaxWaitTimeRatio,
+			Threshold: objectives.MaxWaitTimeRatio,
+			Breached:  metrics.MaxWaitTimeRatio > objectives.MaxWaitTimeRatio,
+		})
+	}
+
+	if objectives.MaxGroupShareDeviation > 0 && judgeAllocations && metrics.TotalAllocations > 0 {
+		result := Result{
+			Objective: ObjectiveGroupShareDeviation,
+			Threshold: objectives.MaxGroupShareDeviation,
+		}
+		for _, gm := range metrics.GroupMetrics {
+			if deviation := math.Abs(gm.AllocationRate - gm.TargetAllocationRate); deviation > result.Value {
+				result.Value = deviation
+				result.UserGroup = gm.UserGroup.String()
+			}
+		}
+		result.Breached = result.Value > result.Threshold
+		results = append(results, result)
+	}
+
+	if objectives.MaxOldestTicketAge > 0 {
+		age := metrics.OldestTicketAge.AsDuration()
+		results = append(results, Result{
+			Objective: ObjectiveOldestTicketAge,
+			Value:     age.Seconds(),
+			Threshold: objectives.MaxOldestTicketAge.Seconds(),
+			Breached:  age > objectives.MaxOldestTicketAge,
+		})
+	}
+
+	return results
+}
+
+// SnapshotFunc returns the current fairness metrics
+type SnapshotFunc func(ctx context.Context) (*fairrentv1.FairnessMetrics, error)
+
+// Monitor evaluates objectives periodically. Every evaluation updates the
+// gauges; entering or leaving a breach is logged and recorded in the audit log.
+type Monitor struct {
+	objectives Objectives
+	snapshot   SnapshotFunc
+	audit      *audit.Log
+	logger     *zap.Logger
+
+	breachedGauge  *prometheus.GaugeVec
+	valueGauge     *prometheus.GaugeVec
+	thresholdGauge *prometheus.GaugeVec
+
+	mu       sync.Mutex
+	breached map[string]bool
+}
+
+// NewMonitor creates a monitor whose gauges are registered with reg; a nil
+// registerer leaves them unregistered
+func NewMonitor(objectives Objectives, snapshot SnapshotFunc, auditLog *audit.Log, reg prometheus.Registerer, logger *zap.Logger) *Monitor {
+	factory := promauto.With(reg)
+
+	return &Monitor{
+		objectives: objectives,
+		snapshot:   snapshot,
+		audit:      auditLog,
+		logger:     logger,
+		breachedGauge: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "fairrent_slo_breached",
+			Help: "1 while a fairness SLO is breached, 0 otherwise",
+		}, []string{"objective"}),
+		valueGauge: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "fairrent_slo_value",
+			Help: "Last evaluated value of a fairness SLO (seconds for ages)",
+		}, []string{"objective"}),
+		thresholdGauge: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "fairrent_slo_threshold",
+			Help: "Configured threshold of a fairness SLO (seconds for ages)",
+		}, []string{"objective"}),
+		breached: make(map[string]bool),
+	}
+}
+
+// Run evaluates the objectives every interval until the context is cancelled
+func (m *Monitor) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := m.Evaluate(ctx); err != nil {
+			m.logger.Error("Failed to evaluate fairness SLOs", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Evaluate takes a metrics snapshot, checks the objectives and reports
+// breach transitions
+func (m *Monitor) Evaluate(ctx context.Context) ([]Result, error) {
+	metrics, err := m.snapshot(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to snapshot metrics: %w", err)
+	}
+
+	results := Evaluate(m.objectives, metrics)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var errs []error
+	for _, result := range results {
+		m.valueGauge.WithLabelValues(result.Objective).Set(result.Value)
+		m.thresholdGauge.WithLabelValues(result.Objective).Set(result.Threshold)
+		if result.Breached {
+			m.breachedGauge.WithLabelValues(result.Objective).Set(1)
+		} else {
+			m.breachedGauge.WithLabelValues(result.Objective).Set(0)
+		}
+
+		if result.Breached == m.breached[result.Objective] {
+			continue
+		}
+		m.breached[result.Objective] = result.Breached
+
+		if err := m.recordTransition(result); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return results, errors.Join(errs...)
+}
+
+// recordTransition logs and audits an objective entering or leaving breach
+func (m *Monitor) recordTransition(result Result) error {
+	recordType := RecoveredRecordType
+	if result.Breached {
+		recordType = BreachRecordType
+	}
+
+	fields := []zap.Field{
+		zap.String("event", recordType),
+		zap.String("objective", result.Objective),
+		zap.Float64("value", result.Value),
+		zap.Float64("threshold", result.Threshold),
+	}
+	if result.UserGroup != "" {
+		fields = append(fields, zap.String("user_group", result.UserGroup))
+	}
+
+	if result.Breached {
+		m.logger.Warn("Fairness SLO breached", fields...)
+	} else {
+		m.logger.Info("Fairness SLO recovered", fields...)
+	}
+
+	payload := map[string]interface{}{
+		"objective": result.Objective,
+		"value":     result.Value,
+		"threshold": result.Threshold,
+	}
+	if result.UserGroup != "" {
+		payload["user_group"] = result.UserGroup
+	}
+	if _, err := m.audit.Append(recordType, "fairrentd", payload); err != nil {
+		return fmt.Errorf("failed to record SLO %s: %w", recordType, err)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package slo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wohnfair/wohnfair/services/fairrent/internal/audit"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/common/v1"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/fairrent/v1"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func TestEvaluate(t *testing.T) {
+	objectives := Objectives{
+		MaxGini:                0.3,
+		MaxWaitTimeRatio:       10,
+		MaxGroupShareDeviation: 0.1,
+		MaxOldestTicketAge:     48 * time.Hour,
+		MinAllocations:         10,
+	}
+	metrics := &fairrentv1.FairnessMetrics{
+		TotalAllocations: 20,
+		GiniCoefficient:  0.2,
+		MaxWaitTimeRatio: 12,
+		GroupMetrics: []*fairrentv1.GroupFairnessMetrics{
+			{UserGroup: commonv1.UserGroup_USER_GROUP_STUDENT, AllocationRate: 0.3, TargetAllocationRate: 0.45},
+			{UserGroup: commonv1.UserGroup_USER_GROUP_REFUGEE, AllocationRate: 0.7, TargetAllocationRate: 0.55},
+		},
+		OldestTicketAge: durationpb.New(time.Hour),
+	}
+
+	results := Evaluate(objectives, metrics)
+	require.Len(t, results, 4)
+
+	byObjective := make(map[string]Result)
+	for _, r := range results {
+		byObjective[r.Objective] = r
+	}
+	assert.False(t, byObjective[ObjectiveGini].Breached)
+	assert.True(t, byObjective[ObjectiveWaitTimeRatio].Breached)
+	assert.True(t, byObjective[ObjectiveGroupShareDeviation].Breached)
+	assert.InDelta(t, 0.15, byObjective[ObjectiveGroupShareDeviation].Value, 1e-9)
+	assert.Equal(t, "USER_GROUP_STUDENT", byObjective[ObjectiveGroupShareDeviation].UserGroup)
+	assert.False(t, byObjective[ObjectiveOldestTicketAge].Breached)
+
+	// Allocation-based objectives wait for enough allocations
+	metrics.TotalAllocations = 5
+	results = Evaluate(objectives, metrics)
+	require.Len(t, results, 1)
+	assert.Equal(t, ObjectiveOldestTicketAge, results[0].Objective)
+}
+
+func TestMonitor_RecordsTransitions(t *testing.T) {
+	auditLog := audit.NewLog()
+	metrics := &fairrentv1.FairnessMetrics{GiniCoefficient: 0.5}
+	snapshot := func(ctx context.Context) (*fairrentv1.FairnessMetrics, error) {
+		return metrics, nil
+	}
+	monitor := NewMonitor(Objectives{MaxGini: 0.3}, snapshot, auditLog, prometheus.NewRegistry(), zap.NewNop())
+	ctx := context.Background()
+
+	_, err := monitor.Evaluate(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, testutil.ToFloat64(monitor.breachedGauge.WithLabelValues(ObjectiveGini)))
+
+	// A continuing breach is not recorded again
+	_, err = monitor.Evaluate(ctx)
+	require.NoError(t, err)
+
+	metrics.GiniCoefficient = 0.1
+	_, err = monitor.Evaluate(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0.0, testutil.ToFloat64(monitor.breachedGauge.WithLabelValues(ObjectiveGini)))
+
+	records := auditLog.Records(1, 0)
+	require.Len(t, records, 2)
+	assert.Equal(t, BreachRecordType, records[0].Type)
+	assert.Equal(t, RecoveredRecordType, records[1].Type)
+}
@@ -3,56 +3,185 @@ package telemetry
 import (
 	"context"
 	"fmt"
+	"io"
+	"net/url"
 	"os"
 	"time"
 
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/exporters/jaeger"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/exporters/zipkin"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
 	"go.opentelemetry.io/otel/trace"
 )
 
-// InitTracer initializes OpenTelemetry tracing
-func InitTracer(serviceName, serviceVersion string) error {
-	// Get Jaeger endpoint from environment
-	jaegerEndpoint := os.Getenv("JAEGER_ENDPOINT")
-	if jaegerEndpoint == "" {
-		jaegerEndpoint = "http://localhost:14268/api/traces"
+// Supported trace exporters
+const (
+	ExporterOTLP   = "otlp"
+	ExporterZipkin = "zipkin"
+	ExporterJaeger = "jaeger"
+	ExporterStdout = "stdout"
+)
+
+// Default collector endpoints, used when none is configured
+const (
+	DefaultOTLPEndpoint   = "localhost:4317"
+	DefaultZipkinEndpoint = "http://localhost:9411/api/v2/spans"
+	DefaultJaegerEndpoint = "http://localhost:14268/api/traces"
+)
+
+// Span attribute keys describing tickets and the queue
+const (
+	AttrTicketID      = attribute.Key("fairrent.ticket_id")
+	AttrUserGroup     = attribute.Key("fairrent.user_group")
+	AttrUrgency       = attribute.Key("fairrent.urgency")
+	AttrPriorityScore = attribute.Key("fairrent.priority_score")
+	AttrQueuePosition = attribute.Key("fairrent.queue_position")
+	AttrQueueLength   = attribute.Key("fairrent.queue_length")
+	AttrProperties    = attribute.Key("fairrent.available_properties")
+)
+
+// TracingConfig configures trace export
+type TracingConfig struct {
+	Enabled     bool    `yaml:"enabled"`
+	Exporter    string  `yaml:"exporter"`    // otlp, zipkin, jaeger or stdout
+	Endpoint    string  `yaml:"endpoint"`    // empty uses the exporter's default
+	SampleRate  float64 `yaml:"sample_rate"` // fraction of new traces sampled
+	Environment string  `yaml:"environment"` // deployment.environment resource attribute
+}
+
+// DefaultTracingConfig returns the tracing configuration used without a file
+func DefaultTracingConfig() TracingConfig {
+	return TracingConfig{
+		Enabled:     true,
+		Exporter:    ExporterJaeger,
+		SampleRate:  1.0,
+		Environment: "development",
+	}
+}
+
+// Validate checks the exporter and sample rate
+func (c TracingConfig) Validate() error {
+	switch c.Exporter {
+	case ExporterOTLP, ExporterZipkin, ExporterJaeger, ExporterStdout:
+	default:
+		return fmt.Errorf("unknown trace exporter %q (want otlp, zipkin, jaeger or stdout)", c.Exporter)
+	}
+	if c.SampleRate < 0 || c.SampleRate > 1 {
+		return fmt.Errorf("trace sample rate must be between 0 and 1, got %v", c.SampleRate)
+	}
+	return nil
+}
+
+// ShutdownFunc flushes pending spans and stops the exporter
+type ShutdownFunc func(ctx context.Context) error
+
+// InitTracer initializes OpenTelemetry tracing as configured and returns a
+// function that flushes and stops it. With tracing disabled, the global
+// no-op provider is left in place.
+func InitTracer(ctx context.Context, cfg TracingConfig, serviceName, serviceVersion string) (ShutdownFunc, error) {
+	if !cfg.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
 	}
 
-	// Create Jaeger exporter
-	exporter, err := jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(jaegerEndpoint)))
+	exporter, err := newExporter(ctx, cfg, os.Stdout)
 	if err != nil {
-		return fmt.Errorf("failed to create Jaeger exporter: %w", err)
+		return nil, err
 	}
 
 	// Create resource with service information
-	res, err := resource.New(context.Background(),
+	res, err := resource.New(ctx,
 		resource.WithAttributes(
 			semconv.ServiceName(serviceName),
 			semconv.ServiceVersion(serviceVersion),
-			semconv.DeploymentEnvironment("development"),
+			semconv.DeploymentEnvironment(cfg.Environment),
 		),
 	)
 	if err != nil {
-		return fmt.Errorf("failed to create resource: %w", err)
+		return nil, fmt.Errorf("failed to create resource: %w", err)
 	}
 
-	// Create trace provider
+	// Create trace provider; sampling follows the parent where there is one
 	tp := sdktrace.NewTracerProvider(
 		sdktrace.WithBatcher(exporter,
 			sdktrace.WithBatchTimeout(5*time.Second),
 			sdktrace.WithMaxExportBatchSize(100),
 		),
 		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRate))),
 	)
 
 	// Set global trace provider
 	otel.SetTracerProvider(tp)
 
-	return nil
+	return tp.Shutdown, nil
+}
+
+// newExporter creates the configured span exporter; stdout spans are
+// written to w
+func newExporter(ctx context.Context, cfg TracingConfig, w io.Writer) (sdktrace.SpanExporter, error) {
+	switch cfg.Exporter {
+	case ExporterOTLP:
+		endpoint := cfg.Endpoint
+		if endpoint == "" {
+			endpoint = DefaultOTLPEndpoint
+		}
+		opts := []otlptracegrpc.Option{}
+		// Accept host:port as well as a URL; plain http means no TLS
+		if u, err := url.Parse(endpoint); err == nil && u.Host != "" {
+			endpoint = u.Host
+			if u.Scheme == "http" {
+				opts = append(opts, otlptracegrpc.WithInsecure())
+			}
+		}
+		opts = append(opts, otlptracegrpc.WithEndpoint(endpoint))
+
+		exporter, err := otlptracegrpc.New(ctx, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+		}
+		return exporter, nil
+
+	case ExporterZipkin:
+		endpoint := cfg.Endpoint
+		if endpoint == "" {
+			endpoint = DefaultZipkinEndpoint
+		}
+		exporter, err := zipkin.New(endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Zipkin exporter: %w", err)
+		}
+		return exporter, nil
+
+	case ExporterJaeger:
+		endpoint := cfg.Endpoint
+		if endpoint == "" {
+			endpoint = DefaultJaegerEndpoint
+		}
+		exporter, err := jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(endpoint)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Jaeger exporter: %w", err)
+		}
+		return exporter, nil
+
+	case ExporterStdout:
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(w))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create stdout exporter: %w", err)
+		}
+		return exporter, nil
+	}
+
+	return nil, fmt.Errorf("unknown trace exporter %q", cfg.Exporter)
 }
 
 // GetTracer returns a tracer for the given service
@@ -66,36 +195,33 @@ func StartSpan(ctx context.Context, name string, opts ...trace.SpanStartOption)
 	return tracer.Start(ctx, name, opts...)
 }
 
-// AddSpanEvent adds an event to the current span
-func AddSpanEvent(ctx context.Context, name string, attrs ...trace.SpanStartOption) {
-	span := trace.SpanFromContext(ctx)
-	if span != nil {
-		span.AddEvent(name)
-	}
+// AddSpanEvent adds an event with attributes to the current span
+func AddSpanEvent(ctx context.Context, name string, attrs ...attribute.KeyValue) {
+	trace.SpanFromContext(ctx).AddEvent(name, trace.WithAttributes(attrs...))
 }
 
 // SetSpanAttributes sets attributes on the current span
-func SetSpanAttributes(ctx context.Context, attrs ...trace.SpanStartOption) {
-	span := trace.SpanFromContext(ctx)
-	if span != nil {
-		// This would need to be implemented based on the specific attributes
-		// For now, we'll just add a generic event
-		span.AddEvent("attributes_set")
+func SetSpanAttributes(ctx context.Context, attrs ...attribute.KeyValue) {
+	trace.SpanFromContext(ctx).SetAttributes(attrs...)
+}
+
+// TicketAttributes describes a ticket on a span
+func TicketAttributes(ticketID, userGroup string, priorityScore float64) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		AttrTicketID.String(ticketID),
+		AttrUserGroup.String(userGroup),
+		AttrPriorityScore.Float64(priorityScore),
 	}
 }
 
-// RecordError records an error on the current span
+// RecordError records an error on the current span and marks it failed
 func RecordError(ctx context.Context, err error) {
 	span := trace.SpanFromContext(ctx)
-	if span != nil {
-		span.RecordError(err)
-	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
 }
 
 // SetSpanStatus sets the status on the current span
-func SetSpanStatus(ctx context.Context, code trace.StatusCode, description string) {
-	span := trace.SpanFromContext(ctx)
-	if span != nil {
-		span.SetStatus(code, description)
-	}
+func SetSpanStatus(ctx context.Context, code codes.Code, description string) {
+	trace.SpanFromContext(ctx).SetStatus(code, description)
 }
@@ -0,0 +1,46 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestTracingConfig_Validate(t *testing.T) {
+	cfg := DefaultTracingConfig()
+	for _, exporter := range []string{ExporterOTLP, ExporterZipkin, ExporterJaeger, ExporterStdout} {
+		cfg.Exporter = exporter
+		assert.NoError(t, cfg.Validate(), exporter)
+	}
+
+	cfg.Exporter = "datadog"
+	assert.Error(t, cfg.Validate())
+
+	cfg.Exporter = ExporterStdout
+	cfg.SampleRate = 1.5
+	assert.Error(t, cfg.Validate())
+}
+
+func TestSpanAttributes_Exported(t *testing.T) {
+	var buf bytes.Buffer
+	exporter, err := newExporter(context.Background(), TracingConfig{Exporter: ExporterStdout}, &buf)
+	require.NoError(t, err)
+
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	ctx, span := tp.Tracer("test").Start(context.Background(), "scheduler.match")
+	SetSpanAttributes(ctx, TicketAttributes("TKT_1", "USER_GROUP_STUDENT", 0.36)...)
+	SetSpanAttributes(ctx, AttrQueuePosition.Int(3))
+	span.End()
+	require.NoError(t, tp.Shutdown(context.Background()))
+
+	out := buf.String()
+	assert.Contains(t, out, `"scheduler.match"`)
+	assert.Contains(t, out, `"fairrent.ticket_id"`)
+	assert.Contains(t, out, `"TKT_1"`)
+	assert.Contains(t, out, `"USER_GROUP_STUDENT"`)
+	assert.Contains(t, out, `"fairrent.queue_position"`)
+}
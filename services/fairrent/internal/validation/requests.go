@@ -0,0 +1,259 @@
+package validation
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/common/v1"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/fairrent/v1"
+)
+
+// Limits applied in strict mode
+const (
+	maxIDLength              = 128
+	maxListLength            = 50
+	maxNameLength            = 100
+	maxRooms                 = 20
+	maxRoommates             = 20
+	maxPreferences           = 50
+	maxPreferenceKeyLength   = 64
+	maxPreferenceValueLength = 1024
+
+	// German tenancy law (§ 551 BGB) caps deposits at three months' rent
+	maxDepositMonths = 3
+)
+
+var (
+	postalCodePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9 -]{1,9}$`)
+	countryPattern    = regexp.MustCompile(`^[A-Z]{2}$`) // ISO 3166-1 alpha-2
+)
+
+// EnqueueRequest validates a new housing request
+func EnqueueRequest(req *fairrentv1.EnqueueRequest, cfg Config) error {
+	v := &Violations{}
+
+	if req.GetUserId().GetValue() == "" {
+		v.required("user_id")
+	} else if cfg.Enabled && cfg.Strict {
+		Identifier(v, "user_id", req.UserId.Value)
+	}
+	if !cfg.Enabled {
+		return v.Err()
+	}
+
+	if req.UserGroup == commonv1.UserGroup_USER_GROUP_UNSPECIFIED {
+		v.required("user_group")
+	} else if _, ok := commonv1.UserGroup_name[int32(req.UserGroup)]; !ok {
+		v.invalidEnum("user_group", int32(req.UserGroup))
+	}
+	if req.Urgency == commonv1.UrgencyLevel_URGENCY_LEVEL_UNSPECIFIED {
+		v.required("urgency")
+	} else if _, ok := commonv1.UrgencyLevel_name[int32(req.Urgency)]; !ok {
+		v.invalidEnum("urgency", int32(req.Urgency))
+	}
+	financialConstraints(v, "financial_constraints", req.FinancialConstraints, cfg.Strict)
+	// The score feeds the priority directly, so it is checked in every mode
+	if math.IsNaN(req.PriorityScore) || req.PriorityScore < 0 || req.PriorityScore > 1 {
+		v.outOfRange("priority_score", 0, 1)
+	}
+
+	if !cfg.Strict {
+		return v.Err()
+	}
+
+	locations(v, "preferred_locations", req.PreferredLocations)
+	listLength(v, "preferred_cities", len(req.PreferredCities))
+	for i, city := range req.PreferredCities {
+		name(v, fmt.Sprintf("preferred_cities[%d]", i), city)
+	}
+	listLength(v, "preferred_postal_codes", len(req.PreferredPostalCodes))
+	for i, code := range req.PreferredPostalCodes {
+		if !postalCodePattern.MatchString(code) {
+			field := fmt.Sprintf("preferred_postal_codes[%d]", i)
+			v.Add(field, CodeInvalidFormat, field+" is not a postal code")
+		}
+	}
+
+	listLength(v, "property_types", len(req.PropertyTypes))
+	for i, propertyType := range req.PropertyTypes {
+		field := fmt.Sprintf("property_types[%d]", i)
+		if propertyType == commonv1.PropertyType_PROPERTY_TYPE_UNSPECIFIED {
+			v.required(field)
+		} else if _, ok := commonv1.PropertyType_name[int32(propertyType)]; !ok {
+			v.invalidEnum(field, int32(propertyType))
+		}
+	}
+	if _, ok := commonv1.LeaseDuration_name[int32(req.PreferredDuration)]; !ok {
+		v.invalidEnum("preferred_duration", int32(req.PreferredDuration))
+	}
+
+	if req.MinRooms < 0 || req.MinRooms > maxRooms {
+		v.outOfRange("min_rooms", 0, maxRooms)
+	}
+	if req.MaxRoommates < 0 || req.MaxRoommates > maxRoommates {
+		v.outOfRange("max_roommates", 0, maxRoommates)
+	}
+	preferences(v, "additional_preferences", req.AdditionalPreferences)
+
+	return v.Err()
+}
+
+// UpdateRequest validates changes to a queued request. Unset fields leave
+// the request unchanged.
+func UpdateRequest(req *fairrentv1.UpdateRequestRequest, cfg Config) error {
+	v := &Violations{}
+
+	ticketID(v, req.GetTicketId(), cfg.Enabled && cfg.Strict)
+	if !cfg.Enabled {
+		return v.Err()
+	}
+
+	if _, ok := commonv1.UrgencyLevel_name[int32(req.NewUrgency)]; !ok {
+		v.invalidEnum("new_urgency", int32(req.NewUrgency))
+	}
+	financialConstraints(v, "new_financial_constraints", req.NewFinancialConstraints, cfg.Strict)
+
+	if !cfg.Strict {
+		return v.Err()
+	}
+
+	locations(v, "new_preferred_locations", req.NewPreferredLocations)
+	preferences(v, "new_additional_preferences", req.NewAdditionalPreferences)
+
+	return v.Err()
+}
+
+// TicketID validates the ticket ID of a request naming a single ticket
+func TicketID(id *commonv1.TicketID) error {
+	v := &Violations{}
+	ticketID(v, id, false)
+	return v.Err()
+}
+
+// ticketID checks a required ticket ID
+func ticketID(v *Violations, id *commonv1.TicketID, strict bool) {
+	if id.GetValue() == "" {
+		v.required("ticket_id")
+	} else if strict {
+		Identifier(v, "ticket_id", id.Value)
+	}
+}
+
+// Identifier checks an opaque ID: bounded length, no whitespace or control
+// characters
+func Identifier(v *Violations, field, id string) {
+	if len(id) > maxIDLength {
+		v.Add(field, CodeOutOfRange, fmt.Sprintf("%s must be at most %d bytes", field, maxIDLength),
+			"max_length", fmt.Sprint(maxIDLength))
+		return
+	}
+	if strings.IndexFunc(id, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) }) >= 0 {
+		v.Add(field, CodeInvalidFormat, field+" must not contain whitespace or control characters")
+	}
+}
+
+// name checks a free-text name such as a city
+func name(v *Violations, field, s string) {
+	if strings.TrimSpace(s) == "" {
+		v.required(field)
+	} else if len(s) > maxNameLength {
+		v.Add(field, CodeOutOfRange, fmt.Sprintf("%s must be at most %d bytes", field, maxNameLength),
+			"max_length", fmt.Sprint(maxNameLength))
+	}
+}
+
+// listLength checks the number of entries of a repeated field
+func listLength(v *Violations, field string, n int) {
+	if n > maxListLength {
+		v.Add(field, CodeTooMany, fmt.Sprintf("%s must have at most %d entries", field, maxListLength),
+			"max_entries", fmt.Sprint(maxListLength))
+	}
+}
+
+// locations checks coordinates and country codes
+func locations(v *Violations, field string, locs []*commonv1.Location) {
+	listLength(v, field, len(locs))
+	for i, loc := range locs {
+		prefix := fmt.Sprintf("%s[%d]", field, i)
+		if loc == nil {
+			v.required(prefix)
+			continue
+		}
+		if math.IsNaN(loc.Latitude) || loc.Latitude < -90 || loc.Latitude > 90 {
+			v.outOfRange(prefix+".latitude", -90, 90)
+		}
+		if math.IsNaN(loc.Longitude) || loc.Longitude < -180 || loc.Longitude > 180 {
+			v.outOfRange(prefix+".longitude", -180, 180)
+		}
+		if loc.City != "" {
+			name(v, prefix+".city", loc.City)
+		}
+		if loc.PostalCode != "" && !postalCodePattern.MatchString(loc.PostalCode) {
+			v.Add(prefix+".postal_code", CodeInvalidFormat, prefix+".postal_code is not a postal code")
+		}
+		if loc.Country != "" && !countryPattern.MatchString(loc.Country) {
+			v.Add(prefix+".country", CodeInvalidFormat, prefix+".country must be an ISO 3166-1 alpha-2 code")
+		}
+	}
+}
+
+// financialConstraints checks rent, deposit and income. Outside strict mode
+// only the rent is checked.
+func financialConstraints(v *Violations, field string, fc *commonv1.FinancialConstraints, strict bool) {
+	if fc == nil {
+		return
+	}
+	rentValid := !math.IsNaN(fc.MaxMonthlyRent) && !math.IsInf(fc.MaxMonthlyRent, 0) && fc.MaxMonthlyRent > 0
+	if !rentValid {
+		v.Add(field+".max_monthly_rent", CodeOutOfRange, field+".max_monthly_rent must be positive")
+	}
+	if !strict {
+		return
+	}
+
+	if math.IsNaN(fc.MaxDeposit) || math.IsInf(fc.MaxDeposit, 0) || fc.MaxDeposit < 0 {
+		v.Add(field+".max_deposit", CodeOutOfRange, field+".max_deposit must not be negative")
+	} else if rentValid && fc.MaxDeposit > maxDepositMonths*fc.MaxMonthlyRent {
+		v.Add(field+".max_deposit", CodeInconsistent,
+			fmt.Sprintf("%s.max_deposit must not exceed %d months of max_monthly_rent", field, maxDepositMonths),
+			"max", fmt.Sprint(maxDepositMonths*fc.MaxMonthlyRent))
+	}
+	if math.IsNaN(fc.MinIncomeRequirement) || math.IsInf(fc.MinIncomeRequirement, 0) || fc.MinIncomeRequirement < 0 {
+		v.Add(field+".min_income_requirement", CodeOutOfRange, field+".min_income_requirement must not be negative")
+	}
+}
+
+// preferences checks free-form key-value preferences, in key order so that
+// violations are reported deterministically
+func preferences(v *Violations, field string, prefs map[string]string) {
+	if len(prefs) > maxPreferences {
+		v.Add(field, CodeTooMany, fmt.Sprintf("%s must have at most %d entries", field, maxPreferences),
+			"max_entries", fmt.Sprint(maxPreferences))
+	}
+
+	keys := make([]string, 0, len(prefs))
+	for key := range prefs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		shown := key
+		if len(shown) > maxPreferenceKeyLength {
+			shown = shown[:maxPreferenceKeyLength] + "..."
+		}
+		entry := fmt.Sprintf("%s[%q]", field, shown)
+		if strings.TrimSpace(key) == "" || len(key) > maxPreferenceKeyLength {
+			v.Add(entry, CodeInvalidFormat,
+				fmt.Sprintf("%s keys must be non-blank and at most %d bytes", field, maxPreferenceKeyLength))
+		}
+		if len(prefs[key]) > maxPreferenceValueLength {
+			v.Add(entry, CodeOutOfRange,
+				fmt.Sprintf("%s must be at most %d bytes", entry, maxPreferenceValueLength),
+				"max_length", fmt.Sprint(maxPreferenceValueLength))
+		}
+	}
+}
@@ -0,0 +1,130 @@
+// Package validation checks API requests field by field. Every invalid field
+// is reported, as a gRPC InvalidArgument status carrying a
+// common.v1.ValidationError with one ErrorDetail per field.
+package validation
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/common/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Error codes of field violations
+const (
+	CodeRequired      = "REQUIRED"
+	CodeInvalidEnum   = "INVALID_ENUM"
+	CodeOutOfRange    = "OUT_OF_RANGE"
+	CodeInvalidFormat = "INVALID_FORMAT"
+	CodeInconsistent  = "INCONSISTENT"
+	CodeTooMany       = "TOO_MANY"
+	CodeAlreadyExists = "ALREADY_EXISTS"
+)
+
+// Config selects how thoroughly requests are checked
+type Config struct {
+	// Without validation only the identifiers needed to process a request
+	// are checked
+	Enabled bool `yaml:"enabled"`
+
+	// Strict validation checks every field, not just the required ones
+	Strict bool `yaml:"strict"`
+}
+
+// DefaultConfig returns validation of required fields
+func DefaultConfig() Config {
+	return Config{Enabled: true}
+}
+
+// Violations collects the invalid fields of a request
+type Violations struct {
+	details []*commonv1.ErrorDetail
+}
+
+// Add records an invalid field. Metadata is given as key-value pairs.
+func (v *Violations) Add(field, code, message string, metadata ...string) {
+	detail := &commonv1.ErrorDetail{
+		Code:    code,
+		Message: message,
+		Field:   field,
+	}
+	if len(metadata) > 0 {
+		detail.Metadata = make(map[string]string, len(metadata)/2)
+		for i := 0; i+1 < len(metadata); i += 2 {
+			detail.Metadata[metadata[i]] = metadata[i+1]
+		}
+	}
+	v.details = append(v.details, detail)
+}
+
+// Merge adds the violations carried by an error from Err, except for fields
+// that already have one
+func (v *Violations) Merge(err error) {
+	reported := make(map[string]bool, len(v.details))
+	for _, detail := range v.details {
+		reported[detail.Field] = true
+	}
+	for _, detail := range Details(err) {
+		if !reported[detail.Field] {
+			v.details = append(v.details, detail)
+		}
+	}
+}
+
+// Len returns the number of violations
+func (v *Violations) Len() int {
+	return len(v.details)
+}
+
+// Err returns an InvalidArgument status listing every violation, or nil if
+// there are none
+func (v *Violations) Err() error {
+	if len(v.details) == 0 {
+		return nil
+	}
+
+	messages := make([]string, len(v.details))
+	for i, detail := range v.details {
+		messages[i] = detail.Message
+	}
+	st := status.New(codes.InvalidArgument, "invalid request: "+strings.Join(messages, "; "))
+	withDetails, err := st.WithDetails(&commonv1.ValidationError{Errors: v.details})
+	if err != nil {
+		return st.Err()
+	}
+	return withDetails.Err()
+}
+
+// Details returns the field violations carried by an error from Err
+func Details(err error) []*commonv1.ErrorDetail {
+	st, ok := status.FromError(err)
+	if !ok {
+		return nil
+	}
+	for _, detail := range st.Details() {
+		if validationErr, ok := detail.(*commonv1.ValidationError); ok {
+			return validationErr.Errors
+		}
+	}
+	return nil
+}
+
+// outOfRange records a number outside [min, max]
+func (v *Violations) outOfRange(field string, min, max float64) {
+	v.Add(field, CodeOutOfRange,
+		fmt.Sprintf("%s must be between %g and %g", field, min, max),
+		"min", fmt.Sprint(min), "max", fmt.Sprint(max))
+}
+
+// invalidEnum records an enum value that is not defined
+func (v *Violations) invalidEnum(field string, value int32) {
+	v.Add(field, CodeInvalidEnum, fmt.Sprintf("%s has unknown value %d", field, value),
+		"value", fmt.Sprint(value))
+}
+
+// required records a missing field
+func (v *Violations) required(field string) {
+	v.Add(field, CodeRequired, field+" is required")
+}
@@ -0,0 +1,129 @@
+package validation
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/common/v1"
+	"github.com/wohnfair/wohnfair/services/gen/wohnfair/fairrent/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var strict = Config{Enabled: true, Strict: true}
+
+// fieldCodes maps each reported field to its code
+func fieldCodes(t *testing.T, err error) map[string]string {
+	t.Helper()
+	require.Error(t, err)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	fields := make(map[string]string)
+	for _, detail := range Details(err) {
+		fields[detail.Field] = detail.Code
+	}
+	return fields
+}
+
+func validEnqueue() *fairrentv1.EnqueueRequest {
+	return &fairrentv1.EnqueueRequest{
+		UserId:    &commonv1.UserID{Value: "user1"},
+		UserGroup: commonv1.UserGroup_USER_GROUP_STUDENT,
+		Urgency:   commonv1.UrgencyLevel_URGENCY_LEVEL_HIGH,
+		PreferredLocations: []*commonv1.Location{
+			{Latitude: 52.52, Longitude: 13.405, City: "Berlin", PostalCode: "10115", Country: "DE"},
+		},
+		PropertyTypes: []commonv1.PropertyType{commonv1.PropertyType_PROPERTY_TYPE_APARTMENT},
+		FinancialConstraints: &commonv1.FinancialConstraints{
+			MaxMonthlyRent: 800,
+			MaxDeposit:     2400,
+		},
+		PriorityScore: 0.5,
+	}
+}
+
+func TestEnqueueRequest_Valid(t *testing.T) {
+	assert.NoError(t, EnqueueRequest(validEnqueue(), DefaultConfig()))
+	assert.NoError(t, EnqueueRequest(validEnqueue(), strict))
+}
+
+func TestEnqueueRequest_ReportsEveryField(t *testing.T) {
+	req := validEnqueue()
+	req.UserId = nil
+	req.UserGroup = commonv1.UserGroup(99)
+	req.PreferredLocations[0].Latitude = 91
+	req.PreferredLocations[0].Longitude = math.NaN()
+	req.PreferredLocations[0].Country = "Germany"
+	req.PropertyTypes = append(req.PropertyTypes, commonv1.PropertyType(42))
+	req.FinancialConstraints.MaxDeposit = 2401
+	req.PriorityScore = 1.5
+	req.MinRooms = -1
+
+	assert.Equal(t, map[string]string{
+		"user_id":                           CodeRequired,
+		"user_group":                        CodeInvalidEnum,
+		"preferred_locations[0].latitude":   CodeOutOfRange,
+		"preferred_locations[0].longitude":  CodeOutOfRange,
+		"preferred_locations[0].country":    CodeInvalidFormat,
+		"property_types[1]":                 CodeInvalidEnum,
+		"financial_constraints.max_deposit": CodeInconsistent,
+		"priority_score":                    CodeOutOfRange,
+		"min_rooms":                         CodeOutOfRange,
+	}, fieldCodes(t, EnqueueRequest(req, strict)))
+
+	// Without strict mode only the required fields and the score are checked
+	assert.Equal(t, map[string]string{
+		"user_id":        CodeRequired,
+		"user_group":     CodeInvalidEnum,
+		"priority_score": CodeOutOfRange,
+	}, fieldCodes(t, EnqueueRequest(req, DefaultConfig())))
+
+	// With validation disabled only the user ID is
+	assert.Equal(t, map[string]string{
+		"user_id": CodeRequired,
+	}, fieldCodes(t, EnqueueRequest(req, Config{})))
+
+	req = validEnqueue()
+	req.PriorityScore = math.Inf(1)
+	assert.Equal(t, map[string]string{
+		"priority_score": CodeOutOfRange,
+	}, fieldCodes(t, EnqueueRequest(req, DefaultConfig())))
+}
+
+func TestEnqueueRequest_Metadata(t *testing.T) {
+	req := validEnqueue()
+	req.PriorityScore = -0.1
+
+	details := Details(EnqueueRequest(req, strict))
+	require.Len(t, details, 1)
+	assert.Equal(t, map[string]string{"min": "0", "max": "1"}, details[0].Metadata)
+	assert.Contains(t, status.Convert(EnqueueRequest(req, strict)).Message(), "priority_score must be between 0 and 1")
+}
+
+func TestUpdateRequest(t *testing.T) {
+	req := &fairrentv1.UpdateRequestRequest{
+		TicketId:   &commonv1.TicketID{Value: "TKT 1"},
+		NewUrgency: commonv1.UrgencyLevel(7),
+		NewFinancialConstraints: &commonv1.FinancialConstraints{
+			MaxMonthlyRent:       600,
+			MinIncomeRequirement: -1,
+		},
+		NewAdditionalPreferences: map[string]string{" ": "pets"},
+	}
+
+	assert.Equal(t, map[string]string{
+		"new_urgency": CodeInvalidEnum,
+	}, fieldCodes(t, UpdateRequest(req, DefaultConfig())))
+	assert.Equal(t, map[string]string{
+		"ticket_id":   CodeInvalidFormat,
+		"new_urgency": CodeInvalidEnum,
+		"new_financial_constraints.min_income_requirement": CodeOutOfRange,
+		`new_additional_preferences[" "]`:                  CodeInvalidFormat,
+	}, fieldCodes(t, UpdateRequest(req, strict)))
+}
+
+func TestTicketID(t *testing.T) {
+	assert.NoError(t, TicketID(&commonv1.TicketID{Value: "TKT_1"}))
+	assert.Equal(t, map[string]string{"ticket_id": CodeRequired}, fieldCodes(t, TicketID(nil)))
+}